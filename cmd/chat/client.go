@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	apperror "llm-chat-service/internal/error"
+	"llm-chat-service/internal/service"
+
+	"github.com/gorilla/websocket"
+)
+
+// Transports the client can use
+const (
+	transportSSE       = "sse"
+	transportWebSocket = "ws"
+)
+
+// headerConversationID tags every request of a conversation in the service logs and audit trail
+const headerConversationID = "X-Conversation-ID"
+
+// turnResult is what the service reported besides the streamed tokens
+type turnResult struct {
+	Metadata  service.ResponseMetadata `json:"-"`
+	Citations []service.Citation       `json:"citations"`
+	Warnings  []service.Warning        `json:"warnings"`
+}
+
+// serviceError is an error response returned by the service
+type serviceError struct {
+	detail apperror.ErrorDetail
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (e *serviceError) Error() string {
+	return fmt.Sprintf("%s: %s", e.detail.Type, e.detail.Message)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// parseServiceError returns the error carried by data, or nil if data is not an error response
+func parseServiceError(data []byte) error {
+	var response apperror.ErrorResponse
+	if err := json.Unmarshal(data, &response); err != nil || response.Error.Type == "" {
+		return nil
+	}
+	return &serviceError{detail: response.Error}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// streamTurn sends one user message and forwards the answer tokens to onToken as they arrive
+func streamTurn(ctx context.Context, url, transport, conversationID, content string, onToken func(string)) (*turnResult, error) {
+	body, _ := json.Marshal(map[string]any{
+		"messages": []map[string]string{{"role": "user", "content": content}},
+	})
+	if transport == transportWebSocket {
+		return streamWebSocket(ctx, url, conversationID, body, onToken)
+	}
+	return streamSSE(ctx, url, conversationID, body, onToken)
+}
+
+// ------------------------------------------------------------------------------------------------------
+func streamSSE(ctx context.Context, url, conversationID string, body []byte, onToken func(string)) (*turnResult, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set(headerConversationID, conversationID)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(resp.Body)
+		if err := parseServiceError(data); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	result := &turnResult{}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	event := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			event = ""
+			continue
+		}
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			event = name
+			continue
+		}
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+
+		switch event {
+		case "":
+			if data == "[DONE]" {
+				return result, nil
+			}
+			if err := parseServiceError([]byte(data)); err != nil {
+				return nil, err
+			}
+			onToken(data)
+		case "usage":
+			_ = json.Unmarshal([]byte(data), &result.Metadata)
+		case "citations", "warnings":
+			_ = json.Unmarshal([]byte(data), result)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("stream ended before the answer was complete")
+}
+
+// ------------------------------------------------------------------------------------------------------
+func streamWebSocket(ctx context.Context, url, conversationID string, body []byte, onToken func(string)) (*turnResult, error) {
+	header := http.Header{}
+	header.Set(headerConversationID, conversationID)
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, "ws"+strings.TrimPrefix(url, "http"), header)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// Reads do not take a context; closing the connection unblocks them on cancel
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := conn.WriteMessage(websocket.TextMessage, body); err != nil {
+		return nil, err
+	}
+
+	result := &turnResult{}
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, err
+		}
+
+		var frame struct {
+			Token *string `json:"token"`
+			Type  string  `json:"type"`
+			Done  string  `json:"done"`
+		}
+		if err := json.Unmarshal(data, &frame); err != nil {
+			return nil, fmt.Errorf("invalid frame: %w", err)
+		}
+
+		switch {
+		case frame.Token != nil:
+			onToken(*frame.Token)
+		case frame.Done == "true":
+			return result, nil
+		case frame.Type == "usage":
+			_ = json.Unmarshal(data, &result.Metadata)
+		case frame.Type == "citations", frame.Type == "warnings":
+			_ = json.Unmarshal(data, result)
+		default:
+			if err := parseServiceError(data); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
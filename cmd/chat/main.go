@@ -0,0 +1,268 @@
+// Command chat is an interactive terminal client for debugging the service. It streams answers over
+// SSE or WebSocket, tags every turn with a conversation ID and prints the usage metadata of each turn.
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+const helpText = `Commands:
+  /new             start a new conversation (new conversation ID, empty local history)
+  /history         show this conversation
+  /model           show the model that served the last answer
+  /system [text]   set the instructions sent with every message; "/system clear" removes them
+  /cancel          stop the answer being streamed (Ctrl-C works too)
+  /help            show this help
+  /quit            exit`
+
+// message is a turn of the local transcript
+type message struct {
+	role    string
+	content string
+}
+
+// session is the state of the conversation as seen from the terminal
+type session struct {
+	url       string
+	transport string
+	out       io.Writer
+
+	conversationID string
+	system         string
+	history        []message
+	lastModel      string
+}
+
+func main() {
+	url := flag.String("url", "http://localhost:8000/chat", "chat endpoint")
+	transport := flag.String("transport", transportSSE, "streaming transport: sse or ws")
+	flag.Parse()
+
+	if *transport != transportSSE && *transport != transportWebSocket {
+		fmt.Fprintf(os.Stderr, "chat: unknown transport %q: must be sse or ws\n", *transport)
+		os.Exit(2)
+	}
+
+	s := &session{url: *url, transport: *transport, out: os.Stdout}
+	s.newConversation()
+	fmt.Fprintf(s.out, "Connected to %s over %s. Type /help for commands.\n", s.url, s.transport)
+	s.run(os.Stdin)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// run reads input until EOF or /quit. Lines typed while an answer streams are queued, except /cancel.
+func (s *session) run(in io.Reader) {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+
+	var queued []string
+	eof := false
+	for {
+		fmt.Fprint(s.out, "\nyou> ")
+
+		var line string
+		switch {
+		case len(queued) > 0:
+			line, queued = queued[0], queued[1:]
+		case eof:
+			fmt.Fprintln(s.out)
+			return
+		default:
+			select {
+			case l, ok := <-lines:
+				if !ok {
+					fmt.Fprintln(s.out)
+					return
+				}
+				line = l
+			case <-interrupts:
+				fmt.Fprintln(s.out)
+				return
+			}
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "/") {
+			if quit := s.command(line); quit {
+				return
+			}
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			s.turn(ctx, line)
+		}()
+
+		input := lines
+	streaming:
+		for {
+			select {
+			case <-done:
+				break streaming
+			case <-interrupts:
+				cancel()
+			case l, ok := <-input:
+				switch {
+				case !ok:
+					eof, input = true, nil
+				case strings.TrimSpace(l) == "/cancel":
+					cancel()
+				default:
+					queued = append(queued, l)
+				}
+			}
+		}
+		cancel()
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// turn sends line, streams the answer and prints its metadata
+func (s *session) turn(ctx context.Context, line string) {
+	content := line
+	if s.system != "" {
+		// The API only accepts user and assistant messages, so instructions travel with each message
+		content = fmt.Sprintf("%s\n\n%s", s.system, line)
+	}
+
+	fmt.Fprint(s.out, "\nassistant> ")
+	var answer strings.Builder
+	result, err := streamTurn(ctx, s.url, s.transport, s.conversationID, content, func(token string) {
+		answer.WriteString(token)
+		fmt.Fprint(s.out, token)
+	})
+	fmt.Fprintln(s.out)
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		fmt.Fprintln(s.out, "[cancelled]")
+		return
+	case err != nil:
+		fmt.Fprintf(s.out, "[error] %v\n", err)
+		return
+	}
+
+	s.history = append(s.history, message{role: "user", content: line}, message{role: "assistant", content: answer.String()})
+	s.lastModel = result.Metadata.Model
+
+	for _, warning := range result.Warnings {
+		fmt.Fprintf(s.out, "[warning] %s in %s content (score %.2f: %s)\n",
+			warning.Type, warning.Source, warning.Score, strings.Join(warning.Signals, ", "))
+	}
+	for _, citation := range result.Citations {
+		fmt.Fprintf(s.out, "[%d] %s\n", citation.Index, citation.Source)
+	}
+	fmt.Fprintln(s.out, formatMetadata(result))
+}
+
+// ------------------------------------------------------------------------------------------------------
+func formatMetadata(result *turnResult) string {
+	meta := result.Metadata
+	parts := []string{meta.Model, meta.FinishReason}
+	if meta.Usage != nil {
+		parts = append(parts, fmt.Sprintf("%d prompt + %d completion = %d tokens",
+			meta.Usage.PromptTokens, meta.Usage.CompletionTokens, meta.Usage.TotalTokens))
+	}
+	parts = append(parts, fmt.Sprintf("%dms", meta.LatencyMs))
+	if meta.UpstreamRequestID != "" {
+		parts = append(parts, meta.UpstreamRequestID)
+	}
+
+	var shown []string
+	for _, part := range parts {
+		if part != "" {
+			shown = append(shown, part)
+		}
+	}
+	return "[" + strings.Join(shown, " · ") + "]"
+}
+
+// ------------------------------------------------------------------------------------------------------
+// command runs a slash command and reports whether the client should exit
+func (s *session) command(line string) bool {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case "/new":
+		s.newConversation()
+	case "/history":
+		fmt.Fprintf(s.out, "Conversation %s\n", s.conversationID)
+		if s.system != "" {
+			fmt.Fprintf(s.out, "\nsystem> %s\n", s.system)
+		}
+		for _, msg := range s.history {
+			fmt.Fprintf(s.out, "\n%s> %s\n", msg.role, msg.content)
+		}
+	case "/model":
+		if arg != "" {
+			fmt.Fprintln(s.out, "The model is chosen by the service (MODEL); it cannot be changed per conversation.")
+		}
+		if s.lastModel == "" {
+			fmt.Fprintln(s.out, "No answer yet.")
+		} else {
+			fmt.Fprintf(s.out, "Last answer served by %s\n", s.lastModel)
+		}
+	case "/system":
+		switch arg {
+		case "":
+			if s.system == "" {
+				fmt.Fprintln(s.out, "No instructions set.")
+			} else {
+				fmt.Fprintln(s.out, s.system)
+			}
+		case "clear":
+			s.system = ""
+			fmt.Fprintln(s.out, "Instructions cleared.")
+		default:
+			s.system = arg
+			fmt.Fprintln(s.out, "Instructions set; they are sent with every message.")
+		}
+	case "/cancel":
+		fmt.Fprintln(s.out, "Nothing to cancel.")
+	case "/help":
+		fmt.Fprintln(s.out, helpText)
+	case "/quit", "/exit":
+		return true
+	default:
+		fmt.Fprintf(s.out, "Unknown command %s. Type /help for commands.\n", name)
+	}
+	return false
+}
+
+// ------------------------------------------------------------------------------------------------------
+// newConversation starts a conversation with a fresh ID. The service keeps a single shared history, so
+// earlier turns may still be part of its context.
+func (s *session) newConversation() {
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	s.conversationID = "cli-" + hex.EncodeToString(id)
+	s.history = nil
+	fmt.Fprintf(s.out, "Conversation %s\n", s.conversationID)
+}
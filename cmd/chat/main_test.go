@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"llm-chat-service/internal/api"
+	"llm-chat-service/internal/api/handlers"
+	"llm-chat-service/internal/llm"
+	"llm-chat-service/internal/llm/fakellm"
+	"llm-chat-service/internal/service"
+	"llm-chat-service/internal/storage"
+
+	"go.uber.org/zap"
+)
+
+func TestSession_Run(t *testing.T) {
+	for _, transport := range []string{transportSSE, transportWebSocket} {
+		t.Run(transport, func(t *testing.T) {
+			upstream := fakellm.NewServer(
+				fakellm.Response{Content: "Hello there.", Usage: &fakellm.Usage{PromptTokens: 5, CompletionTokens: 2, TotalTokens: 7}},
+				fakellm.Response{Content: "Still here."},
+			)
+			defer upstream.Close()
+
+			client := llm.NewGroqClient("test-key", upstream.CompletionsURL(), "llama-test")
+			chatService := service.NewChatService(storage.NewMemoryStore(20), nil, client, 256)
+			logger := zap.NewNop()
+			server := httptest.NewServer(api.SetupRouter(handlers.NewHandler(chatService, logger), logger, ""))
+			defer server.Close()
+
+			var out bytes.Buffer
+			s := &session{url: server.URL + "/chat", transport: transport, out: &out}
+			s.newConversation()
+			s.run(strings.NewReader("/system Be brief.\nHi\n/model\nStill there?\n/history\n"))
+
+			output := out.String()
+			for _, want := range []string{
+				"assistant> Hello there.",
+				"[llama-test · stop · 5 prompt + 2 completion = 7 tokens",
+				"Last answer served by llama-test",
+				"assistant> Still here.",
+				"user> Still there?",
+			} {
+				if !strings.Contains(output, want) {
+					t.Errorf("output lacks %q:\n%s", want, output)
+				}
+			}
+
+			requests := upstream.Requests()
+			if len(requests) != 2 {
+				t.Fatalf("upstream requests = %d, want 2", len(requests))
+			}
+			if !strings.Contains(string(requests[0].Messages[0]), `Be brief.\n\nHi`) {
+				t.Errorf("instructions not sent with the message: %s", requests[0].Messages[0])
+			}
+		})
+	}
+}
+
+func TestSession_Cancel(t *testing.T) {
+	upstream := fakellm.NewServer(fakellm.Response{
+		Chunks:     []string{"This ", "answer ", "takes ", "a ", "while."},
+		ChunkDelay: 200 * time.Millisecond,
+	})
+	defer upstream.Close()
+
+	client := llm.NewGroqClient("test-key", upstream.CompletionsURL(), "llama-test")
+	chatService := service.NewChatService(storage.NewMemoryStore(20), nil, client, 256)
+	logger := zap.NewNop()
+	server := httptest.NewServer(api.SetupRouter(handlers.NewHandler(chatService, logger), logger, ""))
+	defer server.Close()
+
+	var out bytes.Buffer
+	s := &session{url: server.URL + "/chat", transport: transportSSE, out: &out}
+	s.newConversation()
+
+	start := time.Now()
+	s.run(strings.NewReader("Tell me a long story\n/cancel\n"))
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("cancelled turn took %v", elapsed)
+	}
+	if !strings.Contains(out.String(), "[cancelled]") || len(s.history) != 0 {
+		t.Errorf("turn not cancelled:\n%s", out.String())
+	}
+}
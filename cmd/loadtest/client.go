@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	apperror "llm-chat-service/internal/error"
+
+	"github.com/gorilla/websocket"
+)
+
+// Transports the load test can use
+const (
+	transportJSON      = "json"
+	transportSSE       = "sse"
+	transportWebSocket = "ws"
+)
+
+// Error types for failures that never produced an error response from the service
+const (
+	errorTypeClientTimeout = "client_timeout"
+	errorTypeConnection    = "connection_error"
+	errorTypeProtocol      = "protocol_error"
+)
+
+// sample is the outcome of one chat request
+type sample struct {
+	transport string
+	latency   time.Duration
+	// ttft is the time to the first token; for JSON requests it is the full latency
+	ttft      time.Duration
+	tokens    int    // Streamed tokens only
+	errorType string // Empty on success
+}
+
+// ------------------------------------------------------------------------------------------------------
+// send performs one chat turn over transport and measures it
+func send(ctx context.Context, url, transport, prompt string) sample {
+	body, _ := json.Marshal(map[string]any{
+		"messages": []map[string]string{{"role": "user", "content": prompt}},
+	})
+
+	s := sample{transport: transport}
+	start := time.Now()
+	firstToken := func() {
+		if s.tokens == 0 {
+			s.ttft = time.Since(start)
+		}
+		s.tokens++
+	}
+
+	var err error
+	switch transport {
+	case transportJSON:
+		err = sendJSON(ctx, url, body)
+		s.ttft = time.Since(start)
+	case transportSSE:
+		err = sendSSE(ctx, url, body, firstToken)
+	case transportWebSocket:
+		err = sendWebSocket(ctx, url, body, firstToken)
+	}
+
+	s.latency = time.Since(start)
+	if err != nil {
+		s.errorType = classify(ctx, err)
+	}
+	return s
+}
+
+// serviceError is an error response returned by the service
+type serviceError struct {
+	errorType apperror.ErrorType
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (e *serviceError) Error() string {
+	return string(e.errorType)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// parseServiceError extracts the error type from an error response body, if data is one
+func parseServiceError(data []byte) error {
+	var response apperror.ErrorResponse
+	if err := json.Unmarshal(data, &response); err != nil || response.Error.Type == "" {
+		return nil
+	}
+	return &serviceError{errorType: response.Error.Type}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func classify(ctx context.Context, err error) string {
+	var svcErr *serviceError
+	switch {
+	case errors.As(err, &svcErr):
+		return string(svcErr.errorType)
+	case ctx.Err() != nil:
+		return errorTypeClientTimeout
+	case errors.Is(err, errProtocol):
+		return errorTypeProtocol
+	default:
+		return errorTypeConnection
+	}
+}
+
+// errProtocol reports a response the load test could not make sense of
+var errProtocol = errors.New("unexpected response")
+
+// ------------------------------------------------------------------------------------------------------
+func newRequest(ctx context.Context, url string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+func sendJSON(ctx context.Context, url string, body []byte) error {
+	req, err := newRequest(ctx, url, body)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		if err := parseServiceError(buf.Bytes()); err != nil {
+			return err
+		}
+		return errProtocol
+	}
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+func sendSSE(ctx context.Context, url string, body []byte, onToken func()) error {
+	req, err := newRequest(ctx, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var buf bytes.Buffer
+		_, _ = buf.ReadFrom(resp.Body)
+		if err := parseServiceError(buf.Bytes()); err != nil {
+			return err
+		}
+		return errProtocol
+	}
+
+	// Unnamed data events are tokens, except for the error object and the [DONE] marker
+	scanner := bufio.NewScanner(resp.Body)
+	named := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			named = false
+		case strings.HasPrefix(line, "event: "):
+			named = true
+		case strings.HasPrefix(line, "data: ") && !named:
+			data := strings.TrimPrefix(line, "data: ")
+			if data == "[DONE]" {
+				return nil
+			}
+			if err := parseServiceError([]byte(data)); err != nil {
+				return err
+			}
+			onToken()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return errProtocol // Stream ended without [DONE]
+}
+
+// ------------------------------------------------------------------------------------------------------
+func sendWebSocket(ctx context.Context, url string, body []byte, onToken func()) error {
+	wsURL := "ws" + strings.TrimPrefix(url, "http")
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Reads do not take a context, so the request timeout becomes a read deadline
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetReadDeadline(deadline)
+	}
+
+	if err := conn.WriteMessage(websocket.TextMessage, body); err != nil {
+		return err
+	}
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		var frame struct {
+			Token *string `json:"token"`
+			Done  string  `json:"done"`
+		}
+		if err := json.Unmarshal(data, &frame); err != nil {
+			return errProtocol
+		}
+		switch {
+		case frame.Token != nil:
+			onToken()
+		case frame.Done == "true":
+			return nil
+		default:
+			if err := parseServiceError(data); err != nil {
+				return err
+			}
+			// Other frames (usage, citations, warnings) carry no tokens
+		}
+	}
+}
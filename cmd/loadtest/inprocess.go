@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"llm-chat-service/internal/config"
+	"llm-chat-service/internal/llm/fakellm"
+
+	"go.uber.org/zap"
+)
+
+// ------------------------------------------------------------------------------------------------------
+// startInProcess boots the service the way cmd/main.go does, with the LLM replaced by a fake upstream
+// answering every request with opts.fakeChunks tokens. It returns the chat endpoint URL.
+func startInProcess(opts options) (string, func(), error) {
+	words := make([]string, opts.fakeChunks)
+	for i := range words {
+		words[i] = fmt.Sprintf("token%d ", i)
+	}
+
+	upstream := fakellm.NewServer()
+	upstream.DiscardRequests()
+	upstream.SetDefault(fakellm.Response{
+		Content:    strings.Join(words, ""),
+		Chunks:     words,
+		Usage:      &fakellm.Usage{PromptTokens: 20, CompletionTokens: len(words), TotalTokens: 20 + len(words)},
+		Latency:    opts.fakeLatency,
+		ChunkDelay: opts.fakeChunkDelay,
+	})
+
+	// The rest of the configuration comes from the environment, as for the real service
+	os.Setenv("GROQ_API_KEY", "loadtest")
+	os.Setenv("GROQ_BASE_URL", upstream.CompletionsURL())
+
+	cfg, err := config.Load()
+	if err != nil {
+		upstream.Close()
+		return "", nil, err
+	}
+
+	logger := zap.NewNop()
+	chatService, cacheStore, err := cfg.NewChatService(logger)
+	if err != nil {
+		upstream.Close()
+		return "", nil, err
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		upstream.Close()
+		return "", nil, err
+	}
+	srv := cfg.NewHTTPServer(cfg.NewRouter(cfg.NewHandler(chatService, logger), logger))
+	go func() { _ = srv.Serve(listener) }()
+
+	stop := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+		if cacheStore != nil {
+			cacheStore.Close()
+		}
+		upstream.Close()
+	}
+	return "http://" + listener.Addr().String() + "/chat", stop, nil
+}
@@ -0,0 +1,173 @@
+// Command loadtest drives the /chat endpoint over JSON, SSE and WebSocket and reports throughput,
+// latency, time to first token and errors. With -fake it boots the service in-process against a fake
+// upstream, which measures the service itself rather than the provider.
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// defaultPrompts is used when no prompt corpus is given
+var defaultPrompts = []string{
+	"What is the capital of France?",
+	"Explain the difference between a process and a thread.",
+	"Write a haiku about autumn.",
+	"Summarize the plot of Hamlet in three sentences.",
+	"How do I reverse a linked list?",
+	"List five tips for better sleep.",
+}
+
+type options struct {
+	url         string
+	transports  []string
+	concurrency int
+	duration    time.Duration
+	requests    int
+	timeout     time.Duration
+	prompts     []string
+
+	fake           bool
+	fakeChunks     int
+	fakeChunkDelay time.Duration
+	fakeLatency    time.Duration
+}
+
+func main() {
+	opts, err := parseFlags()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "loadtest: %v\n", err)
+		os.Exit(2)
+	}
+
+	if opts.fake {
+		url, stop, err := startInProcess(opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "loadtest: failed to start in-process service: %v\n", err)
+			os.Exit(1)
+		}
+		defer stop()
+		opts.url = url
+	}
+
+	fmt.Printf("Load testing %s with %d workers for %v over %s\n\n",
+		opts.url, opts.concurrency, opts.duration, strings.Join(opts.transports, ", "))
+
+	samples, elapsed := run(context.Background(), opts)
+	newReport(samples, elapsed).print(os.Stdout)
+}
+
+// ------------------------------------------------------------------------------------------------------
+func parseFlags() (options, error) {
+	var opts options
+	var transports, promptFile string
+
+	flag.StringVar(&opts.url, "url", "http://localhost:8000/chat", "chat endpoint to load")
+	flag.StringVar(&transports, "transport", "json,sse,ws", "comma-separated transports to rotate through: json, sse, ws")
+	flag.IntVar(&opts.concurrency, "concurrency", 10, "concurrent clients")
+	flag.DurationVar(&opts.duration, "duration", 30*time.Second, "how long to send requests")
+	flag.IntVar(&opts.requests, "requests", 0, "stop after this many requests (0 = until -duration)")
+	flag.DurationVar(&opts.timeout, "timeout", 60*time.Second, "per-request timeout")
+	flag.StringVar(&promptFile, "prompts", "", "file with one prompt per line (default: a small built-in corpus)")
+	flag.BoolVar(&opts.fake, "fake", false, "boot the service in-process against a fake upstream and ignore -url")
+	flag.IntVar(&opts.fakeChunks, "fake-chunks", 50, "tokens per fake upstream answer")
+	flag.DurationVar(&opts.fakeChunkDelay, "fake-chunk-delay", 10*time.Millisecond, "delay between fake upstream tokens")
+	flag.DurationVar(&opts.fakeLatency, "fake-latency", 100*time.Millisecond, "fake upstream delay before the first byte")
+	flag.Parse()
+
+	for _, transport := range strings.Split(transports, ",") {
+		transport = strings.TrimSpace(transport)
+		switch transport {
+		case transportJSON, transportSSE, transportWebSocket:
+			opts.transports = append(opts.transports, transport)
+		default:
+			return opts, fmt.Errorf("unknown transport %q: must be json, sse or ws", transport)
+		}
+	}
+	if opts.concurrency <= 0 {
+		return opts, fmt.Errorf("-concurrency must be positive")
+	}
+
+	opts.prompts = defaultPrompts
+	if promptFile != "" {
+		prompts, err := readPrompts(promptFile)
+		if err != nil {
+			return opts, err
+		}
+		opts.prompts = prompts
+	}
+	return opts, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+func readPrompts(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open prompt corpus: %w", err)
+	}
+	defer file.Close()
+
+	var prompts []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if prompt := strings.TrimSpace(scanner.Text()); prompt != "" {
+			prompts = append(prompts, prompt)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read prompt corpus: %w", err)
+	}
+	if len(prompts) == 0 {
+		return nil, fmt.Errorf("prompt corpus %s is empty", path)
+	}
+	return prompts, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// run sends requests from opts.concurrency workers until the duration or request budget is spent.
+// Requests in flight when time is up are allowed to finish.
+func run(ctx context.Context, opts options) ([]sample, time.Duration) {
+	deadline := time.Now().Add(opts.duration)
+
+	var (
+		mu      sync.Mutex
+		samples []sample
+		issued  atomic.Int64
+		wg      sync.WaitGroup
+	)
+
+	start := time.Now()
+	for w := 0; w < opts.concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for time.Now().Before(deadline) {
+				n := issued.Add(1)
+				if opts.requests > 0 && n > int64(opts.requests) {
+					return
+				}
+
+				transport := opts.transports[int(n-1)%len(opts.transports)]
+				prompt := opts.prompts[int(n-1)%len(opts.prompts)]
+
+				reqCtx, cancel := context.WithTimeout(ctx, opts.timeout)
+				s := send(reqCtx, opts.url, transport, prompt)
+				cancel()
+
+				mu.Lock()
+				samples = append(samples, s)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return samples, time.Since(start)
+}
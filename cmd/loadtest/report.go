@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// report aggregates the samples of a run, per transport and overall
+type report struct {
+	elapsed time.Duration
+	rows    []reportRow
+	errors  map[string]int
+}
+
+type reportRow struct {
+	name      string
+	requests  int
+	failed    int
+	tokens    int
+	latencies []time.Duration // Successful requests only
+	ttfts     []time.Duration
+}
+
+// ------------------------------------------------------------------------------------------------------
+func newReport(samples []sample, elapsed time.Duration) *report {
+	r := &report{elapsed: elapsed, errors: map[string]int{}}
+
+	byTransport := map[string]*reportRow{}
+	total := &reportRow{name: "total"}
+	for _, s := range samples {
+		row, ok := byTransport[s.transport]
+		if !ok {
+			row = &reportRow{name: s.transport}
+			byTransport[s.transport] = row
+		}
+		for _, row := range []*reportRow{row, total} {
+			row.add(s)
+		}
+		if s.errorType != "" {
+			r.errors[s.errorType]++
+		}
+	}
+
+	for _, name := range []string{transportJSON, transportSSE, transportWebSocket} {
+		if row, ok := byTransport[name]; ok {
+			r.rows = append(r.rows, *row)
+		}
+	}
+	r.rows = append(r.rows, *total)
+	return r
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (row *reportRow) add(s sample) {
+	row.requests++
+	if s.errorType != "" {
+		row.failed++
+		return
+	}
+	row.tokens += s.tokens
+	row.latencies = append(row.latencies, s.latency)
+	row.ttfts = append(row.ttfts, s.ttft)
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (r *report) print(w io.Writer) {
+	seconds := r.elapsed.Seconds()
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "transport\trequests\terrors\treq/s\ttokens/s\tp50\tp95\tp99\tttft p50\tttft p95\tttft p99\t")
+	for _, row := range r.rows {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%.1f\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+			row.name, row.requests, row.failed,
+			float64(row.requests-row.failed)/seconds, float64(row.tokens)/seconds,
+			formatDuration(percentile(row.latencies, 50)),
+			formatDuration(percentile(row.latencies, 95)),
+			formatDuration(percentile(row.latencies, 99)),
+			formatDuration(percentile(row.ttfts, 50)),
+			formatDuration(percentile(row.ttfts, 95)),
+			formatDuration(percentile(row.ttfts, 99)),
+		)
+	}
+	tw.Flush()
+
+	fmt.Fprintf(w, "\nElapsed: %v\n", r.elapsed.Round(time.Millisecond))
+	if len(r.errors) == 0 {
+		return
+	}
+
+	types := make([]string, 0, len(r.errors))
+	for errorType := range r.errors {
+		types = append(types, errorType)
+	}
+	sort.Slice(types, func(i, j int) bool { return r.errors[types[i]] > r.errors[types[j]] })
+
+	fmt.Fprintln(w, "\nErrors by type:")
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, errorType := range types {
+		fmt.Fprintf(tw, "  %s\t%d\n", errorType, r.errors[errorType])
+	}
+	tw.Flush()
+}
+
+// ------------------------------------------------------------------------------------------------------
+// percentile returns the nearest-rank pth percentile of durations, or 0 when there are none
+func percentile(durations []time.Duration, p float64) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := int(p/100*float64(len(sorted))+0.5) - 1
+	rank = max(0, min(rank, len(sorted)-1))
+	return sorted[rank]
+}
+
+// ------------------------------------------------------------------------------------------------------
+func formatDuration(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	}
+	return d.Round(time.Millisecond).String()
+}
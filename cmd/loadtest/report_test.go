@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	var durations []time.Duration
+	for i := 100; i >= 1; i-- {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{50, 50 * time.Millisecond},
+		{95, 95 * time.Millisecond},
+		{99, 99 * time.Millisecond},
+		{100, 100 * time.Millisecond},
+		{0, 1 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		if got := percentile(durations, tt.p); got != tt.want {
+			t.Errorf("percentile(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+
+	if got := percentile(nil, 50); got != 0 {
+		t.Errorf("percentile(nil) = %v, want 0", got)
+	}
+}
+
+func TestNewReport(t *testing.T) {
+	samples := []sample{
+		{transport: transportSSE, latency: 200 * time.Millisecond, ttft: 50 * time.Millisecond, tokens: 10},
+		{transport: transportSSE, errorType: "rate_limit_error"},
+		{transport: transportJSON, latency: 100 * time.Millisecond, ttft: 100 * time.Millisecond},
+		{transport: transportWebSocket, errorType: errorTypeClientTimeout},
+	}
+
+	r := newReport(samples, time.Second)
+
+	var names []string
+	for _, row := range r.rows {
+		names = append(names, row.name)
+	}
+	if want := []string{"json", "sse", "ws", "total"}; len(names) != len(want) || names[0] != want[0] || names[3] != want[3] {
+		t.Errorf("rows = %v, want %v", names, want)
+	}
+
+	total := r.rows[len(r.rows)-1]
+	if total.requests != 4 || total.failed != 2 || total.tokens != 10 || len(total.latencies) != 2 {
+		t.Errorf("total = %+v", total)
+	}
+	if r.errors["rate_limit_error"] != 1 || r.errors[errorTypeClientTimeout] != 1 {
+		t.Errorf("errors = %v", r.errors)
+	}
+}
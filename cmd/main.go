@@ -3,8 +3,10 @@ package main
 import (
 	"context"
 	"fmt"
+	"llm-chat-service/internal/api/handlers"
 	"llm-chat-service/internal/config"
 	"llm-chat-service/internal/logging"
+	"llm-chat-service/internal/service"
 	"net/http"
 	"os"
 	"os/signal"
@@ -31,16 +33,68 @@ func main() {
 
 	logger.Info("Starting LLM Chat Service",
 		zap.String("port", cfg.Port),
+		zap.String("config_file", cfg.ConfigFile),
+		zap.String("redis_mode", cfg.RedisMode),
 		zap.String("redis_addr", cfg.RedisAddr),
+		zap.String("tracing_exporter", cfg.TracingExporter),
 	)
 
-	chatService, cacheStore := cfg.NewChatService(logger)
+	shutdownTracing, err := cfg.NewTracing(context.Background())
+	if err != nil {
+		logger.Fatal("Failed to initialize tracing", zap.Error(err))
+	}
+
+	auditor, err := cfg.NewAuditor(logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize audit log", zap.Error(err))
+	}
+
+	toolRegistry, err := cfg.NewToolRegistry()
+	if err != nil {
+		logger.Fatal("Failed to initialize tools", zap.Error(err))
+	}
+
+	knowledge, err := cfg.NewKnowledgeService(logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize knowledge bases", zap.Error(err))
+	}
+	if knowledge != nil {
+		defer knowledge.Close()
+	}
+
+	moderator, err := cfg.NewModerator(logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize content moderation", zap.Error(err))
+	}
+
+	injectionDetector, err := cfg.NewInjectionDetector()
+	if err != nil {
+		logger.Fatal("Failed to initialize prompt injection detection", zap.Error(err))
+	}
+
+	chatService, cacheStore, err := cfg.NewChatService(logger,
+		service.WithAuditor(auditor),
+		service.WithTools(toolRegistry),
+		service.WithKnowledge(knowledge),
+		service.WithModerator(moderator),
+		service.WithInjectionDetector(injectionDetector),
+	)
+	if err != nil {
+		logger.Fatal("Failed to initialize chat service", zap.Error(err))
+	}
 
 	if cacheStore != nil {
 		defer cacheStore.Close()
 	}
 
-	handler := cfg.NewHandler(chatService, logger)
+	checks := cfg.NewHealth(chatService, cacheStore)
+	streams := handlers.NewStreamTracker()
+
+	handler := cfg.NewHandler(chatService, logger,
+		handlers.WithKnowledge(knowledge),
+		handlers.WithHealth(checks),
+		handlers.WithStreamTracker(streams),
+	)
 
 	router := cfg.NewRouter(handler, logger)
 
@@ -54,20 +108,46 @@ func main() {
 		}
 	}()
 
+	// Reload the configuration file on SIGHUP
+	reloadCtx, stopReloading := context.WithCancel(context.Background())
+	defer stopReloading()
+	go cfg.NewReloader(chatService, logger).WatchSignals(reloadCtx)
+
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
 	logger.Info("Shutting down server...")
+	stopReloading()
+	checks.SetShuttingDown()
+	time.Sleep(cfg.ShutdownDelay)
+
+	// Let the chats in flight finish; http.Server.Shutdown does not wait for hijacked WebSocket connections
+	logger.Info("Draining active chats", zap.Int("active", streams.Active()), zap.Duration("timeout", cfg.ShutdownDrainTimeout))
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.ShutdownDrainTimeout)
+	if err := streams.Shutdown(drainCtx); err != nil {
+		logger.Warn("Chats still running at the drain deadline were cancelled", zap.Error(err))
+	}
+	cancelDrain()
 
 	// Graceful shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
 	if err := srv.Shutdown(ctx); err != nil {
 		logger.Error("Server forced to shutdown", zap.Error(err))
 	}
 
+	if auditor != nil {
+		if err := auditor.Close(); err != nil {
+			logger.Error("Failed to close audit log", zap.Error(err))
+		}
+	}
+
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error("Failed to flush traces", zap.Error(err))
+	}
+
 	logger.Info("Server stopped")
 }
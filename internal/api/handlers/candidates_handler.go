@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	apperror "llm-chat-service/internal/error"
+	"llm-chat-service/internal/logging"
+	"llm-chat-service/internal/service"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// selectCandidateRequest names the candidate to store in the conversation history
+type selectCandidateRequest struct {
+	Index *int `json:"index"`
+}
+
+// ------------------------------------------------------------------------------------------------------
+// SelectCandidateHandler stores the chosen candidate of a multi-candidate turn in the history. The
+// selection ID comes with the turn's response when it asked for n > 1 without persist_index.
+func (h *Handler) SelectCandidateHandler(w http.ResponseWriter, r *http.Request) {
+	selector, ok := h.chatService.(service.CandidateSelector)
+	if !ok {
+		h.sendErrorResponse(w, r, apperror.NewNotFoundError("candidate selection is not available on this server", nil))
+		return
+	}
+
+	logger := logging.FromContext(r.Context())
+
+	var req selectCandidateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendErrorResponse(w, r, apperror.NewValidationError("Invalid JSON in request body", err))
+		return
+	}
+	if req.Index == nil {
+		h.sendErrorResponse(w, r, apperror.NewValidationError("index is required", nil))
+		return
+	}
+
+	candidate, err := selector.SelectCandidate(r.Context(), mux.Vars(r)["id"], *req.Index)
+	if err != nil {
+		logger.Warn("Candidate selection failed", zap.Error(err))
+		h.sendErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if encodeErr := json.NewEncoder(w).Encode(candidate); encodeErr != nil {
+		logger.Error("Failed to encode response", zap.Error(encodeErr))
+	}
+}
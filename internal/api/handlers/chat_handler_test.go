@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"llm-chat-service/internal/llm"
+	"llm-chat-service/internal/llm/fakellm"
+	"llm-chat-service/internal/service"
+	"llm-chat-service/internal/storage"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with the current output")
+
+// latencyField varies between runs, so it is zeroed before comparing with golden files
+var latencyField = regexp.MustCompile(`("latency_ms": ?)\d+`)
+
+const chatRequestBody = `{"messages":[{"role":"user","content":"What is the capital of France?"}]}`
+
+// ------------------------------------------------------------------------------------------------------
+// newTestServer serves the chat handler backed by a real chat service and Groq client talking to a
+// fake upstream answering responses
+func newTestServer(t *testing.T, responses ...fakellm.Response) *httptest.Server {
+	t.Helper()
+
+	upstream := fakellm.NewServer(responses...)
+	t.Cleanup(upstream.Close)
+
+	client := llm.NewGroqClient("test-key", upstream.CompletionsURL(), "llama-test")
+	chatService := service.NewChatService(storage.NewMemoryStore(20), nil, client, 256)
+	handler := NewHandler(chatService, zap.NewNop())
+
+	server := httptest.NewServer(http.HandlerFunc(handler.ChatHandler))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// ------------------------------------------------------------------------------------------------------
+// assertGolden compares got with testdata/<name>.golden, rewriting the file when -update is set
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	got = latencyField.ReplaceAll(got, []byte(`${1}0`))
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("failed to update golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run with -update to create it): %v", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("%s mismatch\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// Scripted upstream replies shared by the transport tests
+var transportCases = []struct {
+	name     string
+	response fakellm.Response
+}{
+	{
+		name: "stop",
+		response: fakellm.Response{
+			Content:   "Paris is the capital of France.",
+			Usage:     &fakellm.Usage{PromptTokens: 14, CompletionTokens: 7, TotalTokens: 21},
+			RequestID: "req_upstream_1",
+		},
+	},
+	{
+		name: "malformed_chunks",
+		response: fakellm.Response{
+			Content:   "Paris.",
+			Malformed: true,
+		},
+	},
+	{
+		name:     "rate_limited",
+		response: fakellm.Response{Status: http.StatusTooManyRequests, Body: `{"error":{"message":"Rate limit reached"}}`},
+	},
+	{
+		name:     "upstream_error",
+		response: fakellm.Response{Status: http.StatusInternalServerError, Body: `{"error":{"message":"Internal"}}`},
+	},
+}
+
+func TestChatHandler_SSE_Golden(t *testing.T) {
+	for _, tt := range transportCases {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t, tt.response)
+
+			req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(chatRequestBody))
+			if err != nil {
+				t.Fatalf("NewRequest() error = %v", err)
+			}
+			req.Header.Set("Accept", "text/event-stream")
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("POST error = %v", err)
+			}
+			defer resp.Body.Close()
+
+			if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+				t.Errorf("Content-Type = %q", got)
+			}
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("failed to read body: %v", err)
+			}
+
+			assertGolden(t, "sse_"+tt.name, body)
+		})
+	}
+}
+
+func TestChatHandler_SSE_OutlivesWriteTimeout(t *testing.T) {
+	upstream := fakellm.NewServer(fakellm.Response{
+		Content:    "Paris is the capital of France.",
+		ChunkDelay: 50 * time.Millisecond,
+	})
+	t.Cleanup(upstream.Close)
+
+	client := llm.NewGroqClient("test-key", upstream.CompletionsURL(), "llama-test")
+	chatService := service.NewChatService(storage.NewMemoryStore(20), nil, client, 256)
+	handler := NewHandler(chatService, zap.NewNop())
+
+	// The stream takes several times the write timeout
+	server := httptest.NewUnstartedServer(http.HandlerFunc(handler.ChatHandler))
+	server.Config.WriteTimeout = 100 * time.Millisecond
+	server.Start()
+	t.Cleanup(server.Close)
+
+	req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(chatRequestBody))
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST error = %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read body: %v", err)
+	}
+	if !strings.HasSuffix(string(body), "data: [DONE]\n\n") {
+		t.Errorf("stream was cut short:\n%s", body)
+	}
+}
+
+func TestChatHandler_WebSocket_Golden(t *testing.T) {
+	for _, tt := range transportCases {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t, tt.response)
+
+			conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+			if err != nil {
+				t.Fatalf("Dial() error = %v", err)
+			}
+			defer conn.Close()
+
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(chatRequestBody)); err != nil {
+				t.Fatalf("WriteMessage() error = %v", err)
+			}
+
+			// The server closes the connection after the done or error frame
+			var transcript bytes.Buffer
+			for {
+				_, frame, err := conn.ReadMessage()
+				if err != nil {
+					break
+				}
+				transcript.Write(bytes.TrimSpace(frame))
+				transcript.WriteByte('\n')
+			}
+
+			assertGolden(t, "ws_"+tt.name, transcript.Bytes())
+		})
+	}
+}
+
+func TestChatHandler_JSON_Golden(t *testing.T) {
+	for _, tt := range transportCases {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t, tt.response)
+
+			resp, err := http.Post(server.URL, "application/json", strings.NewReader(chatRequestBody))
+			if err != nil {
+				t.Fatalf("POST error = %v", err)
+			}
+			defer resp.Body.Close()
+
+			var body map[string]any
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("failed to decode body: %v", err)
+			}
+			data, _ := json.MarshalIndent(map[string]any{"status": resp.StatusCode, "body": body}, "", "  ")
+
+			assertGolden(t, "json_"+tt.name, append(data, '\n'))
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"llm-chat-service/internal/llm"
+	"llm-chat-service/internal/llm/fakellm"
+	"llm-chat-service/internal/service"
+	"llm-chat-service/internal/storage"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// clientToolRequest declares a client-executed tool the scripted model calls
+const clientToolRequest = `{"messages":[{"role":"user","content":"Where am I?"}],` +
+	`"tools":[{"type":"function","function":{"name":"get_location"}}]}`
+
+// ------------------------------------------------------------------------------------------------------
+// dialClientToolChat serves the WebSocket chat with the given client tool timeout, sends a request
+// declaring a client tool and returns the connection, the fake upstream and a channel closed once the
+// handler returns
+func dialClientToolChat(t *testing.T, timeout time.Duration, responses ...fakellm.Response) (*websocket.Conn, *fakellm.Server, <-chan struct{}) {
+	t.Helper()
+
+	upstream := fakellm.NewServer(responses...)
+	t.Cleanup(upstream.Close)
+
+	client := llm.NewGroqClient("test-key", upstream.CompletionsURL(), "llama-test")
+	chatService := service.NewChatService(storage.NewMemoryStore(20), nil, client, 256)
+	handler := NewHandler(chatService, zap.NewNop(), WithClientToolTimeout(timeout))
+
+	served := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(served)
+		handler.ChatHandler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(clientToolRequest)); err != nil {
+		t.Fatalf("WriteMessage() error = %v", err)
+	}
+	return conn, upstream, served
+}
+
+// ------------------------------------------------------------------------------------------------------
+// readToolCall reads the next frame and checks it asks for the given tool call
+func readToolCall(t *testing.T, conn *websocket.Conn, id string) {
+	t.Helper()
+
+	var frame wsToolCallFrame
+	if err := conn.ReadJSON(&frame); err != nil {
+		t.Fatalf("ReadJSON() error = %v", err)
+	}
+	if frame.Type != wsFrameToolCall || frame.ToolCall.ID != id || frame.ToolCall.Function.Name != "get_location" {
+		t.Fatalf("frame = %+v, want a tool_call for %s", frame, id)
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// readTranscript reads frames until the server closes the connection
+func readTranscript(conn *websocket.Conn) string {
+	var transcript strings.Builder
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return transcript.String()
+		}
+		transcript.Write(data)
+		transcript.WriteByte('\n')
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// toolMessage returns the tool message the second upstream request carried back to the model
+func toolMessage(t *testing.T, upstream *fakellm.Server) llm.Message {
+	t.Helper()
+
+	requests := upstream.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("upstream requests = %d, want 2", len(requests))
+	}
+	messages := requests[1].Messages
+	var msg llm.Message
+	if err := json.Unmarshal(messages[len(messages)-1], &msg); err != nil {
+		t.Fatalf("failed to decode the tool message: %v", err)
+	}
+	return msg
+}
+
+func TestWebSocket_ClientTool(t *testing.T) {
+	tests := []struct {
+		name        string
+		frames      []wsClientFrame
+		wantContent string
+	}{
+		{
+			name:        "result",
+			frames:      []wsClientFrame{{Type: wsFrameToolResult, ToolCallID: "call_1", Content: "Paris"}},
+			wantContent: "Paris",
+		},
+		{
+			name: "mismatched id is ignored",
+			frames: []wsClientFrame{
+				{Type: wsFrameToolResult, ToolCallID: "call_2", Content: "Berlin"},
+				{Type: "ping"},
+				{Type: wsFrameToolResult, ToolCallID: "call_1", Content: "Paris"},
+			},
+			wantContent: "Paris",
+		},
+		{
+			name:        "client error",
+			frames:      []wsClientFrame{{Type: wsFrameToolResult, ToolCallID: "call_1", Error: "location unavailable"}},
+			wantContent: `{"error":"location unavailable"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, upstream, _ := dialClientToolChat(t, 5*time.Second,
+				fakellm.Response{ToolCalls: []fakellm.ToolCall{{ID: "call_1", Name: "get_location", Arguments: "{}"}}},
+				fakellm.Response{Content: "You are in Paris."},
+			)
+
+			readToolCall(t, conn, "call_1")
+			for _, frame := range tt.frames {
+				if err := conn.WriteJSON(frame); err != nil {
+					t.Fatalf("WriteJSON() error = %v", err)
+				}
+			}
+
+			transcript := readTranscript(conn)
+			if !strings.Contains(transcript, `"done":"true"`) {
+				t.Errorf("turn did not complete:\n%s", transcript)
+			}
+			if msg := toolMessage(t, upstream); msg.Role != "tool" || msg.ToolCallID != "call_1" || msg.Content != tt.wantContent {
+				t.Errorf("tool message = %+v, want content %q", msg, tt.wantContent)
+			}
+		})
+	}
+}
+
+func TestWebSocket_ClientToolTimeoutEndsTurn(t *testing.T) {
+	conn, upstream, _ := dialClientToolChat(t, 50*time.Millisecond,
+		fakellm.Response{ToolCalls: []fakellm.ToolCall{
+			{ID: "call_1", Name: "get_location", Arguments: "{}"},
+			{ID: "call_2", Name: "get_location", Arguments: "{}"},
+		}},
+		fakellm.Response{Content: "You are somewhere."},
+	)
+
+	readToolCall(t, conn, "call_1")
+
+	// The second call is never sent: the turn ends with the first timeout
+	transcript := readTranscript(conn)
+	if strings.Contains(transcript, wsFrameToolCall) {
+		t.Errorf("tool calls sent after the timeout:\n%s", transcript)
+	}
+	if !strings.Contains(transcript, `"timeout_error"`) || strings.Contains(transcript, `"done"`) {
+		t.Errorf("turn should end with a timeout error:\n%s", transcript)
+	}
+	if requests := upstream.Requests(); len(requests) != 1 {
+		t.Errorf("upstream requests = %d, want the model not to be called again", len(requests))
+	}
+}
+
+func TestWebSocket_ClientToolDisconnectEndsTurn(t *testing.T) {
+	conn, upstream, served := dialClientToolChat(t, 5*time.Second,
+		fakellm.Response{ToolCalls: []fakellm.ToolCall{{ID: "call_1", Name: "get_location", Arguments: "{}"}}},
+		fakellm.Response{Content: "You are somewhere."},
+	)
+
+	readToolCall(t, conn, "call_1")
+	conn.Close()
+
+	select {
+	case <-served:
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler still running after the client went away")
+	}
+	if requests := upstream.Requests(); len(requests) != 1 {
+		t.Errorf("upstream requests = %d, want the model not to be called again", len(requests))
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	apperror "llm-chat-service/internal/error"
+)
+
+// shutdownNotice is sent to streaming clients when the server starts draining
+const shutdownNotice = "The server is shutting down; new chats are refused and this answer is cancelled if it does not complete in time"
+
+// errServerShutdown is the cancellation cause of chats still running at the drain deadline
+var errServerShutdown = errors.New("server shutting down")
+
+// errStreamClosed is returned by writes attempted after a streaming handler has returned
+var errStreamClosed = errors.New("stream closed")
+
+// forcedStopTimeout bounds the wait for cancelled chats to return after the drain deadline
+const forcedStopTimeout = 5 * time.Second
+
+// StreamTracker keeps track of the chats in flight, including hijacked WebSocket connections that
+// http.Server.Shutdown does not see, so that shutdown can refuse new chats, warn streaming clients
+// and let running generations finish before cancelling them
+type StreamTracker struct {
+	mu       sync.Mutex
+	draining bool
+	chats    map[*trackedChat]struct{}
+	wg       sync.WaitGroup
+}
+
+type trackedChat struct {
+	cancel context.CancelCauseFunc
+	// notify sends the shutdown notice; nil for JSON chats, which have no stream. It runs on its own
+	// goroutine and may still run after the chat ended, so it must not touch a finished response.
+	notify func()
+}
+
+// ------------------------------------------------------------------------------------------------------
+func NewStreamTracker() *StreamTracker {
+	return &StreamTracker{chats: map[*trackedChat]struct{}{}}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// begin registers a chat. It returns the context the chat must run under and the function to call
+// when it ends, or ok == false when the server is draining and the chat must be refused.
+func (t *StreamTracker) begin(ctx context.Context, notify func()) (context.Context, func(), bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.draining {
+		return nil, nil, false
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	chat := &trackedChat{cancel: cancel, notify: notify}
+	t.chats[chat] = struct{}{}
+	t.wg.Add(1)
+
+	end := func() {
+		t.mu.Lock()
+		delete(t.chats, chat)
+		t.mu.Unlock()
+		cancel(nil)
+		t.wg.Done()
+	}
+	return ctx, end, true
+}
+
+// ------------------------------------------------------------------------------------------------------
+// isDraining reports whether Shutdown has started
+func (t *StreamTracker) isDraining() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.draining
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Active returns the number of chats in flight
+func (t *StreamTracker) Active() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.chats)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Shutdown refuses new chats, sends a server_shutdown event to every streaming client and waits for
+// the chats in flight to finish. Chats still running when ctx expires are cancelled, and Shutdown
+// returns ctx's error once they have returned.
+func (t *StreamTracker) Shutdown(ctx context.Context) error {
+	t.mu.Lock()
+	t.draining = true
+	for chat := range t.chats {
+		if chat.notify != nil {
+			go chat.notify() // A slow client must not hold up the others
+		}
+	}
+	t.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	t.mu.Lock()
+	for chat := range t.chats {
+		chat.cancel(errServerShutdown)
+	}
+	t.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-time.After(forcedStopTimeout):
+	}
+	return ctx.Err()
+}
+
+// ------------------------------------------------------------------------------------------------------
+// drainingError refuses a chat arriving after shutdown started
+func drainingError() error {
+	return apperror.NewUnavailableError("The server is shutting down, retry the request", nil)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// shutdownError replaces the error of a chat cancelled by Shutdown with one telling the client why
+func shutdownError(ctx context.Context, err error) error {
+	if errors.Is(context.Cause(ctx), errServerShutdown) {
+		return apperror.NewUnavailableError("The server shut down before the answer was complete", err)
+	}
+	return err
+}
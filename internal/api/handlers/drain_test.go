@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"llm-chat-service/internal/llm"
+	"llm-chat-service/internal/llm/fakellm"
+	"llm-chat-service/internal/service"
+	"llm-chat-service/internal/storage"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+// ------------------------------------------------------------------------------------------------------
+// newDrainServer serves the chat handler with a stream tracker the test can shut down
+func newDrainServer(t *testing.T, responses ...fakellm.Response) (*httptest.Server, *StreamTracker) {
+	t.Helper()
+
+	upstream := fakellm.NewServer(responses...)
+	t.Cleanup(upstream.Close)
+
+	client := llm.NewGroqClient("test-key", upstream.CompletionsURL(), "llama-test")
+	chatService := service.NewChatService(storage.NewMemoryStore(20), nil, client, 256)
+	tracker := NewStreamTracker()
+	handler := NewHandler(chatService, zap.NewNop(), WithStreamTracker(tracker))
+
+	server := httptest.NewServer(http.HandlerFunc(handler.ChatHandler))
+	t.Cleanup(server.Close)
+	return server, tracker
+}
+
+// ------------------------------------------------------------------------------------------------------
+// startSSEChat posts a streaming chat and returns once its first token has arrived
+func startSSEChat(t *testing.T, url string) *bufio.Reader {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(chatRequestBody))
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST error = %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", contentType)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "data: ") {
+		t.Fatalf("first line = %q, %v; want a token", line, err)
+	}
+	return reader
+}
+
+// ------------------------------------------------------------------------------------------------------
+func TestStreamTracker_DrainsSSEChat(t *testing.T) {
+	server, tracker := newDrainServer(t, fakellm.Response{
+		Content:    "Paris is the capital of France.",
+		ChunkDelay: 20 * time.Millisecond,
+	})
+
+	reader := startSSEChat(t, server.URL)
+	if got := tracker.Active(); got != 1 {
+		t.Fatalf("Active() = %d, want 1", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- tracker.Shutdown(ctx) }()
+
+	rest, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read body: %v", err)
+	}
+	if !strings.Contains(string(rest), "event: server_shutdown\n") {
+		t.Errorf("stream has no server_shutdown event:\n%s", rest)
+	}
+	if !strings.Contains(string(rest), "France.") || !strings.HasSuffix(string(rest), "data: [DONE]\n\n") {
+		t.Errorf("answer was not completed:\n%s", rest)
+	}
+	if err := <-shutdown; err != nil {
+		t.Errorf("Shutdown() error = %v, want the chat to finish in time", err)
+	}
+
+	// New chats are refused while draining
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader(chatRequestBody))
+	if err != nil {
+		t.Fatalf("POST error = %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusServiceUnavailable || !strings.Contains(string(body), "service_unavailable") {
+		t.Errorf("chat during drain = %d %s, want 503 service_unavailable", resp.StatusCode, body)
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func TestStreamTracker_CancelsChatsAtDeadline(t *testing.T) {
+	server, tracker := newDrainServer(t, fakellm.Response{
+		Content:    "Paris is the capital of France.",
+		ChunkDelay: 200 * time.Millisecond,
+	})
+
+	reader := startSSEChat(t, server.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := tracker.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() error = %v, want the deadline to be exceeded", err)
+	}
+	if got := tracker.Active(); got != 0 {
+		t.Errorf("Active() = %d after Shutdown, want 0", got)
+	}
+
+	rest, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read body: %v", err)
+	}
+	if !strings.Contains(string(rest), `"service_unavailable"`) || strings.Contains(string(rest), "[DONE]") {
+		t.Errorf("cancelled stream should end with a service_unavailable error:\n%s", rest)
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func TestStreamTracker_DrainsWebSocketChat(t *testing.T) {
+	server, tracker := newDrainServer(t, fakellm.Response{
+		Content:    "Paris is the capital of France.",
+		ChunkDelay: 20 * time.Millisecond,
+	})
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(chatRequestBody)); err != nil {
+		t.Fatalf("WriteMessage() error = %v", err)
+	}
+
+	var transcript strings.Builder
+	readFrame := func() bool {
+		_, frame, err := conn.ReadMessage()
+		if err != nil {
+			return false
+		}
+		transcript.Write(frame)
+		return true
+	}
+	if !readFrame() {
+		t.Fatal("connection closed before the first token")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- tracker.Shutdown(ctx) }()
+
+	for readFrame() {
+	}
+	if !strings.Contains(transcript.String(), `"type":"server_shutdown"`) {
+		t.Errorf("transcript has no server_shutdown frame:\n%s", transcript.String())
+	}
+	if !strings.Contains(transcript.String(), `"done":"true"`) {
+		t.Errorf("answer was not completed:\n%s", transcript.String())
+	}
+	if err := <-shutdown; err != nil {
+		t.Errorf("Shutdown() error = %v, want the chat to finish in time", err)
+	}
+}
+
+// blockingChatService streams one token, then waits for release before answering
+type blockingChatService struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingChatService) ProcessChat(ctx context.Context, req *service.ChatRequest) (*service.ChatResponse, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (s *blockingChatService) ProcessChatStream(ctx context.Context, req *service.ChatRequest, onToken func(string) error) (*service.ChatResponse, error) {
+	if err := onToken("Paris"); err != nil {
+		return nil, err
+	}
+	close(s.started)
+	<-s.release
+	return &service.ChatResponse{Content: "Paris"}, nil
+}
+
+// finishedResponseWriter counts the writes made after the handler returned
+type finishedResponseWriter struct {
+	*httptest.ResponseRecorder
+	finished   atomic.Bool
+	lateWrites atomic.Int32
+}
+
+func (w *finishedResponseWriter) Write(p []byte) (int, error) {
+	if w.finished.Load() {
+		w.lateWrites.Add(1)
+	}
+	return w.ResponseRecorder.Write(p)
+}
+
+func (w *finishedResponseWriter) Flush() {
+	if w.finished.Load() {
+		w.lateWrites.Add(1)
+	}
+	w.ResponseRecorder.Flush()
+}
+
+// ------------------------------------------------------------------------------------------------------
+func TestStreamTracker_NotifyAfterSSEChatEnded(t *testing.T) {
+	chatService := &blockingChatService{started: make(chan struct{}), release: make(chan struct{})}
+	tracker := NewStreamTracker()
+	handler := NewHandler(chatService, zap.NewNop(), WithStreamTracker(tracker))
+
+	req := httptest.NewRequest(http.MethodPost, "/chat", strings.NewReader(chatRequestBody))
+	req.Header.Set("Accept", "text/event-stream")
+	w := &finishedResponseWriter{ResponseRecorder: httptest.NewRecorder()}
+
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		handler.ChatHandler(w, req)
+	}()
+	<-chatService.started
+
+	// Shutdown starts notifying while the chat is in flight, but the notice only runs once the chat
+	// has ended, as a slow notify goroutine would
+	tracker.mu.Lock()
+	var notify func()
+	for chat := range tracker.chats {
+		notify = chat.notify
+	}
+	tracker.mu.Unlock()
+	if notify == nil {
+		t.Fatal("the SSE chat registered no shutdown notice")
+	}
+
+	close(chatService.release)
+	<-served
+	w.finished.Store(true)
+	notify()
+
+	if n := w.lateWrites.Load(); n != 0 {
+		t.Errorf("%d writes after the handler returned", n)
+	}
+	if strings.Contains(w.Body.String(), "server_shutdown") {
+		t.Errorf("notice sent after the chat ended:\n%s", w.Body.String())
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func TestStreamTracker_CancelsWebSocketChatAwaitingToolResult(t *testing.T) {
+	server, tracker := newDrainServer(t, fakellm.Response{
+		ToolCalls: []fakellm.ToolCall{{ID: "call_1", Name: "get_location", Arguments: "{}"}},
+	})
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+	request := `{"messages":[{"role":"user","content":"Where am I?"}],` +
+		`"tools":[{"type":"function","function":{"name":"get_location"}}]}`
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(request)); err != nil {
+		t.Fatalf("WriteMessage() error = %v", err)
+	}
+
+	// The chat now waits for a tool_result that never comes
+	var frame map[string]any
+	if err := conn.ReadJSON(&frame); err != nil || frame["type"] != wsFrameToolCall {
+		t.Fatalf("first frame = %v, %v; want a tool_call", frame, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := tracker.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() error = %v, want the deadline to be exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed >= forcedStopTimeout {
+		t.Errorf("Shutdown() took %v, the chat ignored its cancellation", elapsed)
+	}
+	if got := tracker.Active(); got != 0 {
+		t.Errorf("Active() = %d after Shutdown, want 0", got)
+	}
+
+	var transcript strings.Builder
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		transcript.Write(data)
+	}
+	if !strings.Contains(transcript.String(), `"service_unavailable"`) {
+		t.Errorf("cancelled chat should end with a service_unavailable error:\n%s", transcript.String())
+	}
+}
@@ -3,23 +3,101 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"time"
 
 	apperror "llm-chat-service/internal/error"
+	"llm-chat-service/internal/health"
+	"llm-chat-service/internal/logging"
+	"llm-chat-service/internal/metrics"
+	"llm-chat-service/internal/rag"
 	"llm-chat-service/internal/service"
 
 	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 )
 
+// Stream types used as the chat_requests_total / chat_active_streams label
+const (
+	streamTypeJSON      = "json"
+	streamTypeSSE       = "sse"
+	streamTypeWebSocket = "websocket"
+)
+
+// defaultClientToolTimeout is how long a WebSocket client has to answer a tool_call frame
+const defaultClientToolTimeout = 30 * time.Second
+
+// defaultMaxDocumentBytes caps knowledge base uploads when not configured
+const defaultMaxDocumentBytes = 10 << 20
+
 type Handler struct {
-	chatService service.ChatService
-	logger      *zap.Logger
-	upgrader    websocket.Upgrader
+	chatService       service.ChatService
+	logger            *zap.Logger
+	upgrader          websocket.Upgrader
+	metricsHandler    http.Handler
+	clientToolTimeout time.Duration
+
+	knowledge        *rag.Service // Can be nil if knowledge bases are disabled
+	maxDocumentBytes int64
+
+	health  *health.Health
+	streams *StreamTracker
+}
+
+// Option configures optional Handler settings
+type Option func(*Handler)
+
+// ------------------------------------------------------------------------------------------------------
+// WithClientToolTimeout sets how long to wait for a WebSocket client's tool_result frame
+func WithClientToolTimeout(timeout time.Duration) Option {
+	return func(h *Handler) {
+		if timeout > 0 {
+			h.clientToolTimeout = timeout
+		}
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// WithKnowledge enables document ingestion into the given service. A nil service disables it.
+func WithKnowledge(knowledge *rag.Service) Option {
+	return func(h *Handler) {
+		h.knowledge = knowledge
+	}
 }
 
 // ------------------------------------------------------------------------------------------------------
-func NewHandler(chatService service.ChatService, logger *zap.Logger) *Handler {
-	return &Handler{
+// WithHealth backs /readyz with the given checks. Without it the service is always reported ready.
+func WithHealth(h *health.Health) Option {
+	return func(handler *Handler) {
+		if h != nil {
+			handler.health = h
+		}
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// WithStreamTracker registers every chat with the given tracker, whose Shutdown drains them
+func WithStreamTracker(tracker *StreamTracker) Option {
+	return func(h *Handler) {
+		if tracker != nil {
+			h.streams = tracker
+		}
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// WithMaxDocumentBytes caps the size of uploaded knowledge base documents
+func WithMaxDocumentBytes(n int64) Option {
+	return func(h *Handler) {
+		if n > 0 {
+			h.maxDocumentBytes = n
+		}
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func NewHandler(chatService service.ChatService, logger *zap.Logger, opts ...Option) *Handler {
+	h := &Handler{
 		chatService: chatService,
 		logger:      logger,
 		upgrader: websocket.Upgrader{
@@ -27,13 +105,23 @@ func NewHandler(chatService service.ChatService, logger *zap.Logger) *Handler {
 				return true
 			},
 		},
+		metricsHandler:    promhttp.Handler(),
+		clientToolTimeout: defaultClientToolTimeout,
+		maxDocumentBytes:  defaultMaxDocumentBytes,
+		health:            health.New(0),
+		streams:           NewStreamTracker(),
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	return h
 }
 
 // ------------------------------------------------------------------------------------------------------
 func (h *Handler) ChatHandler(w http.ResponseWriter, r *http.Request) {
 
 	if r.Header.Get("Upgrade") == "websocket" || r.Header.Get("Connection") == "Upgrade" {
+		metrics.ChatRequestsTotal.WithLabelValues(streamTypeWebSocket).Inc()
 		h.handleWebSocketChat(w, r)
 		return
 	}
@@ -41,10 +129,12 @@ func (h *Handler) ChatHandler(w http.ResponseWriter, r *http.Request) {
 	accept := r.Header.Get("Accept")
 
 	if accept == "text/event-stream" || r.URL.Query().Get("stream") == "true" {
+		metrics.ChatRequestsTotal.WithLabelValues(streamTypeSSE).Inc()
 		h.handleSSEChat(w, r)
 		return
 	}
 
+	metrics.ChatRequestsTotal.WithLabelValues(streamTypeJSON).Inc()
 	h.handleJSONChat(w, r)
 }
 
@@ -52,38 +142,83 @@ func (h *Handler) ChatHandler(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) HealthHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	
+
 	response := "OK"
 
 	if err := json.NewEncoder(w).Encode(response); err != nil {
-		h.logger.Error("Failed to encode health response", zap.Error(err))
+		logging.FromContext(r.Context()).Error("Failed to encode health response", zap.Error(err))
 	}
 }
 
 // ------------------------------------------------------------------------------------------------------
+// LivezHandler reports that the process is running. It checks no dependency, so an outage of Redis or
+// the LLM provider never gets the service restarted.
+func (h *Handler) LivezHandler(w http.ResponseWriter, r *http.Request) {
+	h.writeHealth(w, r, http.StatusOK, health.Report{Status: health.StatusUp})
+}
+
+// ------------------------------------------------------------------------------------------------------
+// ReadyzHandler runs the readiness checks and answers 503 when a critical component is down or the
+// service is shutting down
+func (h *Handler) ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	report := h.health.Check(r.Context())
+
+	statusCode := http.StatusOK
+	if report.Status == health.StatusDown {
+		statusCode = http.StatusServiceUnavailable
+	}
+	h.writeHealth(w, r, statusCode, report)
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (h *Handler) writeHealth(w http.ResponseWriter, r *http.Request, statusCode int, report health.Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		logging.FromContext(r.Context()).Error("Failed to encode health response", zap.Error(err))
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// MetricsHandler exposes the Prometheus registry in text exposition format
 func (h *Handler) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	h.metricsHandler.ServeHTTP(w, r)
+}
 
-	w.Header().Set("Content-Type", "text/plain")
-	w.WriteHeader(http.StatusOK)
-	_, err := w.Write([]byte("# Metrics endpoint\n"))
-	if err != nil {
-		h.logger.Error("Failed to write metrics endpoint", zap.Error(err))
-		return
+// ------------------------------------------------------------------------------------------------------
+// LogLevelHandler reports (GET) or changes (PUT {"level":"debug"}) the global log level at runtime
+func (h *Handler) LogLevelHandler(w http.ResponseWriter, r *http.Request) {
+	previous := logging.Level.Level()
+	logging.Level.ServeHTTP(w, r)
+
+	if current := logging.Level.Level(); current != previous {
+		logging.FromContext(r.Context()).Warn("Log level changed",
+			zap.Stringer("from", previous),
+			zap.Stringer("to", current),
+		)
 	}
 }
 
 // ------------------------------------------------------------------------------------------------------
-func (h *Handler) sendErrorResponse(w http.ResponseWriter, err error) {
+func (h *Handler) sendErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
 	statusCode := apperror.GetHTTPStatusCode(err)
-	errorResponse := apperror.NewErrorResponse(err)
+	errorResponse := newErrorResponse(r, err)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 
 	if encodeErr := json.NewEncoder(w).Encode(errorResponse); encodeErr != nil {
-		h.logger.Error("Failed to encode error response",
+		logging.FromContext(r.Context()).Error("Failed to encode error response",
 			zap.Error(encodeErr),
 			zap.Error(err),
 		)
 	}
 }
+
+// ------------------------------------------------------------------------------------------------------
+// newErrorResponse builds the error body, tagging it with the request ID when one is known
+func newErrorResponse(r *http.Request, err error) apperror.ErrorResponse {
+	return apperror.NewErrorResponseWithRequestID(err, logging.RequestIDFromContext(r.Context()))
+}
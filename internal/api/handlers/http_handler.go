@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/json"
 	apperror "llm-chat-service/internal/error"
+	"llm-chat-service/internal/logging"
 	"llm-chat-service/internal/service"
 	"net/http"
 
@@ -16,23 +17,33 @@ func (h *Handler) handleJSONChat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	logger := logging.FromContext(r.Context())
+
 	var req service.ChatRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.logger.Error("Failed to decode request", zap.Error(err))
-		h.sendErrorResponse(w, apperror.NewValidationError("Invalid JSON in request body", err))
+		logger.Error("Failed to decode request", zap.Error(err))
+		h.sendErrorResponse(w, r, apperror.NewValidationError("Invalid JSON in request body", err))
+		return
+	}
+
+	ctx, end, ok := h.streams.begin(r.Context(), nil)
+	if !ok {
+		h.sendErrorResponse(w, r, drainingError())
 		return
 	}
+	defer end()
 
-	response, err := h.chatService.ProcessChat(&req)
+	response, err := h.chatService.ProcessChat(ctx, &req)
 	if err != nil {
-		h.logger.Error("Chat processing failed", zap.Error(err))
-		h.sendErrorResponse(w, err)
+		err = shutdownError(ctx, err)
+		logger.Error("Chat processing failed", zap.Error(err))
+		h.sendErrorResponse(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	if encodeErr := json.NewEncoder(w).Encode(map[string]string{"response": response}); encodeErr != nil {
-		h.logger.Error("Failed to encode response", zap.Error(encodeErr))
+	if encodeErr := json.NewEncoder(w).Encode(response); encodeErr != nil {
+		logger.Error("Failed to encode response", zap.Error(encodeErr))
 	}
 }
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	apperror "llm-chat-service/internal/error"
+	"llm-chat-service/internal/logging"
+	"llm-chat-service/internal/rag"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+)
+
+// ------------------------------------------------------------------------------------------------------
+// IngestDocumentHandler adds a document to the knowledge base named in the path. The document is either
+// the raw request body (source and document_id taken from the query) or the "file" part of a
+// multipart form (source defaults to the file name).
+func (h *Handler) IngestDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	if h.knowledge == nil {
+		h.sendErrorResponse(w, r, apperror.NewNotFoundError("knowledge bases are not enabled on this server", nil))
+		return
+	}
+
+	logger := logging.FromContext(r.Context())
+	r.Body = http.MaxBytesReader(w, r.Body, h.maxDocumentBytes)
+
+	doc, data, err := readDocument(r)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			err = apperror.NewValidationError("document exceeds the maximum upload size", err)
+		}
+		logger.Warn("Failed to read document", zap.Error(err))
+		h.sendErrorResponse(w, r, err)
+		return
+	}
+
+	doc.Text, err = rag.ExtractText(doc.Format, data)
+	if err != nil {
+		h.sendErrorResponse(w, r, apperror.NewValidationError("failed to extract document text", err))
+		return
+	}
+
+	result, err := h.knowledge.Ingest(r.Context(), mux.Vars(r)["name"], doc)
+	if err != nil {
+		logger.Error("Document ingestion failed", zap.Error(err))
+		h.sendErrorResponse(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if encodeErr := json.NewEncoder(w).Encode(result); encodeErr != nil {
+		logger.Error("Failed to encode response", zap.Error(encodeErr))
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// readDocument returns the uploaded document's metadata and raw content
+func readDocument(r *http.Request) (rag.Document, []byte, error) {
+	doc := rag.Document{
+		ID:     r.URL.Query().Get("document_id"),
+		Source: r.URL.Query().Get("source"),
+	}
+
+	var body io.Reader = r.Body
+	contentType := r.Header.Get("Content-Type")
+
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "multipart/form-data" {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				return doc, nil, err
+			}
+			return doc, nil, apperror.NewValidationError("multipart upload must contain a 'file' part", err)
+		}
+		defer file.Close()
+
+		body = file
+		contentType = header.Header.Get("Content-Type")
+		if doc.Source == "" {
+			doc.Source = header.Filename
+		}
+		if id := r.FormValue("document_id"); id != "" {
+			doc.ID = id
+		}
+	}
+
+	format, err := rag.DetectFormat(contentType, doc.Source)
+	if err != nil {
+		return doc, nil, apperror.NewValidationError(err.Error(), nil)
+	}
+	doc.Format = format
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return doc, nil, err
+	}
+	return doc, data, nil
+}
@@ -4,8 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	apperror "llm-chat-service/internal/error"
+	"llm-chat-service/internal/logging"
+	"llm-chat-service/internal/metrics"
 	"llm-chat-service/internal/service"
 	"net/http"
+	"sync"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -18,15 +22,29 @@ func (h *Handler) handleSSEChat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	logger := logging.FromContext(r.Context())
+
 	var req service.ChatRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.logger.Error("Failed to decode request", zap.Error(err))
-		h.sendErrorResponse(w, apperror.NewValidationError("Invalid JSON in request body", err))
+		logger.Error("Failed to decode request", zap.Error(err))
+		h.sendErrorResponse(w, r, apperror.NewValidationError("Invalid JSON in request body", err))
 		return
 	}
 
 	req.Stream = true
 
+	// Refuse with a 503 while that is still possible; once the stream headers are out, errors are events
+	if h.streams.isDraining() {
+		h.sendErrorResponse(w, r, drainingError())
+		return
+	}
+
+	// A turn may chain several upstream calls through tool calls, repairs and continuations, so the
+	// server's write timeout, meant for ordinary responses, must not cut the stream short
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		logger.Warn("Failed to lift the write deadline of the stream", zap.Error(err))
+	}
+
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
@@ -36,49 +54,96 @@ func (h *Handler) handleSSEChat(w http.ResponseWriter, r *http.Request) {
 		flusher.Flush()
 	}
 
-	_, err := h.chatService.ProcessChatStream(&req, func(token string) error {
-
-		// Write SSE format: "data: token\n\n"
-		data := fmt.Sprintf("data: %s\n\n", token)
-		if _, err := w.Write([]byte(data)); err != nil {
+	// Writes are serialized because the shutdown notice is sent from another goroutine, which may run
+	// after the handler has returned: done turns it into a no-op once w must no longer be used
+	var mu sync.Mutex
+	done := false
+	send := func(format string, args ...any) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if done {
+			return errStreamClosed
+		}
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
 			return err
 		}
 		if flusher, ok := w.(http.Flusher); ok {
 			flusher.Flush()
 		}
 		return nil
+	}
+	defer func() {
+		mu.Lock()
+		done = true
+		mu.Unlock()
+	}()
+
+	ctx, end, ok := h.streams.begin(r.Context(), func() {
+		noticeJSON, _ := json.Marshal(map[string]string{"message": shutdownNotice})
+		_ = send("event: server_shutdown\ndata: %s\n\n", noticeJSON)
+	})
+	if !ok {
+		errorJSON, _ := json.Marshal(newErrorResponse(r, drainingError()))
+		_ = send("data: %s\n\n", errorJSON)
+		return
+	}
+	defer end()
+
+	activeStreams := metrics.ActiveStreams.WithLabelValues(streamTypeSSE)
+	activeStreams.Inc()
+	defer activeStreams.Dec()
+
+	// Candidate tokens are a named event carrying the candidate index (n > 1)
+	req.OnCandidateToken = func(index int, token string) error {
+		tokenJSON, _ := json.Marshal(map[string]any{"index": index, "token": token})
+		return send("event: candidate\ndata: %s\n\n", tokenJSON)
+	}
+
+	response, err := h.chatService.ProcessChatStream(ctx, &req, func(token string) error {
+
+		// Write SSE format: "data: token\n\n"
+		return send("data: %s\n\n", token)
 	})
 
 	if err != nil {
-		h.logger.Error("Streaming failed", zap.Error(err))
+		err = shutdownError(ctx, err)
+		logger.Error("Streaming failed", zap.Error(err))
 
-		errorResponse := apperror.NewErrorResponse(err)
+		errorResponse := newErrorResponse(r, err)
 		errorJSON, _ := json.Marshal(errorResponse)
 
-		errorMsg := fmt.Sprintf("data: %s\n\n", string(errorJSON))
+		if err := send("data: %s\n\n", errorJSON); err != nil {
+			logger.Error("Failed to write error message", zap.Error(err))
+		}
+		return
+	}
 
-		_, err = w.Write([]byte(errorMsg))
-		if err != nil {
-			h.logger.Error("Failed to write error message", zap.Error(err))
+	// Citations are a named event so clients reading only unnamed data events are unaffected
+	if len(response.Citations) > 0 {
+		citationsJSON, _ := json.Marshal(map[string]any{"citations": response.Citations})
+		if err := send("event: citations\ndata: %s\n\n", citationsJSON); err != nil {
+			logger.Error("Failed to write citations", zap.Error(err))
 			return
 		}
+	}
 
-		if flusher, ok := w.(http.Flusher); ok {
-			flusher.Flush()
+	if len(response.Warnings) > 0 {
+		warningsJSON, _ := json.Marshal(map[string]any{"warnings": response.Warnings})
+		if err := send("event: warnings\ndata: %s\n\n", warningsJSON); err != nil {
+			logger.Error("Failed to write warnings", zap.Error(err))
+			return
 		}
-
-		return
 	}
 
-	// Send completion marker
-	_, err = w.Write([]byte("data: [DONE]\n\n"))
-	if err != nil {
-		h.logger.Error("Failed to write completion marker", zap.Error(err))
+	// The usage event always comes last so clients can tell a complete answer from a truncated one
+	usageJSON, _ := json.Marshal(response.ResponseMetadata)
+	if err := send("event: usage\ndata: %s\n\n", usageJSON); err != nil {
+		logger.Error("Failed to write usage", zap.Error(err))
 		return
 	}
 
-	if flusher, ok := w.(http.Flusher); ok {
-		flusher.Flush()
+	// Send completion marker
+	if err := send("data: [DONE]\n\n"); err != nil {
+		logger.Error("Failed to write completion marker", zap.Error(err))
 	}
-
 }
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	apperror "llm-chat-service/internal/error"
+	"llm-chat-service/internal/logging"
+	"llm-chat-service/internal/service"
+	"llm-chat-service/internal/storage"
+
+	"go.uber.org/zap"
+)
+
+// maxTokenizeBodyBytes bounds the messages a client may ask to count
+const maxTokenizeBodyBytes = 1 << 20
+
+// tokenizeRequest lists the messages to count, for the server's model unless another is named
+type tokenizeRequest struct {
+	Model    string            `json:"model,omitempty"`
+	Messages []storage.Message `json:"messages"`
+}
+
+// ------------------------------------------------------------------------------------------------------
+// TokenizeHandler counts the prompt tokens of a list of messages, so clients can check a prompt fits
+// before sending it
+func (h *Handler) TokenizeHandler(w http.ResponseWriter, r *http.Request) {
+	counter, ok := h.chatService.(service.TokenCounter)
+	if !ok {
+		h.sendErrorResponse(w, r, apperror.NewNotFoundError("token counting is not available on this server", nil))
+		return
+	}
+
+	logger := logging.FromContext(r.Context())
+	r.Body = http.MaxBytesReader(w, r.Body, maxTokenizeBodyBytes)
+
+	var req tokenizeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			h.sendErrorResponse(w, r, apperror.NewValidationError("request body exceeds 1 MiB", err))
+			return
+		}
+		h.sendErrorResponse(w, r, apperror.NewValidationError("Invalid JSON in request body", err))
+		return
+	}
+	if len(req.Messages) == 0 {
+		h.sendErrorResponse(w, r, apperror.NewValidationError("messages cannot be empty", nil))
+		return
+	}
+
+	count, err := counter.CountTokens(r.Context(), req.Model, req.Messages)
+	if err != nil {
+		logger.Error("Token counting failed", zap.Error(err))
+		h.sendErrorResponse(w, r, apperror.NewInternalError("failed to count tokens", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if encodeErr := json.NewEncoder(w).Encode(count); encodeErr != nil {
+		logger.Error("Failed to encode response", zap.Error(encodeErr))
+	}
+}
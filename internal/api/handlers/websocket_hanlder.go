@@ -1,26 +1,113 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	apperror "llm-chat-service/internal/error"
+	"llm-chat-service/internal/llm"
+	"llm-chat-service/internal/logging"
+	"llm-chat-service/internal/metrics"
 	"llm-chat-service/internal/service"
+	"net"
 	"net/http"
+	"sync"
+	"time"
 
+	"github.com/gorilla/websocket"
 	"go.uber.org/zap"
 )
 
+// WebSocket frame types used for client-executed tools
+const (
+	wsFrameToolCall   = "tool_call"
+	wsFrameToolResult = "tool_result"
+	wsFrameCitations  = "citations"
+	wsFrameWarnings   = "warnings"
+	wsFrameUsage      = "usage"
+
+	wsFrameServerShutdown = "server_shutdown"
+)
+
+// wsToolCallFrame asks the client to execute one of the tools it declared
+type wsToolCallFrame struct {
+	Type     string       `json:"type"`
+	ToolCall llm.ToolCall `json:"tool_call"`
+}
+
+// wsCitationsFrame lists the knowledge base excerpts the answer was grounded in
+type wsCitationsFrame struct {
+	Type      string             `json:"type"`
+	Citations []service.Citation `json:"citations"`
+}
+
+// wsWarningsFrame reports suspicious content that did not stop the turn
+type wsWarningsFrame struct {
+	Type     string            `json:"type"`
+	Warnings []service.Warning `json:"warnings"`
+}
+
+// wsCandidateTokenFrame carries a token of one of several candidate answers (n > 1)
+type wsCandidateTokenFrame struct {
+	Index int    `json:"index"`
+	Token string `json:"token"`
+}
+
+// wsUsageFrame reports how the answer was generated, right before the done frame
+type wsUsageFrame struct {
+	Type string `json:"type"`
+	service.ResponseMetadata
+}
+
+// wsShutdownFrame tells the client the server is draining
+type wsShutdownFrame struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// wsConn serializes writes, which gorilla/websocket does not allow from several goroutines
+type wsConn struct {
+	*websocket.Conn
+	mu sync.Mutex
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (c *wsConn) WriteJSON(v any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Conn.WriteJSON(v)
+}
+
+// wsClientFrame is a frame sent by the client after the initial request
+type wsClientFrame struct {
+	Type       string `json:"type"`
+	ToolCallID string `json:"tool_call_id"`
+	Content    string `json:"content"`
+	Error      string `json:"error,omitempty"`
+}
+
 func (h *Handler) handleWebSocketChat(w http.ResponseWriter, r *http.Request) {
-	conn, err := h.upgrader.Upgrade(w, r, nil)
+	logger := logging.FromContext(r.Context())
+
+	// The upgrader writes its own handshake response, so headers set by middleware must be passed explicitly
+	responseHeader := http.Header{}
+	if requestID := logging.RequestIDFromContext(r.Context()); requestID != "" {
+		responseHeader.Set("X-Request-ID", requestID)
+	}
+
+	upgraded, err := h.upgrader.Upgrade(w, r, responseHeader)
 	if err != nil {
-		h.logger.Error("WebSocket upgrade failed", zap.Error(err))
+		logger.Error("WebSocket upgrade failed", zap.Error(err))
 		return
 	}
-	defer conn.Close()
+	defer upgraded.Close()
+	conn := &wsConn{Conn: upgraded}
 
 	var req service.ChatRequest
 	if err := conn.ReadJSON(&req); err != nil {
-		h.logger.Error("Failed to read WebSocket message", zap.Error(err))
+		logger.Error("Failed to read WebSocket message", zap.Error(err))
 
-		errorResponse := apperror.NewErrorResponse(
+		errorResponse := newErrorResponse(r,
 			apperror.NewValidationError("Failed to read WebSocket message: invalid JSON", err),
 		)
 
@@ -28,23 +115,111 @@ func (h *Handler) handleWebSocketChat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ctx, end, ok := h.streams.begin(r.Context(), func() {
+		_ = conn.WriteJSON(wsShutdownFrame{Type: wsFrameServerShutdown, Message: shutdownNotice})
+	})
+	if !ok {
+		_ = conn.WriteJSON(newErrorResponse(r, drainingError()))
+		return
+	}
+	defer end()
+
 	req.Stream = true
+	req.ClientToolExecutor = h.clientToolExecutor(conn)
+	req.OnCandidateToken = func(index int, token string) error {
+		return conn.WriteJSON(wsCandidateTokenFrame{Index: index, Token: token})
+	}
 
-	_, err = h.chatService.ProcessChatStream(&req, func(token string) error {
+	activeStreams := metrics.ActiveStreams.WithLabelValues(streamTypeWebSocket)
+	activeStreams.Inc()
+	defer activeStreams.Dec()
+
+	response, err := h.chatService.ProcessChatStream(ctx, &req, func(token string) error {
 		message := map[string]string{"token": token}
 		return conn.WriteJSON(message)
 	})
 
 	if err != nil {
-		h.logger.Error("WebSocket streaming failed", zap.Error(err))
-		errorResponse := apperror.NewErrorResponse(err)
+		err = shutdownError(ctx, err)
+		logger.Error("WebSocket streaming failed", zap.Error(err))
+		errorResponse := newErrorResponse(r, err)
 		_ = conn.WriteJSON(errorResponse)
 		return
 	}
 
+	if len(response.Citations) > 0 {
+		if err := conn.WriteJSON(wsCitationsFrame{Type: wsFrameCitations, Citations: response.Citations}); err != nil {
+			logger.Error("Failed to write citations", zap.Error(err))
+			return
+		}
+	}
+
+	if len(response.Warnings) > 0 {
+		if err := conn.WriteJSON(wsWarningsFrame{Type: wsFrameWarnings, Warnings: response.Warnings}); err != nil {
+			logger.Error("Failed to write warnings", zap.Error(err))
+			return
+		}
+	}
+
+	if err := conn.WriteJSON(wsUsageFrame{Type: wsFrameUsage, ResponseMetadata: response.ResponseMetadata}); err != nil {
+		logger.Error("Failed to write usage", zap.Error(err))
+		return
+	}
+
 	err = conn.WriteJSON(map[string]string{"done": "true"})
 	if err != nil {
-		h.logger.Error("Failed to write done message", zap.Error(err))
+		logger.Error("Failed to write done message", zap.Error(err))
 		return
 	}
 }
+
+// ------------------------------------------------------------------------------------------------------
+// clientToolExecutor sends a tool_call frame and blocks until the matching tool_result frame arrives
+// or the client tool timeout expires. Reads only happen here, after the initial request has been read.
+// Any failed read or write leaves the connection unusable, so transport failures and timeouts end the
+// turn; only an error reported by the client in its tool_result goes back to the model.
+func (h *Handler) clientToolExecutor(conn *wsConn) service.ClientToolExecutor {
+	return func(ctx context.Context, call llm.ToolCall) (string, error) {
+		if err := conn.WriteJSON(wsToolCallFrame{Type: wsFrameToolCall, ToolCall: call}); err != nil {
+			return "", fmt.Errorf("failed to send tool call to client: %w: %w", service.ErrClientToolUnavailable, err)
+		}
+
+		deadline := time.Now().Add(h.clientToolTimeout)
+		if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+			deadline = ctxDeadline
+		}
+		if err := conn.SetReadDeadline(deadline); err != nil {
+			return "", fmt.Errorf("%w: %w", service.ErrClientToolUnavailable, err)
+		}
+		defer conn.SetReadDeadline(time.Time{})
+
+		// Cancellation without a deadline, e.g. by the shutdown drain, unblocks the read through an
+		// expired read deadline
+		stop := context.AfterFunc(ctx, func() { conn.SetReadDeadline(time.Now()) })
+		defer stop()
+
+		for {
+			var frame wsClientFrame
+			if err := conn.ReadJSON(&frame); err != nil {
+				if ctx.Err() != nil {
+					return "", fmt.Errorf("tool %q cancelled while waiting for the client: %w: %w", call.Function.Name, service.ErrClientToolUnavailable, context.Cause(ctx))
+				}
+				var netErr net.Error
+				if errors.As(err, &netErr) && netErr.Timeout() {
+					return "", fmt.Errorf("no result for tool %q within %v: %w", call.Function.Name, h.clientToolTimeout, service.ErrClientToolTimeout)
+				}
+				return "", fmt.Errorf("failed to read tool result from client: %w: %w", service.ErrClientToolUnavailable, err)
+			}
+
+			// Ignore frames that do not answer this call
+			if frame.Type != wsFrameToolResult || frame.ToolCallID != call.ID {
+				continue
+			}
+
+			if frame.Error != "" {
+				return "", errors.New(frame.Error)
+			}
+			return frame.Content, nil
+		}
+	}
+}
@@ -2,16 +2,111 @@ package api
 
 import (
 	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	apperror "llm-chat-service/internal/error"
+	"llm-chat-service/internal/logging"
+	"llm-chat-service/internal/metrics"
+	"llm-chat-service/internal/tracing"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
+// Correlation headers accepted from clients
+const (
+	HeaderRequestID      = "X-Request-ID"
+	HeaderConversationID = "X-Conversation-ID"
+	HeaderTenantID       = "X-Tenant-ID"
+)
+
+// maxRequestIDLength bounds client-supplied request IDs so they cannot bloat logs
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware accepts or generates an X-Request-ID, echoes it in the response and
+// attaches a request-scoped logger carrying the correlation fields to the request context
+func RequestIDMiddleware(logger *zap.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(HeaderRequestID)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set(HeaderRequestID, requestID)
+
+		ctx := logging.WithRequestID(r.Context(), requestID)
+		fields := []zap.Field{zap.String(logging.FieldRequestID, requestID)}
+		if conversationID := r.Header.Get(HeaderConversationID); conversationID != "" {
+			ctx = logging.WithConversationID(ctx, conversationID)
+			fields = append(fields, zap.String(logging.FieldConversationID, conversationID))
+		}
+		if tenant := r.Header.Get(HeaderTenantID); tenant != "" {
+			ctx = logging.WithTenant(ctx, tenant)
+			fields = append(fields, zap.String(logging.FieldTenant, tenant))
+		}
+		ctx = logging.WithLogger(ctx, logger.With(fields...))
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// validRequestID reports whether a client-supplied ID is safe to reuse
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random 128-bit hex identifier
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// AdminAuthMiddleware requires "Authorization: Bearer <token>" matching the configured admin token
+func AdminAuthMiddleware(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			logging.FromContext(r.Context()).Warn("Rejected admin request", zap.String("path", r.URL.Path))
+
+			errorResponse := apperror.NewErrorResponseWithRequestID(
+				apperror.NewUnauthorizedError("invalid or missing admin token", nil),
+				logging.RequestIDFromContext(r.Context()),
+			)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(errorResponse)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 // LoggingMiddleware logs HTTP requests
-func LoggingMiddleware(logger *zap.Logger, next http.Handler) http.Handler {
+func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 
@@ -25,7 +120,7 @@ func LoggingMiddleware(logger *zap.Logger, next http.Handler) http.Handler {
 
 		duration := time.Since(start)
 
-		logger.Info("HTTP request",
+		logging.FromContext(r.Context()).Info("HTTP request",
 			zap.String("method", r.Method),
 			zap.String("path", r.URL.Path),
 			zap.String("ip", r.RemoteAddr),
@@ -35,6 +130,64 @@ func LoggingMiddleware(logger *zap.Logger, next http.Handler) http.Handler {
 	})
 }
 
+// MetricsMiddleware records request counts and latencies labelled by the matched route template
+func MetricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		wrapped := &responseWriter{
+			ResponseWriter: w,
+			statusCode:     http.StatusOK,
+		}
+
+		next.ServeHTTP(wrapped, r)
+
+		endpoint := routeTemplate(r)
+		metrics.HTTPRequestsTotal.WithLabelValues(r.Method, endpoint, strconv.Itoa(wrapped.statusCode)).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(r.Method, endpoint).Observe(time.Since(start).Seconds())
+	})
+}
+
+// TracingMiddleware starts a server span per request, continuing any incoming W3C trace context
+func TracingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		route := routeTemplate(r)
+		ctx, span := tracing.StartSpan(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.HTTPRoute(route),
+			),
+		)
+		defer span.End()
+
+		wrapped := &responseWriter{
+			ResponseWriter: w,
+			statusCode:     http.StatusOK,
+		}
+
+		next.ServeHTTP(wrapped, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(wrapped.statusCode))
+		if wrapped.statusCode >= http.StatusInternalServerError {
+			tracing.RecordError(span, fmt.Errorf("HTTP %d", wrapped.statusCode))
+		}
+	})
+}
+
+// routeTemplate returns the mux path template so that metric labels stay low-cardinality.
+// Requests that matched no route are labelled "unmatched".
+func routeTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+	return "unmatched"
+}
+
 // responseWriter wraps http.ResponseWriter to capture status code
 type responseWriter struct {
 	http.ResponseWriter
@@ -46,6 +199,19 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Flush implements http.Flusher so SSE responses are not buffered by the wrapper
+func (rw *responseWriter) Flush() {
+	if flusher, ok := rw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to lift the write deadline
+// of SSE responses
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // Hijack implements http.Hijacker interface for WebSocket support
 func (rw *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
 	hijacker, ok := rw.ResponseWriter.(http.Hijacker)
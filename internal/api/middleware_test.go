@@ -0,0 +1,378 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"llm-chat-service/internal/api/handlers"
+	"llm-chat-service/internal/logging"
+	"llm-chat-service/internal/metrics"
+	"llm-chat-service/internal/service"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const testAdminToken = "s3cret"
+
+// stubChatService answers every chat with a fixed response
+type stubChatService struct{}
+
+func (stubChatService) ProcessChat(ctx context.Context, req *service.ChatRequest) (*service.ChatResponse, error) {
+	return &service.ChatResponse{Content: "Hello"}, nil
+}
+
+func (stubChatService) ProcessChatStream(ctx context.Context, req *service.ChatRequest, onToken func(string) error) (*service.ChatResponse, error) {
+	if err := onToken("Hello"); err != nil {
+		return nil, err
+	}
+	return &service.ChatResponse{Content: "Hello"}, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// newTestRouter serves the full router, middleware included, in front of a stub chat service
+func newTestRouter(t *testing.T, adminToken string) *httptest.Server {
+	t.Helper()
+	handler := handlers.NewHandler(stubChatService{}, zap.NewNop())
+	server := httptest.NewServer(SetupRouter(handler, zap.NewNop(), adminToken))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// ------------------------------------------------------------------------------------------------------
+// doRequest sends a request with the given headers and returns the response with its body read
+func doRequest(t *testing.T, method, url, body string, headers map[string]string) (*http.Response, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s error = %v", method, url, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read body: %v", err)
+	}
+	return resp, string(data)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// restoreLogLevel puts the global log level back once the test is done
+func restoreLogLevel(t *testing.T) {
+	level := logging.Level.Level()
+	t.Cleanup(func() { logging.Level.SetLevel(level) })
+}
+
+func TestAdminAuthMiddleware(t *testing.T) {
+	server := newTestRouter(t, testAdminToken)
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantStatus    int
+	}{
+		{name: "bearer token", authorization: "Bearer " + testAdminToken, wantStatus: http.StatusOK},
+		{name: "missing header", wantStatus: http.StatusUnauthorized},
+		{name: "wrong token", authorization: "Bearer nope", wantStatus: http.StatusUnauthorized},
+		{name: "token without scheme", authorization: testAdminToken, wantStatus: http.StatusUnauthorized},
+		{name: "other scheme", authorization: "Basic " + testAdminToken, wantStatus: http.StatusUnauthorized},
+		{name: "lowercase scheme", authorization: "bearer " + testAdminToken, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{}
+			if tt.authorization != "" {
+				headers["Authorization"] = tt.authorization
+			}
+			resp, body := doRequest(t, http.MethodGet, server.URL+"/admin/log-level", "", headers)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tt.wantStatus, body)
+			}
+			if tt.wantStatus != http.StatusUnauthorized {
+				return
+			}
+
+			var errorResponse struct {
+				Error struct {
+					Type      string `json:"type"`
+					RequestID string `json:"request_id"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal([]byte(body), &errorResponse); err != nil {
+				t.Fatalf("failed to decode error body %q: %v", body, err)
+			}
+			if errorResponse.Error.Type != "unauthorized_error" || errorResponse.Error.RequestID != resp.Header.Get(HeaderRequestID) {
+				t.Errorf("error body = %s, want an unauthorized error tagged with the request ID", body)
+			}
+		})
+	}
+}
+
+func TestAdminRoutesDisabledWithoutToken(t *testing.T) {
+	server := newTestRouter(t, "")
+
+	resp, _ := doRequest(t, http.MethodGet, server.URL+"/admin/log-level", "", map[string]string{
+		"Authorization": "Bearer ",
+	})
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want 404 without an admin token", resp.StatusCode)
+	}
+}
+
+func TestLogLevelHandler(t *testing.T) {
+	restoreLogLevel(t)
+	logging.Level.SetLevel(zapcore.InfoLevel)
+	server := newTestRouter(t, testAdminToken)
+	auth := map[string]string{"Authorization": "Bearer " + testAdminToken}
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+		wantLevel  zapcore.Level
+	}{
+		{name: "get", method: http.MethodGet, wantStatus: http.StatusOK, wantLevel: zapcore.InfoLevel},
+		{name: "set debug", method: http.MethodPut, body: `{"level":"debug"}`, wantStatus: http.StatusOK, wantLevel: zapcore.DebugLevel},
+		{name: "unknown level", method: http.MethodPut, body: `{"level":"loud"}`, wantStatus: http.StatusBadRequest, wantLevel: zapcore.DebugLevel},
+		{name: "set warn", method: http.MethodPut, body: `{"level":"warn"}`, wantStatus: http.StatusOK, wantLevel: zapcore.WarnLevel},
+		{name: "unsupported method", method: http.MethodPost, wantStatus: http.StatusMethodNotAllowed, wantLevel: zapcore.WarnLevel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := doRequest(t, tt.method, server.URL+"/admin/log-level", tt.body, auth)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", resp.StatusCode, tt.wantStatus, body)
+			}
+			if got := logging.Level.Level(); got != tt.wantLevel {
+				t.Errorf("level = %v, want %v", got, tt.wantLevel)
+			}
+			if tt.wantStatus == http.StatusOK && !strings.Contains(body, `"level":"`+tt.wantLevel.String()+`"`) {
+				t.Errorf("body = %s, want the current level", body)
+			}
+		})
+	}
+}
+
+func TestValidateLoggingOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    logging.Options
+		wantErr bool
+	}{
+		{name: "defaults", opts: logging.Options{}},
+		{name: "valid", opts: logging.Options{Level: "debug", Format: logging.FormatConsole, SamplingInitial: 100, SamplingThereafter: 10}},
+		{name: "unknown level", opts: logging.Options{Level: "loud"}, wantErr: true},
+		{name: "unknown format", opts: logging.Options{Format: "xml"}, wantErr: true},
+		{name: "negative sampling", opts: logging.Options{SamplingThereafter: -1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := logging.ValidateOptions(tt.opts); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// generatedRequestID matches the IDs the middleware generates
+var generatedRequestID = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	server := newTestRouter(t, "")
+
+	tests := []struct {
+		name      string
+		requestID string
+		wantEcho  bool
+	}{
+		{name: "missing", wantEcho: false},
+		{name: "valid", requestID: "client-req-42", wantEcho: true},
+		{name: "max length", requestID: strings.Repeat("a", maxRequestIDLength), wantEcho: true},
+		{name: "oversized", requestID: strings.Repeat("a", maxRequestIDLength+1), wantEcho: false},
+		{name: "space", requestID: "two words", wantEcho: false},
+		{name: "non-ASCII", requestID: "req-é", wantEcho: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{}
+			if tt.requestID != "" {
+				headers[HeaderRequestID] = tt.requestID
+			}
+			resp, body := doRequest(t, http.MethodGet, server.URL+"/health", "", headers)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d: %s", resp.StatusCode, body)
+			}
+
+			got := resp.Header.Get(HeaderRequestID)
+			if tt.wantEcho && got != tt.requestID {
+				t.Errorf("%s = %q, want the incoming %q", HeaderRequestID, got, tt.requestID)
+			}
+			if !tt.wantEcho && !generatedRequestID.MatchString(got) {
+				t.Errorf("%s = %q, want a generated ID", HeaderRequestID, got)
+			}
+		})
+	}
+}
+
+func TestRequestIDMiddleware_ErrorBody(t *testing.T) {
+	server := newTestRouter(t, "")
+
+	tests := []struct {
+		name      string
+		requestID string
+	}{
+		{name: "incoming", requestID: "client-req-42"},
+		{name: "generated"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{"Content-Type": "application/json"}
+			if tt.requestID != "" {
+				headers[HeaderRequestID] = tt.requestID
+			}
+			resp, body := doRequest(t, http.MethodPost, server.URL+"/chat", "{not json", headers)
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400: %s", resp.StatusCode, body)
+			}
+
+			var errorResponse struct {
+				Error struct {
+					RequestID string `json:"request_id"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal([]byte(body), &errorResponse); err != nil {
+				t.Fatalf("failed to decode error body %q: %v", body, err)
+			}
+			header := resp.Header.Get(HeaderRequestID)
+			if errorResponse.Error.RequestID == "" || errorResponse.Error.RequestID != header {
+				t.Errorf("error request_id = %q, header = %q, want them equal", errorResponse.Error.RequestID, header)
+			}
+			if tt.requestID != "" && header != tt.requestID {
+				t.Errorf("%s = %q, want %q", HeaderRequestID, header, tt.requestID)
+			}
+		})
+	}
+}
+
+func TestRequestIDMiddleware_WebSocketUpgrade(t *testing.T) {
+	server := newTestRouter(t, "")
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/chat"
+
+	tests := []struct {
+		name      string
+		requestID string
+		wantEcho  bool
+	}{
+		{name: "incoming", requestID: "client-req-42", wantEcho: true},
+		{name: "invalid", requestID: strings.Repeat("a", maxRequestIDLength+1), wantEcho: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, resp, err := websocket.DefaultDialer.Dial(url, http.Header{HeaderRequestID: {tt.requestID}})
+			if err != nil {
+				t.Fatalf("Dial() error = %v", err)
+			}
+			defer conn.Close()
+
+			got := resp.Header.Get(HeaderRequestID)
+			if tt.wantEcho && got != tt.requestID {
+				t.Errorf("handshake %s = %q, want %q", HeaderRequestID, got, tt.requestID)
+			}
+			if !tt.wantEcho && !generatedRequestID.MatchString(got) {
+				t.Errorf("handshake %s = %q, want a generated ID", HeaderRequestID, got)
+			}
+		})
+	}
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	server := newTestRouter(t, "")
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		endpoint string
+		status   string
+	}{
+		{name: "ok", method: http.MethodGet, path: "/health", endpoint: "/health", status: "200"},
+		{name: "client error", method: http.MethodPost, path: "/chat", body: "{not json", endpoint: "/chat", status: "400"},
+		{
+			// Path variables are collapsed into the route template
+			name:     "route template",
+			method:   http.MethodPost,
+			path:     "/knowledge-bases/handbook/documents",
+			body:     "text",
+			endpoint: "/knowledge-bases/{name}/documents",
+			status:   "404",
+		},
+		// Requests matching no route share one label instead of their raw path
+		{name: "unknown path", method: http.MethodGet, path: "/no-such-route", endpoint: "unmatched", status: "404"},
+		{name: "method not allowed", method: http.MethodDelete, path: "/health", endpoint: "unmatched", status: "405"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := metrics.HTTPRequestsTotal.WithLabelValues(tt.method, tt.endpoint, tt.status)
+			before := testutil.ToFloat64(requests)
+			samplesBefore := histogramSamples(t, tt.method, tt.endpoint)
+
+			resp, body := doRequest(t, tt.method, server.URL+tt.path, tt.body, nil)
+			if got := strconv.Itoa(resp.StatusCode); got != tt.status {
+				t.Fatalf("status = %s, want %s: %s", got, tt.status, body)
+			}
+
+			if got := testutil.ToFloat64(requests) - before; got != 1 {
+				t.Errorf("http_requests_total{%s,%s,%s} grew by %v, want 1", tt.method, tt.endpoint, tt.status, got)
+			}
+			if got := histogramSamples(t, tt.method, tt.endpoint) - samplesBefore; got != 1 {
+				t.Errorf("http_request_duration_seconds{%s,%s} grew by %d samples, want 1", tt.method, tt.endpoint, got)
+			}
+			if tt.path != tt.endpoint {
+				if got := testutil.ToFloat64(metrics.HTTPRequestsTotal.WithLabelValues(tt.method, tt.path, tt.status)); got != 0 {
+					t.Errorf("requests recorded under the raw path %s", tt.path)
+				}
+			}
+		})
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// histogramSamples returns how many durations were observed for method and endpoint
+func histogramSamples(t *testing.T, method, endpoint string) uint64 {
+	t.Helper()
+
+	var metric dto.Metric
+	histogram := metrics.HTTPRequestDuration.WithLabelValues(method, endpoint).(prometheus.Metric)
+	if err := histogram.Write(&metric); err != nil {
+		t.Fatalf("failed to read the duration histogram: %v", err)
+	}
+	return metric.GetHistogram().GetSampleCount()
+}
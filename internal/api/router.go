@@ -4,60 +4,59 @@ import (
 	"net/http"
 
 	"llm-chat-service/internal/api/handlers"
+	"llm-chat-service/internal/metrics"
 
 	"github.com/gorilla/mux"
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 )
 
-// SetupRouter configures HTTP routes
-func SetupRouter(handler *handlers.Handler, logger *zap.Logger) *mux.Router {
+// SetupRouter configures HTTP routes. Admin routes are only registered when adminToken is set.
+func SetupRouter(handler *handlers.Handler, logger *zap.Logger, adminToken string) *mux.Router {
 	router := mux.NewRouter()
 
-	router.Use(func(next http.Handler) http.Handler {
-		return LoggingMiddleware(logger, next)
-	})
+	middlewares := []mux.MiddlewareFunc{
+		func(next http.Handler) http.Handler {
+			return RequestIDMiddleware(logger, next)
+		},
+		LoggingMiddleware,
+		MetricsMiddleware,
+		TracingMiddleware,
+	}
+	router.Use(middlewares...)
+
+	// mux only runs middleware on matched routes, so unmatched requests get the chain explicitly
+	router.NotFoundHandler = withMiddleware(http.NotFoundHandler(), middlewares)
+	router.MethodNotAllowedHandler = withMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}), middlewares)
 
 	router.HandleFunc("/health", handler.HealthHandler).Methods("GET")
+	router.HandleFunc("/livez", handler.LivezHandler).Methods("GET")
+	router.HandleFunc("/readyz", handler.ReadyzHandler).Methods("GET")
 	router.HandleFunc("/chat", handler.ChatHandler).Methods("GET", "POST")
+	router.HandleFunc("/chat/candidates/{id}/select", handler.SelectCandidateHandler).Methods("POST")
+	router.HandleFunc("/knowledge-bases/{name}/documents", handler.IngestDocumentHandler).Methods("POST")
+	router.HandleFunc("/tokenize", handler.TokenizeHandler).Methods("POST")
+
+	router.HandleFunc("/metrics", handler.MetricsHandler).Methods("GET")
 
-	router.Handle("/metrics", promhttp.Handler()).Methods("GET")
+	if adminToken != "" {
+		admin := router.PathPrefix("/admin").Subrouter()
+		admin.Use(func(next http.Handler) http.Handler {
+			return AdminAuthMiddleware(adminToken, next)
+		})
+		admin.HandleFunc("/log-level", handler.LogLevelHandler).Methods("GET", "PUT")
+	}
 
-	registerMetrics()
+	metrics.Register()
 
 	return router
 }
 
-var (
-	httpRequestsTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "http_requests_total",
-			Help: "Total number of HTTP requests",
-		},
-		[]string{"method", "endpoint", "status"},
-	)
-
-	httpRequestDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "http_request_duration_seconds",
-			Help:    "HTTP request duration in seconds",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"method", "endpoint"},
-	)
-
-	chatRequestsTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "chat_requests_total",
-			Help: "Total number of chat requests",
-		},
-		[]string{"stream_type"},
-	)
-)
-
-func registerMetrics() {
-	prometheus.MustRegister(httpRequestsTotal)
-	prometheus.MustRegister(httpRequestDuration)
-	prometheus.MustRegister(chatRequestsTotal)
+// withMiddleware wraps handler in middlewares, the first being the outermost as with router.Use
+func withMiddleware(handler http.Handler, middlewares []mux.MiddlewareFunc) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
 }
@@ -0,0 +1,105 @@
+package audit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"llm-chat-service/internal/metrics"
+
+	"go.uber.org/zap"
+)
+
+// writeTimeout bounds a single sink write so a stuck sink cannot stall the queue forever
+const writeTimeout = 5 * time.Second
+
+// Auditor redacts records and writes them to a sink from a background goroutine.
+// Records are dropped (and counted) when the queue is full so chat latency never depends on the sink.
+type Auditor struct {
+	sink     Sink
+	redactor *Redactor
+	logger   *zap.Logger
+	queue    chan Record
+	done     chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewAuditor starts the background writer
+func NewAuditor(sink Sink, redactor *Redactor, bufferSize int, logger *zap.Logger) *Auditor {
+	if bufferSize <= 0 {
+		bufferSize = 1
+	}
+	a := &Auditor{
+		sink:     sink,
+		redactor: redactor,
+		logger:   logger,
+		queue:    make(chan Record, bufferSize),
+		done:     make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Record enqueues a record without blocking
+func (a *Auditor) Record(record Record) {
+	if record.Timestamp.IsZero() {
+		record.Timestamp = time.Now().UTC()
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		metrics.AuditRecordsTotal.WithLabelValues("dropped").Inc()
+		return
+	}
+
+	select {
+	case a.queue <- record:
+	default:
+		metrics.AuditRecordsTotal.WithLabelValues("dropped").Inc()
+		a.logger.Warn("Audit queue full, dropping record", zap.String("request_id", record.RequestID))
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Close stops accepting records, flushes the queue and closes the sink
+func (a *Auditor) Close() error {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.queue)
+	}
+	a.mu.Unlock()
+
+	<-a.done
+	return a.sink.Close()
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (a *Auditor) run() {
+	defer close(a.done)
+
+	for record := range a.queue {
+		record.Prompt = a.redactor.Redact(record.Prompt)
+		record.Response = a.redactor.Redact(record.Response)
+		record.Error = a.redactor.Redact(record.Error)
+
+		ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+		err := a.sink.Write(ctx, record)
+		cancel()
+
+		if err != nil {
+			metrics.AuditRecordsTotal.WithLabelValues("failed").Inc()
+			a.logger.Error("Failed to write audit record",
+				zap.String("request_id", record.RequestID),
+				zap.Error(err),
+			)
+			continue
+		}
+		metrics.AuditRecordsTotal.WithLabelValues("written").Inc()
+	}
+}
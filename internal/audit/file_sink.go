@@ -0,0 +1,121 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileSink appends records as JSON lines and rotates the file when it exceeds maxBytes.
+// Rotated files are named <path>.1 (newest) to <path>.<maxBackups> (oldest).
+type FileSink struct {
+	mu         sync.Mutex
+	path       string
+	maxBytes   int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewFileSink opens (or creates) the audit file at path
+func NewFileSink(path string, maxBytes int64, maxBackups int) (*FileSink, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return nil, fmt.Errorf("failed to create audit directory: %w", err)
+		}
+	}
+
+	s := &FileSink{
+		path:       path,
+		maxBytes:   maxBytes,
+		maxBackups: maxBackups,
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (s *FileSink) Write(ctx context.Context, record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit record: %w", err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.maxBytes > 0 && s.size > 0 && s.size+int64(len(line)) > s.maxBytes {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open audit file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat audit file: %w", err)
+	}
+	s.file = file
+	s.size = info.Size()
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// rotate shifts existing backups up by one, moves the current file to .1 and reopens a fresh file
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("failed to close audit file: %w", err)
+	}
+
+	if s.maxBackups <= 0 {
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove audit file: %w", err)
+		}
+		return s.open()
+	}
+
+	_ = os.Remove(s.backupName(s.maxBackups))
+	for i := s.maxBackups - 1; i >= 1; i-- {
+		if err := os.Rename(s.backupName(i), s.backupName(i+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate audit backup: %w", err)
+		}
+	}
+	if err := os.Rename(s.path, s.backupName(1)); err != nil {
+		return fmt.Errorf("failed to rotate audit file: %w", err)
+	}
+
+	return s.open()
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (s *FileSink) backupName(i int) string {
+	return fmt.Sprintf("%s.%d", s.path, i)
+}
@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestFileSink_Rotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	sink, err := NewFileSink(path, 200, 2)
+	if err != nil {
+		t.Fatalf("NewFileSink() error = %v", err)
+	}
+	defer sink.Close()
+
+	for i := 0; i < 10; i++ {
+		if err := sink.Write(context.Background(), Record{Prompt: "Hello", Response: "World"}); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("Expected %s to exist: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("Expected at most 2 backups, found %s.3", path)
+	}
+}
+
+func TestAuditor_RedactsBeforeWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+
+	sink, err := NewFileSink(path, 0, 0)
+	if err != nil {
+		t.Fatalf("NewFileSink() error = %v", err)
+	}
+	redactor, _ := NewRedactor([]string{"email"}, nil)
+
+	auditor := NewAuditor(sink, redactor, 10, zap.NewNop())
+	auditor.Record(Record{RequestID: "req-1", Prompt: "I am bob@example.com", Response: "Hi"})
+	if err := auditor.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open audit file: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		t.Fatal("Expected one audit record")
+	}
+
+	var record Record
+	if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+		t.Fatalf("Failed to decode audit record: %v", err)
+	}
+	if record.Prompt != "I am [REDACTED_EMAIL]" {
+		t.Errorf("Expected redacted prompt, got %q", record.Prompt)
+	}
+	if record.RequestID != "req-1" {
+		t.Errorf("Expected request ID req-1, got %q", record.RequestID)
+	}
+}
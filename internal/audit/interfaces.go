@@ -0,0 +1,25 @@
+package audit
+
+import (
+	"context"
+	"time"
+)
+
+// Record is a single audited chat turn
+type Record struct {
+	Timestamp      time.Time `json:"timestamp"`
+	RequestID      string    `json:"request_id,omitempty"`
+	ConversationID string    `json:"conversation_id,omitempty"`
+	Tenant         string    `json:"tenant,omitempty"`
+	Stream         bool      `json:"stream"`
+	Prompt         string    `json:"prompt"`
+	Response       string    `json:"response,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	DurationMs     int64     `json:"duration_ms"`
+}
+
+// Sink defines the interface for durable audit storage
+type Sink interface {
+	Write(ctx context.Context, record Record) error
+	Close() error
+}
@@ -0,0 +1,142 @@
+package audit
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Built-in redaction rule names
+const (
+	RuleEmail = "email"
+	RulePhone = "phone"
+	RuleCard  = "card"
+)
+
+// Rule replaces every match of Pattern with Replacement
+type Rule struct {
+	Name        string
+	Pattern     *regexp.Regexp
+	Replacement string
+	// Match, when set, must also return true for a candidate to be redacted
+	Match func(string) bool
+}
+
+var builtinRules = map[string]Rule{
+	RuleEmail: {
+		Name:        RuleEmail,
+		Pattern:     regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+		Replacement: "[REDACTED_EMAIL]",
+	},
+	RuleCard: {
+		Name:        RuleCard,
+		Pattern:     regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`),
+		Replacement: "[REDACTED_CARD]",
+		Match:       luhnValid,
+	},
+	RulePhone: {
+		Name:        RulePhone,
+		Pattern:     regexp.MustCompile(`(?:\+\d{1,3}[ .\-]?)?(?:\(\d{2,4}\)[ .\-]?)?\d{2,4}[ .\-]\d{3,4}[ .\-]?\d{0,4}\b`),
+		Replacement: "[REDACTED_PHONE]",
+		Match:       hasPhoneDigits,
+	},
+}
+
+// builtinOrder applies cards before phones so card numbers are not partially matched as phones
+var builtinOrder = []string{RuleEmail, RuleCard, RulePhone}
+
+// Redactor applies an ordered list of rules to text
+type Redactor struct {
+	rules []Rule
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewRedactor builds a redactor from built-in rule names and custom regular expressions
+func NewRedactor(builtins []string, customPatterns []string) (*Redactor, error) {
+	enabled := make(map[string]bool, len(builtins))
+	for _, name := range builtins {
+		name = strings.TrimSpace(strings.ToLower(name))
+		if name == "" {
+			continue
+		}
+		if _, ok := builtinRules[name]; !ok {
+			return nil, fmt.Errorf("unknown redaction rule %q", name)
+		}
+		enabled[name] = true
+	}
+
+	r := &Redactor{}
+	for _, name := range builtinOrder {
+		if enabled[name] {
+			r.rules = append(r.rules, builtinRules[name])
+		}
+	}
+
+	for i, pattern := range customPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %d: %w", i, err)
+		}
+		r.rules = append(r.rules, Rule{
+			Name:        fmt.Sprintf("custom_%d", i),
+			Pattern:     re,
+			Replacement: "[REDACTED]",
+		})
+	}
+
+	return r, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Redact returns text with every rule applied
+func (r *Redactor) Redact(text string) string {
+	if r == nil {
+		return text
+	}
+	for _, rule := range r.rules {
+		rule := rule
+		text = rule.Pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if rule.Match != nil && !rule.Match(match) {
+				return match
+			}
+			return rule.Replacement
+		})
+	}
+	return text
+}
+
+// ------------------------------------------------------------------------------------------------------
+// luhnValid reports whether the digits in s pass the Luhn checksum used by payment cards
+func luhnValid(s string) bool {
+	sum, count := 0, 0
+	double := false
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+		count++
+	}
+	return count >= 13 && sum%10 == 0
+}
+
+// ------------------------------------------------------------------------------------------------------
+// hasPhoneDigits filters out short numeric matches such as dates or version numbers
+func hasPhoneDigits(s string) bool {
+	count := 0
+	for _, c := range s {
+		if c >= '0' && c <= '9' {
+			count++
+		}
+	}
+	return count >= 7 && count <= 15
+}
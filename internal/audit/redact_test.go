@@ -0,0 +1,61 @@
+package audit
+
+import (
+	"testing"
+)
+
+func TestRedactor_Redact(t *testing.T) {
+	redactor, err := NewRedactor([]string{"email", "phone", "card"}, []string{`ACME-\d+`})
+	if err != nil {
+		t.Fatalf("NewRedactor() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "email",
+			input: "mail me at jane.doe@example.com please",
+			want:  "mail me at [REDACTED_EMAIL] please",
+		},
+		{
+			name:  "phone",
+			input: "call +1 555-123-4567 now",
+			want:  "call [REDACTED_PHONE] now",
+		},
+		{
+			name:  "valid card",
+			input: "card 4111 1111 1111 1111 expires soon",
+			want:  "card [REDACTED_CARD] expires soon",
+		},
+		{
+			name:  "custom pattern",
+			input: "ticket ACME-12345 is open",
+			want:  "ticket [REDACTED] is open",
+		},
+		{
+			name:  "no pii",
+			input: "the meeting is on 2024-01-15 at 10:30",
+			want:  "the meeting is on 2024-01-15 at 10:30",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redactor.Redact(tt.input); got != tt.want {
+				t.Errorf("Redact() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRedactor_Invalid(t *testing.T) {
+	if _, err := NewRedactor([]string{"ssn"}, nil); err == nil {
+		t.Error("Expected error for unknown rule")
+	}
+	if _, err := NewRedactor(nil, []string{"("}); err == nil {
+		t.Error("Expected error for invalid pattern")
+	}
+}
@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"llm-chat-service/internal/redisconn"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStreamSink appends records to a Redis stream, trimming it to roughly maxLen entries
+type RedisStreamSink struct {
+	client *redisconn.Client
+	stream string
+	maxLen int64
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewRedisStreamSink creates a sink writing to the given stream key
+func NewRedisStreamSink(client *redisconn.Client, stream string, maxLen int64) *RedisStreamSink {
+	return &RedisStreamSink{
+		client: client,
+		stream: stream,
+		maxLen: maxLen,
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (s *RedisStreamSink) Write(ctx context.Context, record Record) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit record: %w", err)
+	}
+
+	args := &redis.XAddArgs{
+		Stream: s.stream,
+		Values: map[string]any{
+			"request_id": record.RequestID,
+			"record":     data,
+		},
+	}
+	if s.maxLen > 0 {
+		args.MaxLen = s.maxLen
+		args.Approx = true
+	}
+
+	return s.client.XAdd(ctx, args).Err()
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (s *RedisStreamSink) Close() error {
+	return s.client.Close()
+}
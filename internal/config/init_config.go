@@ -1,17 +1,29 @@
 package config
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
 	"llm-chat-service/internal/api"
 	"llm-chat-service/internal/api/handlers"
+	"llm-chat-service/internal/audit"
+	"llm-chat-service/internal/health"
 	"llm-chat-service/internal/llm"
 	"llm-chat-service/internal/logging"
+	"llm-chat-service/internal/moderation"
+	"llm-chat-service/internal/rag"
+	"llm-chat-service/internal/redisconn"
 	"llm-chat-service/internal/service"
 	"llm-chat-service/internal/storage"
+	"llm-chat-service/internal/tracing"
 	"net/http"
-	"time"
+	"os"
+	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
 
@@ -21,33 +33,302 @@ func (c *Config) NewMessageStore() storage.MessageStore {
 }
 
 // ------------------------------------------------------------------------------------------------------
+// RedisOptions returns the connection settings shared by every Redis client of the service
+func (c *Config) RedisOptions() (*redisconn.Options, error) {
+	opts := &redisconn.Options{
+		Mode: c.RedisMode,
+		UniversalOptions: redis.UniversalOptions{
+			Addrs:            redisAddrs(c.RedisAddr),
+			Username:         c.RedisUsername,
+			Password:         c.RedisPassword,
+			DB:               c.RedisDB,
+			MasterName:       c.RedisMasterName,
+			SentinelPassword: c.RedisSentinelPassword,
+		},
+	}
+	if c.RedisTLS {
+		tlsConfig, err := c.redisTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		opts.TLSConfig = tlsConfig
+	}
+	return opts, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// redisTLSConfig trusts REDIS_TLS_CA_FILE in addition to the system roots and presents a client
+// certificate when one is configured
+func (c *Config) redisTLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: c.RedisTLSServerName}
+
+	if c.RedisTLSCAFile != "" {
+		pem, err := os.ReadFile(c.RedisTLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("REDIS_TLS_CA_FILE: %w", err)
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("REDIS_TLS_CA_FILE: no PEM certificate found in %s", c.RedisTLSCAFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	if c.RedisTLSCertFile != "" || c.RedisTLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.RedisTLSCertFile, c.RedisTLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("REDIS_TLS_CERT_FILE and REDIS_TLS_KEY_FILE: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// redisAddrs splits the comma-separated REDIS_ADDR
+func redisAddrs(value string) []string {
+	var addrs []string
+	for _, addr := range strings.Split(value, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewRedisClient creates a Redis client named after its use. It connects in the background when Redis
+// is unreachable, so it only fails on invalid settings.
+func (c *Config) NewRedisClient(name string, logger *zap.Logger) (*redisconn.Client, error) {
+	opts, err := c.RedisOptions()
+	if err != nil {
+		return nil, err
+	}
+	return redisconn.New(name, opts, logger), nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewCacheStore creates the token count cache. While Redis is unreachable the cache is skipped and
+// reconnected in the background.
 func (c *Config) NewCacheStore(logger *zap.Logger) storage.CacheStore {
-	redisStore, err := storage.NewRedisStore(c.RedisAddr, c.RedisPassword)
+	client, err := c.NewRedisClient("cache", logger)
 	if err != nil {
-		logger.Warn("Failed to connect to Redis, continuing without cache",
+		logger.Warn("Invalid Redis settings, continuing without cache",
 			zap.Error(err),
 		)
 		return nil
 	}
-	logger.Info("Connected to Redis")
-	return redisStore
+	return storage.NewRedisStore(client)
 }
 
 // ------------------------------------------------------------------------------------------------------
-func (c *Config) NewLLMClient() llm.Client {
-	return llm.NewGroqClient(c.GroqAPIKey, c.GroqBaseURL, c.Model)
+// NewLLMClient builds the chat model client, recording its traffic to or replaying it from the cassette
+// when LLM_CASSETTE_MODE asks for it
+func (c *Config) NewLLMClient(logger *zap.Logger) (llm.Client, error) {
+	opts := []llm.ClientOption{
+		llm.WithJSONSchemaSupport(c.LLMJSONSchemaSupport),
+		llm.WithVisionSupport(c.LLMVisionSupport),
+		llm.WithMultipleChoicesSupport(c.LLMMultipleChoicesSupport),
+		llm.WithTimeout(c.LLMRequestTimeout),
+	}
+
+	switch c.LLMCassetteMode {
+	case "", "off":
+	case "record":
+		recorder, err := llm.NewRecorder(c.LLMCassettePath)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, llm.WithRecorder(recorder))
+		logger.Warn("Recording LLM traffic", zap.String("cassette", c.LLMCassettePath))
+	case "replay":
+		cassette, err := llm.LoadCassette(c.LLMCassettePath)
+		if err != nil {
+			return nil, err
+		}
+		logger.Warn("Replaying LLM traffic, the provider will not be called",
+			zap.String("cassette", c.LLMCassettePath),
+			zap.Int("exchanges", cassette.Len()),
+		)
+		return llm.NewReplayClient(cassette, c.Model, c.LLMCassetteRealtime, opts...), nil
+	default:
+		return nil, fmt.Errorf("unknown LLM cassette mode %q: must be off, record or replay", c.LLMCassetteMode)
+	}
+
+	return llm.NewGroqClient(c.GroqAPIKey, c.GroqBaseURL, c.Model, opts...), nil
 }
 
 // ------------------------------------------------------------------------------------------------------
 func (c *Config) NewLogger() (*zap.Logger, error) {
-	if err := logging.Init(); err != nil {
+	if err := logging.Init(c.LoggingOptions()); err != nil {
 		return nil, fmt.Errorf("failed to initialize logger: %w", err)
 	}
 	return logging.Logger, nil
 }
 
 // ------------------------------------------------------------------------------------------------------
-func (c *Config) NewChatService(logger *zap.Logger) (service.ChatService, storage.CacheStore) {
+// NewTracing installs the global tracer provider and returns its shutdown function
+func (c *Config) NewTracing(ctx context.Context) (func(context.Context) error, error) {
+	shutdown, err := tracing.Init(ctx, c.TracingExporter, c.OTLPEndpoint, c.ServiceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize tracing: %w", err)
+	}
+	return shutdown, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewAuditor builds the audit pipeline for the configured sink. It returns nil when auditing is disabled.
+func (c *Config) NewAuditor(logger *zap.Logger) (*audit.Auditor, error) {
+	redactor, err := audit.NewRedactor(c.AuditRedact, c.AuditRedactPatterns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build audit redactor: %w", err)
+	}
+
+	var sink audit.Sink
+	switch c.AuditSink {
+	case "", "none":
+		return nil, nil
+	case "file":
+		sink, err = audit.NewFileSink(c.AuditFilePath, int64(c.AuditFileMaxSizeMB)*1024*1024, c.AuditFileMaxBackups)
+	case "redis":
+		var client *redisconn.Client
+		if client, err = c.NewRedisClient("audit", logger); err == nil {
+			sink = audit.NewRedisStreamSink(client, c.AuditRedisStream, int64(c.AuditRedisMaxLen))
+		}
+	default:
+		return nil, fmt.Errorf("unknown audit sink %q", c.AuditSink)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s audit sink: %w", c.AuditSink, err)
+	}
+
+	logger.Info("Audit logging enabled", zap.String("sink", c.AuditSink))
+	return audit.NewAuditor(sink, redactor, c.AuditBufferSize, logger), nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewToolRegistry registers the enabled built-in tools. It returns nil when no tools are enabled.
+func (c *Config) NewToolRegistry() (*service.ToolRegistry, error) {
+	if len(c.ToolsEnabled) == 0 {
+		return nil, nil
+	}
+
+	registry := service.NewToolRegistry()
+	if err := service.RegisterBuiltinTools(registry, c.ToolsEnabled); err != nil {
+		return nil, fmt.Errorf("failed to register tools: %w", err)
+	}
+	return registry, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewKnowledgeService builds the document index and embedder. It returns nil when knowledge bases are disabled.
+func (c *Config) NewKnowledgeService(logger *zap.Logger) (*rag.Service, error) {
+	var index rag.Index
+	switch c.RAGIndex {
+	case "", "none":
+		return nil, nil
+	case "memory":
+		index = rag.NewMemoryIndex()
+	case "redis":
+		client, err := c.NewRedisClient("rag", logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create redis knowledge index: %w", err)
+		}
+		index = rag.NewRedisIndex(client, c.RAGRedisPrefix)
+	default:
+		return nil, fmt.Errorf("unknown knowledge index %q", c.RAGIndex)
+	}
+
+	var embedder rag.Embedder
+	switch c.RAGEmbedder {
+	case "hash":
+		embedder = rag.NewHashEmbedder(c.RAGEmbeddingDimensions)
+	case "http":
+		if c.RAGEmbeddingURL == "" || c.RAGEmbeddingModel == "" {
+			index.Close()
+			return nil, fmt.Errorf("RAG_EMBEDDING_URL and RAG_EMBEDDING_MODEL are required for the http embedder")
+		}
+		embedder = rag.NewHTTPEmbedder(c.RAGEmbeddingURL, c.RAGEmbeddingAPIKey, c.RAGEmbeddingModel)
+	default:
+		index.Close()
+		return nil, fmt.Errorf("unknown embedder %q", c.RAGEmbedder)
+	}
+
+	chunker, err := rag.NewChunker(c.RAGChunkTokens, c.RAGChunkOverlap)
+	if err != nil {
+		index.Close()
+		return nil, fmt.Errorf("failed to create chunker: %w", err)
+	}
+
+	logger.Info("Knowledge bases enabled",
+		zap.String("index", c.RAGIndex),
+		zap.String("embedder", c.RAGEmbedder),
+	)
+	return rag.NewService(chunker, embedder, index, c.RAGTopK), nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewModerator builds the content moderation stage, or returns nil when no classifier is configured
+func (c *Config) NewModerator(logger *zap.Logger) (*moderation.Moderator, error) {
+	if len(c.ModerationClassifiers) == 0 {
+		return nil, nil
+	}
+
+	inputAction, err := moderation.ParseAction(c.ModerationInputAction)
+	if err != nil {
+		return nil, err
+	}
+	outputAction, err := moderation.ParseAction(c.ModerationOutputAction)
+	if err != nil {
+		return nil, err
+	}
+
+	var classifiers moderation.MultiClassifier
+	for _, name := range c.ModerationClassifiers {
+		switch name {
+		case "keyword":
+			classifier, err := moderation.NewPatternClassifier(c.ModerationKeywords, c.ModerationPatterns)
+			if err != nil {
+				return nil, err
+			}
+			classifiers = append(classifiers, classifier)
+		case "guard":
+			guard := llm.NewGroqClient(c.GroqAPIKey, c.GroqBaseURL, c.ModerationGuardModel, llm.WithTimeout(c.LLMRequestTimeout))
+			classifiers = append(classifiers, moderation.NewGuardClassifier(guard))
+		default:
+			return nil, fmt.Errorf("unknown moderation classifier %q", name)
+		}
+	}
+
+	logger.Info("Content moderation enabled",
+		zap.Strings("classifiers", c.ModerationClassifiers),
+		zap.String("input_action", string(inputAction)),
+		zap.String("output_action", string(outputAction)),
+	)
+	return moderation.NewModerator(classifiers, inputAction, outputAction, c.ModerationOutputWindow), nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewInjectionDetector builds the prompt-injection detector, or returns nil when it is disabled
+func (c *Config) NewInjectionDetector() (*service.InjectionDetector, error) {
+	if c.InjectionAction == "none" {
+		return nil, nil
+	}
+	action, err := service.ParseInjectionAction(c.InjectionAction)
+	if err != nil {
+		return nil, err
+	}
+	if c.InjectionThreshold <= 0 || c.InjectionThreshold > 1 {
+		return nil, fmt.Errorf("INJECTION_THRESHOLD must be between 0 and 1, got %v", c.InjectionThreshold)
+	}
+	return service.NewInjectionDetector(action, c.InjectionThreshold), nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (c *Config) NewChatService(logger *zap.Logger, opts ...service.Option) (service.ChatService, storage.CacheStore, error) {
 	// Create message store
 	messageStore := c.NewMessageStore()
 
@@ -55,21 +336,59 @@ func (c *Config) NewChatService(logger *zap.Logger) (service.ChatService, storag
 	cacheStore := c.NewCacheStore(logger)
 
 	// Create LLM client
-	llmClient := c.NewLLMClient()
+	llmClient, err := c.NewLLMClient(logger)
+	if err != nil {
+		if cacheStore != nil {
+			cacheStore.Close()
+		}
+		return nil, nil, fmt.Errorf("failed to create LLM client: %w", err)
+	}
+
+	opts = append([]service.Option{
+		service.WithMaxToolIterations(c.MaxToolIterations),
+		service.WithStructuredOutputRetries(c.StructuredOutputMaxRetries),
+		service.WithAutoContinue(c.AutoContinueMax, c.AutoContinueMaxTokens),
+		service.WithMaxCandidates(c.MaxCandidates),
+	}, opts...)
+	chatService := service.NewChatService(messageStore, cacheStore, llmClient, c.MaxTokens, opts...)
+
+	return chatService, cacheStore, nil
+}
 
-	chatService := service.NewChatService(messageStore, cacheStore, llmClient, c.MaxTokens)
+// ------------------------------------------------------------------------------------------------------
+// NewHealth registers the readiness checks: the conversation store and the LLM provider are critical,
+// the Redis cache is not since chats work without it. The provider probe is cached to spare the API.
+func (c *Config) NewHealth(chatService service.ChatService, cacheStore storage.CacheStore) *health.Health {
+	h := health.New(c.HealthCheckTimeout)
+
+	if checker, ok := chatService.(service.DependencyChecker); ok {
+		h.Register("store", health.CheckerFunc(checker.PingStore), true)
+		h.Register("llm", health.Cached(health.CheckerFunc(checker.PingLLM), c.HealthLLMProbeCache), true)
+	}
+
+	redisCheck := health.CheckerFunc(func(ctx context.Context) error {
+		return errors.New("cache disabled: invalid Redis settings")
+	})
+	if pinger, ok := cacheStore.(storage.Pinger); ok {
+		redisCheck = pinger.Ping
+	}
+	h.Register("redis", redisCheck, false)
 
-	return chatService, cacheStore
+	return h
 }
 
 // ------------------------------------------------------------------------------------------------------
-func (c *Config) NewHandler(chatService service.ChatService, logger *zap.Logger) *handlers.Handler {
-	return handlers.NewHandler(chatService, logger)
+func (c *Config) NewHandler(chatService service.ChatService, logger *zap.Logger, opts ...handlers.Option) *handlers.Handler {
+	opts = append([]handlers.Option{
+		handlers.WithClientToolTimeout(c.ClientToolTimeout),
+		handlers.WithMaxDocumentBytes(int64(c.RAGMaxDocumentMB) << 20),
+	}, opts...)
+	return handlers.NewHandler(chatService, logger, opts...)
 }
 
 // ------------------------------------------------------------------------------------------------------
 func (c *Config) NewRouter(handler *handlers.Handler, logger *zap.Logger) *mux.Router {
-	return api.SetupRouter(handler, logger)
+	return api.SetupRouter(handler, logger, c.AdminToken)
 }
 
 // ------------------------------------------------------------------------------------------------------
@@ -77,8 +396,8 @@ func (c *Config) NewHTTPServer(router *mux.Router) *http.Server {
 	return &http.Server{
 		Addr:         ":" + c.Port,
 		Handler:      router,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 60 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		ReadTimeout:  c.HTTPReadTimeout,
+		WriteTimeout: c.HTTPWriteTimeout,
+		IdleTimeout:  c.HTTPIdleTimeout,
 	}
 }
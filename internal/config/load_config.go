@@ -1,9 +1,10 @@
 package config
 
 import (
-	"fmt"
 	"os"
-	"strconv"
+	"time"
+
+	"llm-chat-service/internal/logging"
 
 	"github.com/joho/godotenv"
 )
@@ -14,50 +15,256 @@ type Config struct {
 	GroqAPIKey    string
 	RedisAddr     string
 	RedisPassword string
+	RedisDB       int
+	RedisTLS      bool
 	MaxTokens     int
 	MaxExchanges  int
 	Model         string
 	GroqBaseURL   string
+
+	// Redis deployment: REDIS_ADDR lists the Sentinels in sentinel mode and the seed nodes in cluster mode
+	RedisMode             string
+	RedisUsername         string
+	RedisMasterName       string
+	RedisSentinelPassword string
+	RedisTLSCAFile        string
+	RedisTLSCertFile      string
+	RedisTLSKeyFile       string
+	RedisTLSServerName    string
+
+	// ConfigFile is the optional file (CONFIG_FILE) whose settings the environment overrides
+	ConfigFile string
+
+	// HTTP server
+	HTTPReadTimeout  time.Duration
+	HTTPWriteTimeout time.Duration
+	HTTPIdleTimeout  time.Duration
+	ShutdownTimeout  time.Duration
+
+	// ShutdownDelay is how long /readyz reports not ready before the server stops accepting
+	// connections, so load balancers stop routing to it first
+	ShutdownDelay time.Duration
+
+	// ShutdownDrainTimeout is how long chats in flight may run after shutdown starts before they are cancelled
+	ShutdownDrainTimeout time.Duration
+
+	// LLMRequestTimeout bounds each upstream LLM request, including the streamed answer
+	LLMRequestTimeout time.Duration
+
+	// Readiness checks
+	HealthCheckTimeout  time.Duration
+	HealthLLMProbeCache time.Duration
+
+	// Model capabilities
+	LLMVisionSupport bool
+
+	// LLMMultipleChoicesSupport declares that the provider honours n > 1; otherwise candidates are
+	// generated with parallel requests
+	LLMMultipleChoicesSupport bool
+	MaxCandidates             int
+
+	// LLMCassetteMode records upstream LLM traffic to LLMCassettePath ("record") or answers from it
+	// instead of calling the provider ("replay")
+	LLMCassetteMode     string
+	LLMCassettePath     string
+	LLMCassetteRealtime bool
+
+	// Structured output
+	LLMJSONSchemaSupport       bool
+	StructuredOutputMaxRetries int
+
+	// Tracing
+	TracingExporter string
+	OTLPEndpoint    string
+	ServiceName     string
+
+	// Logging
+	LogLevel              string
+	LogFormat             string
+	LogSamplingInitial    int
+	LogSamplingThereafter int
+
+	// AdminToken protects the /admin endpoints; they are disabled when empty
+	AdminToken string
+
+	// Tools
+	ToolsEnabled      []string
+	MaxToolIterations int
+	ClientToolTimeout time.Duration
+
+	// Auto-continue for answers cut off by MaxTokens
+	AutoContinueMax       int
+	AutoContinueMaxTokens int
+
+	// Knowledge bases (retrieval-augmented generation)
+	RAGIndex               string
+	RAGRedisPrefix         string
+	RAGEmbedder            string
+	RAGEmbeddingURL        string
+	RAGEmbeddingModel      string
+	RAGEmbeddingAPIKey     string
+	RAGEmbeddingDimensions int
+	RAGChunkTokens         int
+	RAGChunkOverlap        int
+	RAGTopK                int
+	RAGMaxDocumentMB       int
+
+	// Content moderation
+	ModerationClassifiers  []string
+	ModerationKeywords     []string
+	ModerationPatterns     []string
+	ModerationGuardModel   string
+	ModerationInputAction  string
+	ModerationOutputAction string
+	ModerationOutputWindow int
+
+	// Prompt-injection detection
+	InjectionAction    string
+	InjectionThreshold float64
+
+	// Audit
+	AuditSink           string
+	AuditFilePath       string
+	AuditFileMaxSizeMB  int
+	AuditFileMaxBackups int
+	AuditRedisStream    string
+	AuditRedisMaxLen    int
+	AuditBufferSize     int
+	AuditRedact         []string
+	AuditRedactPatterns []string
 }
 
 // ------------------------------------------------------------------------------------------------------
+// Load reads the configuration from the environment, layered over the optional CONFIG_FILE, and
+// validates it. All problems are reported together.
 func Load() (*Config, error) {
 	_ = godotenv.Load()
+	src, err := newSource(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		return nil, err
+	}
+
 	cfg := &Config{
-		Port:          getEnv("PORT", "8000"),
-		GroqAPIKey:    getEnv("GROQ_API_KEY", ""),
-		RedisAddr:     getEnv("REDIS_ADDR", "localhost:6379"),
-		RedisPassword: getEnv("REDIS_PASSWORD", ""),
-		MaxTokens:     getEnvAsInt("MAX_TOKENS", 1024),
-		MaxExchanges:  getEnvAsInt("MAX_EXCHANGES", 20),
-		Model:         getEnv("MODEL", "llama-3.1-8b-instant"),
-		GroqBaseURL:   getEnv("GROQ_BASE_URL", "https://api.groq.com/openai/v1/chat/completions"),
+		Port:          src.getString("PORT", "8000"),
+		GroqAPIKey:    src.getString("GROQ_API_KEY", ""),
+		RedisAddr:     src.getString("REDIS_ADDR", "localhost:6379"),
+		RedisPassword: src.getString("REDIS_PASSWORD", ""),
+		RedisDB:       src.getInt("REDIS_DB", 0),
+		RedisTLS:      src.getBool("REDIS_TLS", false),
+		MaxTokens:     src.getInt("MAX_TOKENS", 1024),
+		MaxExchanges:  src.getInt("MAX_EXCHANGES", 20),
+		Model:         src.getString("MODEL", "llama-3.1-8b-instant"),
+		GroqBaseURL:   src.getString("GROQ_BASE_URL", "https://api.groq.com/openai/v1/chat/completions"),
+
+		RedisMode:             src.getString("REDIS_MODE", "standalone"),
+		RedisUsername:         src.getString("REDIS_USERNAME", ""),
+		RedisMasterName:       src.getString("REDIS_MASTER_NAME", ""),
+		RedisSentinelPassword: src.getString("REDIS_SENTINEL_PASSWORD", ""),
+		RedisTLSCAFile:        src.getString("REDIS_TLS_CA_FILE", ""),
+		RedisTLSCertFile:      src.getString("REDIS_TLS_CERT_FILE", ""),
+		RedisTLSKeyFile:       src.getString("REDIS_TLS_KEY_FILE", ""),
+		RedisTLSServerName:    src.getString("REDIS_TLS_SERVER_NAME", ""),
+
+		ConfigFile: src.path,
+
+		HTTPReadTimeout:  src.getDuration("HTTP_READ_TIMEOUT", 15*time.Second),
+		HTTPWriteTimeout: src.getDuration("HTTP_WRITE_TIMEOUT", 60*time.Second),
+		HTTPIdleTimeout:  src.getDuration("HTTP_IDLE_TIMEOUT", 60*time.Second),
+		ShutdownTimeout:  src.getDuration("SHUTDOWN_TIMEOUT", 10*time.Second),
+		ShutdownDelay:    src.getDuration("SHUTDOWN_DELAY", 0),
+
+		ShutdownDrainTimeout: src.getDuration("SHUTDOWN_DRAIN_TIMEOUT", 30*time.Second),
+
+		LLMRequestTimeout: src.getDuration("LLM_REQUEST_TIMEOUT", 60*time.Second),
+
+		HealthCheckTimeout:  src.getDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+		HealthLLMProbeCache: src.getDuration("HEALTH_LLM_PROBE_CACHE", 30*time.Second),
+
+		LLMVisionSupport: src.getBool("LLM_VISION_SUPPORT", false),
+
+		LLMMultipleChoicesSupport: src.getBool("LLM_MULTIPLE_CHOICES_SUPPORT", false),
+		MaxCandidates:             src.getInt("MAX_CANDIDATES", 4),
+
+		LLMCassetteMode:     src.getString("LLM_CASSETTE_MODE", "off"),
+		LLMCassettePath:     src.getString("LLM_CASSETTE_PATH", "llm-cassette.jsonl"),
+		LLMCassetteRealtime: src.getBool("LLM_CASSETTE_REALTIME", false),
+
+		LLMJSONSchemaSupport:       src.getBool("LLM_JSON_SCHEMA_SUPPORT", false),
+		StructuredOutputMaxRetries: src.getInt("STRUCTURED_OUTPUT_MAX_RETRIES", 2),
+
+		TracingExporter: src.getString("TRACING_EXPORTER", "none"),
+		OTLPEndpoint:    src.getString("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+		ServiceName:     src.getString("OTEL_SERVICE_NAME", "llm-chat-service"),
+
+		LogLevel:              src.getString("LOG_LEVEL", "info"),
+		LogFormat:             src.getString("LOG_FORMAT", logging.FormatJSON),
+		LogSamplingInitial:    src.getInt("LOG_SAMPLING_INITIAL", 100),
+		LogSamplingThereafter: src.getInt("LOG_SAMPLING_THEREAFTER", 100),
+
+		AdminToken: src.getString("ADMIN_TOKEN", ""),
+
+		ToolsEnabled:      src.getList("TOOLS_ENABLED", ""),
+		MaxToolIterations: src.getInt("MAX_TOOL_ITERATIONS", 5),
+		ClientToolTimeout: src.getDuration("CLIENT_TOOL_TIMEOUT", 30*time.Second),
+
+		AutoContinueMax:       src.getInt("AUTO_CONTINUE_MAX", 0),
+		AutoContinueMaxTokens: src.getInt("AUTO_CONTINUE_MAX_TOKENS", 4096),
+
+		RAGIndex:               src.getString("RAG_INDEX", "none"),
+		RAGRedisPrefix:         src.getString("RAG_REDIS_PREFIX", "rag"),
+		RAGEmbedder:            src.getString("RAG_EMBEDDER", "hash"),
+		RAGEmbeddingURL:        src.getString("RAG_EMBEDDING_URL", ""),
+		RAGEmbeddingModel:      src.getString("RAG_EMBEDDING_MODEL", ""),
+		RAGEmbeddingAPIKey:     src.getString("RAG_EMBEDDING_API_KEY", ""),
+		RAGEmbeddingDimensions: src.getInt("RAG_EMBEDDING_DIMENSIONS", 256),
+		RAGChunkTokens:         src.getInt("RAG_CHUNK_TOKENS", 400),
+		RAGChunkOverlap:        src.getInt("RAG_CHUNK_OVERLAP", 50),
+		RAGTopK:                src.getInt("RAG_TOP_K", 4),
+		RAGMaxDocumentMB:       src.getInt("RAG_MAX_DOCUMENT_MB", 10),
+
+		ModerationClassifiers:  src.getList("MODERATION_CLASSIFIERS", ""),
+		ModerationKeywords:     src.getList("MODERATION_KEYWORDS", ""),
+		ModerationGuardModel:   src.getString("MODERATION_GUARD_MODEL", "meta-llama/llama-guard-4-12b"),
+		ModerationInputAction:  src.getString("MODERATION_INPUT_ACTION", "block"),
+		ModerationOutputAction: src.getString("MODERATION_OUTPUT_ACTION", "block"),
+		ModerationOutputWindow: src.getInt("MODERATION_OUTPUT_WINDOW", 200),
+
+		InjectionAction:    src.getString("INJECTION_ACTION", "log"),
+		InjectionThreshold: src.getFloat("INJECTION_THRESHOLD", 0.5),
+
+		AuditSink:           src.getString("AUDIT_SINK", "none"),
+		AuditFilePath:       src.getString("AUDIT_FILE_PATH", "audit/audit.jsonl"),
+		AuditFileMaxSizeMB:  src.getInt("AUDIT_FILE_MAX_SIZE_MB", 100),
+		AuditFileMaxBackups: src.getInt("AUDIT_FILE_MAX_BACKUPS", 5),
+		AuditRedisStream:    src.getString("AUDIT_REDIS_STREAM", "chat:audit"),
+		AuditRedisMaxLen:    src.getInt("AUDIT_REDIS_MAX_LEN", 100000),
+		AuditBufferSize:     src.getInt("AUDIT_BUFFER_SIZE", 1000),
+		AuditRedact:         src.getList("AUDIT_REDACT", "email,phone,card"),
 	}
 
-	if cfg.GroqAPIKey == "" {
-		return nil, fmt.Errorf("GROQ_API_KEY environment variable is required")
+	if pattern := src.getString("AUDIT_REDACT_PATTERN", ""); pattern != "" {
+		cfg.AuditRedactPatterns = []string{pattern}
 	}
 
-	return cfg, nil
-}
+	if pattern := src.getString("MODERATION_PATTERN", ""); pattern != "" {
+		cfg.ModerationPatterns = []string{pattern}
+	}
 
-// ------------------------------------------------------------------------------------------------------
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+	src.unknownKeys()
+	if problems := append(src.problems, cfg.problems()...); len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
 	}
-	return defaultValue
+
+	return cfg, nil
 }
 
 // ------------------------------------------------------------------------------------------------------
-func getEnvAsInt(key string, defaultValue int) int {
-	valueStr := os.Getenv(key)
-	if valueStr == "" {
-		return defaultValue
-	}
-	value, err := strconv.Atoi(valueStr)
-	if err != nil {
-		return defaultValue
+// LoggingOptions returns the logger settings
+func (c *Config) LoggingOptions() logging.Options {
+	return logging.Options{
+		Level:              c.LogLevel,
+		Format:             c.LogFormat,
+		SamplingInitial:    c.LogSamplingInitial,
+		SamplingThereafter: c.LogSamplingThereafter,
 	}
-	return value
 }
@@ -0,0 +1,259 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"llm-chat-service/internal/service"
+
+	"go.uber.org/zap"
+)
+
+// ------------------------------------------------------------------------------------------------------
+// writeConfigFile writes content to a config file and points CONFIG_FILE at it
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+	return path
+}
+
+// ------------------------------------------------------------------------------------------------------
+func TestLoad_ConfigFileUnderEnvironment(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{
+			name: "yaml",
+			file: "config.yaml",
+			content: `
+groq_api_key: from-file
+MODEL: file-model
+max_tokens: 2048
+llm_request_timeout: 90s
+redis_tls: true
+tools_enabled: [calculator, current_time]
+`,
+		},
+		{
+			name:    "json",
+			file:    "config.json",
+			content: `{"groq_api_key": "from-file", "model": "file-model", "max_tokens": 2048, "llm_request_timeout": "90s", "redis_tls": true, "tools_enabled": ["calculator", "current_time"]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writeConfigFile(t, tt.file, tt.content)
+			t.Setenv("MODEL", "env-model")
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			if cfg.Model != "env-model" {
+				t.Errorf("Model = %q, want the environment to win", cfg.Model)
+			}
+			if cfg.GroqAPIKey != "from-file" || cfg.MaxTokens != 2048 || !cfg.RedisTLS {
+				t.Errorf("file settings not applied: key %q, max tokens %d, redis tls %v", cfg.GroqAPIKey, cfg.MaxTokens, cfg.RedisTLS)
+			}
+			if cfg.LLMRequestTimeout != 90*time.Second {
+				t.Errorf("LLMRequestTimeout = %v, want 90s", cfg.LLMRequestTimeout)
+			}
+			if want := []string{"calculator", "current_time"}; !reflect.DeepEqual(cfg.ToolsEnabled, want) {
+				t.Errorf("ToolsEnabled = %v, want %v", cfg.ToolsEnabled, want)
+			}
+			if cfg.HTTPWriteTimeout != 60*time.Second {
+				t.Errorf("HTTPWriteTimeout = %v, want the 60s default", cfg.HTTPWriteTimeout)
+			}
+		})
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func TestLoad_EmptyEnvironmentClearsFileValue(t *testing.T) {
+	writeConfigFile(t, "config.yaml", "groq_api_key: from-file\nmax_tokens: 2048\ntools_enabled: [calculator]\n")
+	t.Setenv("MAX_TOKENS", "")
+	t.Setenv("TOOLS_ENABLED", "")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.MaxTokens != 1024 {
+		t.Errorf("MaxTokens = %d, want the 1024 default", cfg.MaxTokens)
+	}
+	if len(cfg.ToolsEnabled) != 0 {
+		t.Errorf("ToolsEnabled = %v, want none", cfg.ToolsEnabled)
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func TestLoad_ReportsEveryProblem(t *testing.T) {
+	writeConfigFile(t, "config.yaml", `
+max_tokns: 10
+audit_sink: kafka
+rag_index:
+  kind: memory
+`)
+	t.Setenv("GROQ_API_KEY", "")
+	t.Setenv("MAX_TOKENS", "lots")
+	t.Setenv("HTTP_READ_TIMEOUT", "15")
+	t.Setenv("MAX_CANDIDATES", "0")
+	t.Setenv("INJECTION_THRESHOLD", "2")
+
+	_, err := Load()
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Load() error = %v, want a ValidationError", err)
+	}
+
+	wantPrefixes := []string{
+		"RAG_INDEX: must be a value or a list",
+		"MAX_TOKENS: \"lots\" is not an integer (from environment)",
+		"HTTP_READ_TIMEOUT: \"15\" is not a duration",
+		"MAX_TOKNS: unknown setting",
+		"GROQ_API_KEY: required",
+		"MAX_CANDIDATES: must be positive",
+		"INJECTION_THRESHOLD: must be between 0 and 1",
+		"AUDIT_SINK: unknown value \"kafka\"",
+	}
+	for _, prefix := range wantPrefixes {
+		found := false
+		for _, problem := range validationErr.Problems {
+			found = found || strings.HasPrefix(problem, prefix)
+		}
+		if !found {
+			t.Errorf("missing problem %q in:\n%v", prefix, err)
+		}
+	}
+	if len(validationErr.Problems) != len(wantPrefixes) {
+		t.Errorf("got %d problems, want %d:\n%v", len(validationErr.Problems), len(wantPrefixes), err)
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func TestLoad_MissingConfigFile(t *testing.T) {
+	t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), "missing.yaml"))
+	t.Setenv("GROQ_API_KEY", "test")
+
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "failed to read config file") {
+		t.Errorf("Load() error = %v, want a read error", err)
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func TestLoad_RedisDeployment(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{name: "standalone", env: map[string]string{"REDIS_ADDR": "redis:6379"}},
+		{name: "sentinel", env: map[string]string{"REDIS_MODE": "sentinel", "REDIS_ADDR": "s1:26379, s2:26379", "REDIS_MASTER_NAME": "mymaster"}},
+		{name: "cluster", env: map[string]string{"REDIS_MODE": "cluster", "REDIS_ADDR": "n1:6379,n2:6379"}},
+		{name: "standalone with several addresses", env: map[string]string{"REDIS_ADDR": "a:6379,b:6379"}, wantErr: "REDIS_ADDR: standalone mode takes one address"},
+		{name: "sentinel without master", env: map[string]string{"REDIS_MODE": "sentinel"}, wantErr: "REDIS_MASTER_NAME: required"},
+		{name: "cluster with a database", env: map[string]string{"REDIS_MODE": "cluster", "REDIS_DB": "2"}, wantErr: "REDIS_DB: must be 0 in cluster mode"},
+		{name: "tls files without tls", env: map[string]string{"REDIS_TLS_CA_FILE": "ca.pem"}, wantErr: "REDIS_TLS_CA_FILE, REDIS_TLS_CERT_FILE and REDIS_TLS_KEY_FILE: require REDIS_TLS=true"},
+		{name: "missing ca file", env: map[string]string{"REDIS_TLS": "true", "REDIS_TLS_CA_FILE": filepath.Join(t.TempDir(), "missing.pem")}, wantErr: "REDIS_TLS_CA_FILE:"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GROQ_API_KEY", "test")
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			cfg, err := Load()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Load() error = %v", err)
+				}
+				if _, err := cfg.RedisOptions(); err != nil {
+					t.Errorf("RedisOptions() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// fakeChatService records the settings a reload applies
+type fakeChatService struct {
+	service.ChatService
+	limits       service.Limits
+	model        string
+	maxExchanges int
+}
+
+func (f *fakeChatService) Limits() service.Limits          { return f.limits }
+func (f *fakeChatService) SetLimits(limits service.Limits) { f.limits = limits }
+func (f *fakeChatService) SetModel(model string) error {
+	f.model = model
+	return nil
+}
+func (f *fakeChatService) SetMaxExchanges(maxExchanges int) error {
+	f.maxExchanges = maxExchanges
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+func TestReloader_Reload(t *testing.T) {
+	t.Setenv("GROQ_API_KEY", "test")
+	path := writeConfigFile(t, "config.yaml", "model: first\nmax_tokens: 100\nredis_addr: redis-a:6379\n")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	chat := &fakeChatService{}
+	reloader := cfg.NewReloader(chat, zap.NewNop())
+
+	rewrite := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("failed to rewrite config file: %v", err)
+		}
+	}
+
+	rewrite("model: second\nmax_tokens: 200\nmax_candidates: 2\nmax_exchanges: 5\nredis_addr: redis-b:6379\n")
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if chat.model != "second" {
+		t.Errorf("model = %q, want second", chat.model)
+	}
+	if chat.limits.MaxTokens != 200 || chat.limits.MaxCandidates != 2 {
+		t.Errorf("limits = %+v, want max tokens 200 and max candidates 2", chat.limits)
+	}
+	if chat.maxExchanges != 5 {
+		t.Errorf("max exchanges = %d, want 5", chat.maxExchanges)
+	}
+	if reloader.current.RedisAddr != "redis-a:6379" {
+		t.Errorf("RedisAddr = %q, want the running value until a restart", reloader.current.RedisAddr)
+	}
+
+	// An invalid file is rejected as a whole
+	rewrite("model: third\nmax_tokens: -1\n")
+	if err := reloader.Reload(); err == nil {
+		t.Fatal("Reload() accepted an invalid configuration")
+	}
+	if chat.model != "second" || chat.limits.MaxTokens != 200 {
+		t.Errorf("rejected reload changed the service: model %q, limits %+v", chat.model, chat.limits)
+	}
+}
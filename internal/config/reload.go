@@ -0,0 +1,165 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+
+	"llm-chat-service/internal/logging"
+	"llm-chat-service/internal/metrics"
+	"llm-chat-service/internal/service"
+
+	"go.uber.org/zap"
+)
+
+// reloadableFields are the Config fields a reload applies to the running service. Changes to any
+// other field are only reported; they take effect after a restart. The service has no configurable
+// prompt, so there is no prompt setting to reload, and the guard model of content moderation has its
+// own client, built at startup, so it needs a restart too.
+var reloadableFields = map[string]bool{
+	"Model":                      true,
+	"MaxExchanges":               true,
+	"MaxTokens":                  true,
+	"MaxToolIterations":          true,
+	"StructuredOutputMaxRetries": true,
+	"AutoContinueMax":            true,
+	"AutoContinueMaxTokens":      true,
+	"MaxCandidates":              true,
+	"LogLevel":                   true,
+}
+
+// Reloader re-reads the configuration while the service runs and applies its reloadable settings
+type Reloader struct {
+	mu          sync.Mutex
+	current     *Config
+	chatService service.Reconfigurable // Nil if the chat service cannot be reconfigured
+	logger      *zap.Logger
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewReloader creates a reloader for a service started with this configuration
+func (c *Config) NewReloader(chatService service.ChatService, logger *zap.Logger) *Reloader {
+	reconfigurable, _ := chatService.(service.Reconfigurable)
+	return &Reloader{current: c, chatService: reconfigurable, logger: logger}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// ChatLimits returns the generation limits of the chat service
+func (c *Config) ChatLimits() service.Limits {
+	return service.Limits{
+		MaxTokens:               c.MaxTokens,
+		MaxToolIterations:       c.MaxToolIterations,
+		StructuredOutputRetries: c.StructuredOutputMaxRetries,
+		MaxContinuations:        c.AutoContinueMax,
+		MaxContinuationTokens:   c.AutoContinueMaxTokens,
+		MaxCandidates:           c.MaxCandidates,
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Reload loads the configuration again and applies the reloadable settings. An invalid configuration
+// is rejected as a whole and the running settings are kept.
+func (r *Reloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	next, err := Load()
+	if err != nil {
+		metrics.ConfigReloadsTotal.WithLabelValues("rejected").Inc()
+		r.logger.Error("Configuration reload rejected, keeping the running settings", zap.Error(err))
+		return err
+	}
+
+	changed := changedFields(r.current, next)
+	var applied, pending []string
+	for _, field := range changed {
+		if r.apply(field, next) {
+			applied = append(applied, field)
+		} else {
+			pending = append(pending, field)
+		}
+	}
+
+	metrics.ConfigReloadsTotal.WithLabelValues("applied").Inc()
+	r.logger.Info("Configuration reloaded", zap.Strings("applied", applied))
+	if len(pending) > 0 {
+		r.logger.Warn("Some changed settings only take effect after a restart", zap.Strings("settings", pending))
+	}
+
+	// Settings waiting for a restart keep being reported until they are applied
+	for _, field := range pending {
+		reflect.ValueOf(next).Elem().FieldByName(field).Set(reflect.ValueOf(r.current).Elem().FieldByName(field))
+	}
+	r.current = next
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// apply hands the new value of field to the running service and reports whether it took effect
+func (r *Reloader) apply(field string, next *Config) bool {
+	if !reloadableFields[field] {
+		return false
+	}
+	switch field {
+	case "LogLevel":
+		return logging.Level.UnmarshalText([]byte(next.LogLevel)) == nil
+	case "Model":
+		if r.chatService == nil {
+			return false
+		}
+		if err := r.chatService.SetModel(next.Model); err != nil {
+			r.logger.Warn("Cannot change the model without a restart", zap.Error(err))
+			return false
+		}
+		return true
+	case "MaxExchanges":
+		if r.chatService == nil {
+			return false
+		}
+		if err := r.chatService.SetMaxExchanges(next.MaxExchanges); err != nil {
+			r.logger.Warn("Cannot change the history size without a restart", zap.Error(err))
+			return false
+		}
+		return true
+	default:
+		if r.chatService == nil {
+			return false
+		}
+		r.chatService.SetLimits(next.ChatLimits())
+		return true
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// WatchSignals reloads the configuration on every SIGHUP until ctx is done
+func (r *Reloader) WatchSignals(ctx context.Context) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangups:
+			r.logger.Info("Received SIGHUP, reloading configuration")
+			_ = r.Reload()
+		}
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// changedFields lists the fields that differ between two configurations
+func changedFields(current, next *Config) []string {
+	var changed []string
+	a, b := reflect.ValueOf(current).Elem(), reflect.ValueOf(next).Elem()
+	for i := 0; i < a.NumField(); i++ {
+		if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			changed = append(changed, a.Type().Field(i).Name)
+		}
+	}
+	return changed
+}
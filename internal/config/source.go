@@ -0,0 +1,168 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// source resolves each setting from the environment, then the config file, then its default. Values
+// that cannot be parsed are recorded as problems instead of silently falling back to the default.
+type source struct {
+	path     string            // Config file path, empty when there is none
+	file     map[string]string // Config file values keyed by environment variable name
+	used     map[string]bool
+	problems []string
+}
+
+// ------------------------------------------------------------------------------------------------------
+// newSource reads the config file at path, if any. The file is a flat YAML (or JSON) mapping whose
+// keys are the environment variable names, in any case; lists are joined with commas.
+func newSource(path string) (*source, error) {
+	s := &source{path: path, file: map[string]string{}, used: map[string]bool{}}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var values map[string]any
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	for key, value := range values {
+		key = strings.ToUpper(key)
+		switch v := value.(type) {
+		case nil:
+		case map[string]any:
+			s.problemf("%s: must be a value or a list, not a mapping (in %s)", key, path)
+		case []any:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			s.file[key] = strings.Join(items, ",")
+		default:
+			s.file[key] = fmt.Sprint(v)
+		}
+	}
+	return s, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (s *source) problemf(format string, args ...any) {
+	s.problems = append(s.problems, fmt.Sprintf(format, args...))
+}
+
+// ------------------------------------------------------------------------------------------------------
+// lookup returns the raw value of key and where it came from. A variable set in the environment hides
+// the file even when it is empty, which leaves the setting at its default.
+func (s *source) lookup(key string) (value, origin string, ok bool) {
+	s.used[key] = true
+	if value, ok := os.LookupEnv(key); ok {
+		return value, "environment", value != ""
+	}
+	if value, ok := s.file[key]; ok && value != "" {
+		return value, s.path, true
+	}
+	return "", "", false
+}
+
+// ------------------------------------------------------------------------------------------------------
+// unknownKeys reports config file keys that no setting reads, which are most likely typos
+func (s *source) unknownKeys() {
+	var unknown []string
+	for key := range s.file {
+		if !s.used[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		s.problemf("%s: unknown setting (in %s)", key, s.path)
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (s *source) getString(key, defaultValue string) string {
+	if value, _, ok := s.lookup(key); ok {
+		return value
+	}
+	return defaultValue
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (s *source) getInt(key string, defaultValue int) int {
+	valueStr, origin, ok := s.lookup(key)
+	if !ok {
+		return defaultValue
+	}
+	value, err := strconv.Atoi(valueStr)
+	if err != nil {
+		s.problemf("%s: %q is not an integer (from %s)", key, valueStr, origin)
+		return defaultValue
+	}
+	return value
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (s *source) getBool(key string, defaultValue bool) bool {
+	valueStr, origin, ok := s.lookup(key)
+	if !ok {
+		return defaultValue
+	}
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		s.problemf("%s: %q is not a boolean (from %s)", key, valueStr, origin)
+		return defaultValue
+	}
+	return value
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (s *source) getFloat(key string, defaultValue float64) float64 {
+	valueStr, origin, ok := s.lookup(key)
+	if !ok {
+		return defaultValue
+	}
+	value, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		s.problemf("%s: %q is not a number (from %s)", key, valueStr, origin)
+		return defaultValue
+	}
+	return value
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (s *source) getDuration(key string, defaultValue time.Duration) time.Duration {
+	valueStr, origin, ok := s.lookup(key)
+	if !ok {
+		return defaultValue
+	}
+	value, err := time.ParseDuration(valueStr)
+	if err != nil {
+		s.problemf("%s: %q is not a duration such as 30s or 2m (from %s)", key, valueStr, origin)
+		return defaultValue
+	}
+	return value
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (s *source) getList(key, defaultValue string) []string {
+	var values []string
+	for _, value := range strings.Split(s.getString(key, defaultValue), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"llm-chat-service/internal/logging"
+	"llm-chat-service/internal/moderation"
+	"llm-chat-service/internal/redisconn"
+	"llm-chat-service/internal/service"
+	"llm-chat-service/internal/tracing"
+)
+
+// ValidationError lists every problem found in the configuration
+type ValidationError struct {
+	Problems []string
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Validate checks ranges, enumerations and settings that depend on each other
+func (c *Config) Validate() error {
+	if problems := c.problems(); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (c *Config) problems() []string {
+	var problems []string
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+	oneOf := func(key, value string, allowed ...string) {
+		for _, a := range allowed {
+			if value == a {
+				return
+			}
+		}
+		problems = append(problems, fmt.Sprintf("%s: unknown value %q, must be one of %s", key, value, strings.Join(allowed, ", ")))
+	}
+	positive := func(key string, value int) {
+		check(value > 0, "%s: must be positive, got %d", key, value)
+	}
+	notNegative := func(key string, value int) {
+		check(value >= 0, "%s: must not be negative, got %d", key, value)
+	}
+	positiveDuration := func(key string, value time.Duration) {
+		check(value > 0, "%s: must be positive, got %v", key, value)
+	}
+	notNegativeDuration := func(key string, value time.Duration) {
+		check(value >= 0, "%s: must not be negative, got %v", key, value)
+	}
+	validates := func(err error) {
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+
+	// Replayed traffic never reaches the provider, so no API key is needed
+	check(c.GroqAPIKey != "" || c.LLMCassetteMode == "replay", "GROQ_API_KEY: required")
+	port, err := strconv.Atoi(c.Port)
+	check(err == nil && port > 0 && port <= 65535, "PORT: %q is not a valid port", c.Port)
+
+	notNegative("REDIS_DB", c.RedisDB)
+	oneOf("REDIS_MODE", c.RedisMode, redisconn.ModeStandalone, redisconn.ModeSentinel, redisconn.ModeCluster)
+	addrs := redisAddrs(c.RedisAddr)
+	switch c.RedisMode {
+	case redisconn.ModeStandalone:
+		check(len(addrs) == 1, "REDIS_ADDR: standalone mode takes one address, got %d", len(addrs))
+	case redisconn.ModeSentinel:
+		check(len(addrs) > 0, "REDIS_ADDR: required, the Sentinel addresses")
+		check(c.RedisMasterName != "", "REDIS_MASTER_NAME: required in sentinel mode")
+	case redisconn.ModeCluster:
+		check(len(addrs) > 0, "REDIS_ADDR: required, the cluster seed nodes")
+		check(c.RedisDB == 0, "REDIS_DB: must be 0 in cluster mode, got %d", c.RedisDB)
+	}
+	if c.RedisTLS {
+		_, err = c.redisTLSConfig()
+		validates(err)
+	} else {
+		check(c.RedisTLSCAFile == "" && c.RedisTLSCertFile == "" && c.RedisTLSKeyFile == "",
+			"REDIS_TLS_CA_FILE, REDIS_TLS_CERT_FILE and REDIS_TLS_KEY_FILE: require REDIS_TLS=true")
+	}
+
+	// Zero disables an HTTP server timeout, as in net/http
+	notNegativeDuration("HTTP_READ_TIMEOUT", c.HTTPReadTimeout)
+	notNegativeDuration("HTTP_WRITE_TIMEOUT", c.HTTPWriteTimeout)
+	notNegativeDuration("HTTP_IDLE_TIMEOUT", c.HTTPIdleTimeout)
+	positiveDuration("SHUTDOWN_TIMEOUT", c.ShutdownTimeout)
+	notNegativeDuration("SHUTDOWN_DELAY", c.ShutdownDelay)
+	positiveDuration("SHUTDOWN_DRAIN_TIMEOUT", c.ShutdownDrainTimeout)
+	positiveDuration("LLM_REQUEST_TIMEOUT", c.LLMRequestTimeout)
+	positiveDuration("CLIENT_TOOL_TIMEOUT", c.ClientToolTimeout)
+	positiveDuration("HEALTH_CHECK_TIMEOUT", c.HealthCheckTimeout)
+	notNegativeDuration("HEALTH_LLM_PROBE_CACHE", c.HealthLLMProbeCache)
+
+	positive("MAX_TOKENS", c.MaxTokens)
+	positive("MAX_EXCHANGES", c.MaxExchanges)
+	positive("MAX_CANDIDATES", c.MaxCandidates)
+	positive("MAX_TOOL_ITERATIONS", c.MaxToolIterations)
+	notNegative("STRUCTURED_OUTPUT_MAX_RETRIES", c.StructuredOutputMaxRetries)
+	notNegative("AUTO_CONTINUE_MAX", c.AutoContinueMax)
+	positive("AUTO_CONTINUE_MAX_TOKENS", c.AutoContinueMaxTokens)
+
+	oneOf("LLM_CASSETTE_MODE", c.LLMCassetteMode, "off", "record", "replay")
+	oneOf("TRACING_EXPORTER", c.TracingExporter, tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP)
+	validates(logging.ValidateOptions(c.LoggingOptions()))
+
+	oneOf("RAG_INDEX", c.RAGIndex, "none", "memory", "redis")
+	oneOf("RAG_EMBEDDER", c.RAGEmbedder, "hash", "http")
+	if c.RAGIndex != "none" && c.RAGEmbedder == "http" {
+		check(c.RAGEmbeddingURL != "" && c.RAGEmbeddingModel != "",
+			"RAG_EMBEDDING_URL and RAG_EMBEDDING_MODEL: required for the http embedder")
+	}
+	positive("RAG_EMBEDDING_DIMENSIONS", c.RAGEmbeddingDimensions)
+	positive("RAG_CHUNK_TOKENS", c.RAGChunkTokens)
+	check(c.RAGChunkOverlap >= 0 && c.RAGChunkOverlap < c.RAGChunkTokens,
+		"RAG_CHUNK_OVERLAP: must be at least 0 and less than RAG_CHUNK_TOKENS, got %d", c.RAGChunkOverlap)
+	positive("RAG_TOP_K", c.RAGTopK)
+	positive("RAG_MAX_DOCUMENT_MB", c.RAGMaxDocumentMB)
+
+	for _, name := range c.ModerationClassifiers {
+		oneOf("MODERATION_CLASSIFIERS", name, "keyword", "guard")
+	}
+	_, err = moderation.ParseAction(c.ModerationInputAction)
+	validates(prefixed("MODERATION_INPUT_ACTION", err))
+	_, err = moderation.ParseAction(c.ModerationOutputAction)
+	validates(prefixed("MODERATION_OUTPUT_ACTION", err))
+
+	if c.InjectionAction != "none" {
+		_, err = service.ParseInjectionAction(c.InjectionAction)
+		validates(prefixed("INJECTION_ACTION", err))
+		check(c.InjectionThreshold > 0 && c.InjectionThreshold <= 1,
+			"INJECTION_THRESHOLD: must be between 0 and 1, got %v", c.InjectionThreshold)
+	}
+
+	oneOf("AUDIT_SINK", c.AuditSink, "none", "file", "redis")
+	positive("AUDIT_FILE_MAX_SIZE_MB", c.AuditFileMaxSizeMB)
+	notNegative("AUDIT_FILE_MAX_BACKUPS", c.AuditFileMaxBackups)
+	notNegative("AUDIT_REDIS_MAX_LEN", c.AuditRedisMaxLen)
+	positive("AUDIT_BUFFER_SIZE", c.AuditBufferSize)
+
+	return problems
+}
+
+// ------------------------------------------------------------------------------------------------------
+func prefixed(key string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%s: %w", key, err)
+}
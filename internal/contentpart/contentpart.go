@@ -0,0 +1,151 @@
+// Package contentpart holds the multimodal message content of the OpenAI chat format, shared by the
+// stored and the provider message types: the parts themselves and the string-or-parts JSON codec
+package contentpart
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// Part types
+const (
+	TypeText     = "text"
+	TypeImageURL = "image_url"
+)
+
+// Part is one element of a multimodal message
+type Part struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+// ImageURL references an image by http(s) URL or base64 data URI
+type ImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Decode reads a message content field, which is a string, null, or an array of parts. For parts, the
+// returned text joins the text parts so text-only consumers keep working.
+func Decode(raw json.RawMessage) (string, []Part, error) {
+	content := bytes.TrimSpace(raw)
+	switch {
+	case len(content) == 0 || bytes.Equal(content, []byte("null")):
+		return "", nil, nil
+	case content[0] == '"':
+		var text string
+		err := json.Unmarshal(content, &text)
+		return text, nil, err
+	case content[0] == '[':
+		var parts []Part
+		if err := json.Unmarshal(content, &parts); err != nil {
+			return "", nil, err
+		}
+		return Text(parts), parts, nil
+	default:
+		return "", nil, errors.New("message content must be a string or an array of content parts")
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// MarshalContent encodes message, a message type without its own JSON methods whose "content" field
+// holds the text, writing parts in place of the text when there are any
+func MarshalContent(message any, parts []Part) ([]byte, error) {
+	data, err := json.Marshal(message)
+	if err != nil || len(parts) == 0 {
+		return data, err
+	}
+	content, err := json.Marshal(parts)
+	if err != nil {
+		return nil, err
+	}
+	data, _, err = replaceContent(data, content)
+	return data, err
+}
+
+// ------------------------------------------------------------------------------------------------------
+// UnmarshalContent decodes data into message, a message type without its own JSON methods, and returns
+// the content as Decode does. The content field of message itself is left empty.
+func UnmarshalContent(data []byte, message any) (string, []Part, error) {
+	data, content, err := replaceContent(data, []byte("null"))
+	if err != nil {
+		return "", nil, err
+	}
+	if err := json.Unmarshal(data, message); err != nil {
+		return "", nil, err
+	}
+	return Decode(content)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// replaceContent replaces the value of the "content" field of a JSON object, adding the field when it
+// is missing, and returns the object with the previous value. Other fields keep their order.
+func replaceContent(data, content []byte) ([]byte, json.RawMessage, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil {
+		return nil, nil, err
+	} else if token != json.Delim('{') {
+		return nil, nil, errors.New("message must be a JSON object")
+	}
+
+	var out bytes.Buffer
+	var previous json.RawMessage
+	out.WriteByte('{')
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key, _ := token.(string)
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err != nil {
+			return nil, nil, err
+		}
+		if key == "content" {
+			previous, value = value, content
+		}
+		if out.Len() > 1 {
+			out.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		out.Write(name)
+		out.WriteByte(':')
+		out.Write(value)
+	}
+	if previous == nil {
+		if out.Len() > 1 {
+			out.WriteByte(',')
+		}
+		out.WriteString(`"content":`)
+		out.Write(content)
+	}
+	out.WriteByte('}')
+	return out.Bytes(), previous, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Text joins the text parts of a multimodal message
+func Text(parts []Part) string {
+	var texts []string
+	for _, part := range parts {
+		if part.Type == TypeText && part.Text != "" {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// ------------------------------------------------------------------------------------------------------
+// HasImages reports whether parts contain an image
+func HasImages(parts []Part) bool {
+	for _, part := range parts {
+		if part.Type == TypeImageURL {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package contentpart
+
+import (
+	"reflect"
+	"testing"
+)
+
+// message stands for a message type without its own JSON methods
+type message struct {
+	Role    string `json:"role"`
+	Content string `json:"content,omitempty"`
+	Name    string `json:"name,omitempty"`
+}
+
+func TestMarshalContent(t *testing.T) {
+	parts := []Part{{Type: TypeText, Text: "Describe"}}
+	tests := []struct {
+		name  string
+		msg   message
+		parts []Part
+		want  string
+	}{
+		{name: "text", msg: message{Role: "user", Content: "Hello", Name: "bob"}, want: `{"role":"user","content":"Hello","name":"bob"}`},
+		{name: "parts keep the field order", msg: message{Role: "user", Content: "Describe", Name: "bob"}, parts: parts, want: `{"role":"user","content":[{"type":"text","text":"Describe"}],"name":"bob"}`},
+		{name: "parts without text", msg: message{Role: "user"}, parts: parts, want: `{"role":"user","content":[{"type":"text","text":"Describe"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := MarshalContent(tt.msg, tt.parts)
+			if err != nil {
+				t.Fatalf("MarshalContent() error = %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("MarshalContent() = %s, want %s", data, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalContent(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		wantText string
+		wantPart []Part
+		wantErr  bool
+	}{
+		{name: "text", input: `{"role":"user","content":"Hello","name":"bob"}`, wantText: "Hello"},
+		{name: "parts", input: `{"role":"user","content":[{"type":"text","text":"Describe"}],"name":"bob"}`, wantText: "Describe", wantPart: []Part{{Type: TypeText, Text: "Describe"}}},
+		{name: "no content", input: `{"role":"user","name":"bob"}`},
+		{name: "invalid content", input: `{"role":"user","content":42}`, wantErr: true},
+		{name: "not an object", input: `[]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var msg message
+			text, parts, err := UnmarshalContent([]byte(tt.input), &msg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UnmarshalContent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if text != tt.wantText || !reflect.DeepEqual(parts, tt.wantPart) {
+				t.Errorf("UnmarshalContent() = %q, %+v, want %q, %+v", text, parts, tt.wantText, tt.wantPart)
+			}
+			if msg.Role != "user" || msg.Name != "bob" || msg.Content != "" {
+				t.Errorf("message = %+v, want the other fields decoded and no content", msg)
+			}
+		})
+	}
+}
@@ -17,6 +17,11 @@ const (
 	ErrorTypeInternal     ErrorType = "internal_error"
 	ErrorTypeNotFound     ErrorType = "not_found"
 	ErrorTypeUnauthorized ErrorType = "unauthorized_error"
+
+	ErrorTypeOutputValidation ErrorType = "output_validation_error"
+	ErrorTypeModeration       ErrorType = "moderation_error"
+	ErrorTypePromptInjection  ErrorType = "prompt_injection_error"
+	ErrorTypeUnavailable      ErrorType = "service_unavailable"
 )
 
 // AppError represents a structured application error
@@ -92,6 +97,17 @@ func NewInternalError(message string, err error) *AppError {
 	}
 }
 
+// ------------------------------------------------------------------------------------------------------
+// NewNotFoundError creates a not found error
+func NewNotFoundError(message string, err error) *AppError {
+	return &AppError{
+		Type:       ErrorTypeNotFound,
+		Message:    message,
+		StatusCode: http.StatusNotFound,
+		Err:        err,
+	}
+}
+
 // ------------------------------------------------------------------------------------------------------
 // NewUnauthorizedError creates an unauthorized error
 func NewUnauthorizedError(message string, err error) *AppError {
@@ -103,6 +119,51 @@ func NewUnauthorizedError(message string, err error) *AppError {
 	}
 }
 
+// ------------------------------------------------------------------------------------------------------
+// NewOutputValidationError creates an error for model output that does not match the requested format
+func NewOutputValidationError(message string, err error) *AppError {
+	return &AppError{
+		Type:       ErrorTypeOutputValidation,
+		Message:    message,
+		StatusCode: http.StatusUnprocessableEntity,
+		Err:        err,
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewModerationError creates an error for content blocked by the moderation pipeline
+func NewModerationError(message string, err error) *AppError {
+	return &AppError{
+		Type:       ErrorTypeModeration,
+		Message:    message,
+		StatusCode: http.StatusUnprocessableEntity,
+		Err:        err,
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewPromptInjectionError creates an error for a message rejected by the prompt-injection detector
+func NewPromptInjectionError(message string, err error) *AppError {
+	return &AppError{
+		Type:       ErrorTypePromptInjection,
+		Message:    message,
+		StatusCode: http.StatusUnprocessableEntity,
+		Err:        err,
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewUnavailableError creates an error for requests the service cannot take right now, e.g. while
+// shutting down
+func NewUnavailableError(message string, err error) *AppError {
+	return &AppError{
+		Type:       ErrorTypeUnavailable,
+		Message:    message,
+		StatusCode: http.StatusServiceUnavailable,
+		Err:        err,
+	}
+}
+
 // ------------------------------------------------------------------------------------------------------
 // GetHTTPStatusCode returns the appropriate HTTP status code for an error
 func GetHTTPStatusCode(err error) int {
@@ -134,9 +195,18 @@ type ErrorResponse struct {
 // ------------------------------------------------------------------------------------------------------
 // ErrorDetail contains error details
 type ErrorDetail struct {
-	Type    ErrorType `json:"type"`
-	Message string    `json:"message"`
-	Code    string    `json:"code,omitempty"`
+	Type      ErrorType `json:"type"`
+	Message   string    `json:"message"`
+	Code      string    `json:"code,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewErrorResponseWithRequestID creates a standardized error response tagged with the request ID
+func NewErrorResponseWithRequestID(err error, requestID string) ErrorResponse {
+	response := NewErrorResponse(err)
+	response.Error.RequestID = requestID
+	return response
 }
 
 // ------------------------------------------------------------------------------------------------------
@@ -0,0 +1,200 @@
+// Package health runs the dependency checks behind the readiness probe
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"llm-chat-service/internal/metrics"
+)
+
+// Status of a component or of the whole service
+const (
+	StatusUp       = "up"
+	StatusDegraded = "degraded" // A non-critical component is down; the service still answers
+	StatusDown     = "down"
+)
+
+// defaultTimeout bounds each check when not configured
+const defaultTimeout = 2 * time.Second
+
+// Checker reports whether a dependency is usable
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function to the Checker interface
+type CheckerFunc func(ctx context.Context) error
+
+// ------------------------------------------------------------------------------------------------------
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Report is the readiness of the service and of each of its components
+type Report struct {
+	Status       string                     `json:"status"`
+	ShuttingDown bool                       `json:"shutting_down,omitempty"`
+	Components   map[string]ComponentStatus `json:"components,omitempty"`
+}
+
+// ComponentStatus is the outcome of one check
+type ComponentStatus struct {
+	Status    string `json:"status"`
+	Critical  bool   `json:"critical"`
+	Error     string `json:"error,omitempty"`
+	LatencyMs int64  `json:"latency_ms"`
+}
+
+type component struct {
+	name     string
+	checker  Checker
+	critical bool
+}
+
+// Health holds the registered checks and whether the service is shutting down
+type Health struct {
+	timeout      time.Duration
+	mu           sync.RWMutex
+	components   []component
+	shuttingDown atomic.Bool
+}
+
+// ------------------------------------------------------------------------------------------------------
+// New creates an empty set of checks, each bounded by timeout
+func New(timeout time.Duration) *Health {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &Health{timeout: timeout}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Register adds a check. A failing critical component makes the service not ready; a failing
+// non-critical one only degrades it.
+func (h *Health) Register(name string, checker Checker, critical bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.components = append(h.components, component{name: name, checker: checker, critical: critical})
+}
+
+// ------------------------------------------------------------------------------------------------------
+// SetShuttingDown makes the service report not ready from now on, so load balancers stop routing to it
+func (h *Health) SetShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// ShuttingDown reports whether SetShuttingDown was called
+func (h *Health) ShuttingDown() bool {
+	return h.shuttingDown.Load()
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Check runs every check concurrently and aggregates the results
+func (h *Health) Check(ctx context.Context) Report {
+	h.mu.RLock()
+	components := append([]component(nil), h.components...)
+	h.mu.RUnlock()
+
+	statuses := make([]ComponentStatus, len(components))
+	var wg sync.WaitGroup
+	for i, c := range components {
+		i, c := i, c
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i] = h.run(ctx, c)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Components: make(map[string]ComponentStatus, len(components))}
+	for i, c := range components {
+		status := statuses[i]
+		report.Components[c.name] = status
+
+		up := 0.0
+		if status.Status == StatusUp {
+			up = 1
+		}
+		metrics.HealthComponentUp.WithLabelValues(c.name).Set(up)
+
+		switch {
+		case status.Status == StatusUp:
+		case c.critical:
+			report.Status = StatusDown
+		case report.Status == StatusUp:
+			report.Status = StatusDegraded
+		}
+	}
+
+	if h.ShuttingDown() {
+		report.Status = StatusDown
+		report.ShuttingDown = true
+	}
+	return report
+}
+
+// ------------------------------------------------------------------------------------------------------
+// run executes one check within the timeout, even if the checker ignores its context
+func (h *Health) run(ctx context.Context, c component) ComponentStatus {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	result := make(chan error, 1)
+	go func() { result <- c.checker.Check(ctx) }()
+
+	var err error
+	select {
+	case err = <-result:
+	case <-ctx.Done():
+		err = errors.New("check timed out")
+	}
+
+	status := ComponentStatus{Status: StatusUp, Critical: c.critical, LatencyMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		status.Status = StatusDown
+		status.Error = err.Error()
+	}
+	return status
+}
+
+// cachedChecker reuses the last result of an expensive check
+type cachedChecker struct {
+	checker Checker
+	ttl     time.Duration
+
+	mu        sync.Mutex
+	checkedAt time.Time
+	err       error
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Cached returns a checker that runs checker at most once per ttl and reports the last result in
+// between. Use it for checks that cost money or count against rate limits.
+func Cached(checker Checker, ttl time.Duration) Checker {
+	return &cachedChecker{checker: checker, ttl: ttl}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (c *cachedChecker) Check(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.checkedAt.IsZero() && time.Since(c.checkedAt) < c.ttl {
+		return c.err
+	}
+
+	err := c.checker.Check(ctx)
+	if ctx.Err() != nil {
+		// A probe cut short by the caller says nothing about the dependency; do not cache it
+		return err
+	}
+	c.checkedAt, c.err = time.Now(), err
+	return err
+}
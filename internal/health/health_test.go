@@ -0,0 +1,106 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// ------------------------------------------------------------------------------------------------------
+func TestHealth_Check(t *testing.T) {
+	up := CheckerFunc(func(ctx context.Context) error { return nil })
+	down := CheckerFunc(func(ctx context.Context) error { return errors.New("unreachable") })
+
+	tests := []struct {
+		name         string
+		critical     Checker
+		optional     Checker
+		shuttingDown bool
+		want         string
+	}{
+		{name: "all up", critical: up, optional: up, want: StatusUp},
+		{name: "optional down", critical: up, optional: down, want: StatusDegraded},
+		{name: "critical down", critical: down, optional: up, want: StatusDown},
+		{name: "both down", critical: down, optional: down, want: StatusDown},
+		{name: "shutting down", critical: up, optional: up, shuttingDown: true, want: StatusDown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(time.Second)
+			h.Register("critical", tt.critical, true)
+			h.Register("optional", tt.optional, false)
+			if tt.shuttingDown {
+				h.SetShuttingDown()
+			}
+
+			report := h.Check(context.Background())
+			if report.Status != tt.want {
+				t.Errorf("Status = %q, want %q", report.Status, tt.want)
+			}
+			if report.ShuttingDown != tt.shuttingDown {
+				t.Errorf("ShuttingDown = %v, want %v", report.ShuttingDown, tt.shuttingDown)
+			}
+			if len(report.Components) != 2 || !report.Components["critical"].Critical {
+				t.Errorf("Components = %+v, want both components with criticality", report.Components)
+			}
+		})
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func TestHealth_CheckTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	h := New(20 * time.Millisecond)
+	h.Register("stuck", CheckerFunc(func(ctx context.Context) error {
+		<-release // Ignores its context
+		return nil
+	}), true)
+
+	report := h.Check(context.Background())
+	if status := report.Components["stuck"]; status.Status != StatusDown || status.Error != "check timed out" {
+		t.Errorf("stuck component = %+v, want down after the timeout", status)
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func TestCached(t *testing.T) {
+	calls := 0
+	result := errors.New("first failure")
+	checker := Cached(CheckerFunc(func(ctx context.Context) error {
+		calls++
+		return result
+	}), 50*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		if err := checker.Check(context.Background()); err == nil || err.Error() != "first failure" {
+			t.Fatalf("Check() error = %v, want the cached failure", err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1 within the TTL", calls)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	result = nil
+	if err := checker.Check(context.Background()); err != nil {
+		t.Errorf("Check() error = %v after the TTL, want a fresh result", err)
+	}
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2 after the TTL", calls)
+	}
+
+	// A check cut short by its caller is not cached
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	time.Sleep(60 * time.Millisecond)
+	result = ctx.Err()
+	_ = checker.Check(ctx)
+	result = nil
+	if err := checker.Check(context.Background()); err != nil || calls != 4 {
+		t.Errorf("Check() error = %v, calls = %d; want a new probe after a cancelled one", err, calls)
+	}
+}
@@ -0,0 +1,396 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CassetteEntry is one upstream exchange, stored as a line of a JSONL cassette. Only the request body
+// is recorded, so API keys never end up in a cassette.
+type CassetteEntry struct {
+	Hash       string            `json:"hash"`
+	RecordedAt time.Time         `json:"recorded_at"`
+	Request    json.RawMessage   `json:"request"`
+	Status     int               `json:"status"`
+	Header     map[string]string `json:"header,omitempty"`
+	// Body holds non-streamed responses; Chunks holds streamed ones as they were read off the wire
+	Body   string          `json:"body,omitempty"`
+	Chunks []CassetteChunk `json:"chunks,omitempty"`
+}
+
+// CassetteChunk is a piece of a streamed response and when it arrived
+type CassetteChunk struct {
+	OffsetMs int64  `json:"offset_ms"` // Since the response headers
+	Data     string `json:"data"`
+}
+
+// recordedHeaders are the response headers worth keeping in a cassette
+var recordedHeaders = []string{"Content-Type", "X-Request-ID"}
+
+// ErrCassetteMiss is returned when a replayed request has no recorded response
+var ErrCassetteMiss = errors.New("no recorded response for request")
+
+// ------------------------------------------------------------------------------------------------------
+// RequestHash identifies a request body independently of key order and formatting
+func RequestHash(body []byte) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return "", fmt.Errorf("failed to parse request body: %w", err)
+	}
+
+	// Maps marshal with sorted keys, which makes the encoding canonical
+	canonical, err := json.Marshal(normalizeJSON(value))
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// normalizeJSON drops null and empty fields, which clients are free to send or leave out
+func normalizeJSON(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		normalized := make(map[string]any, len(v))
+		for key, field := range v {
+			field = normalizeJSON(field)
+			if isEmptyJSON(field) {
+				continue
+			}
+			normalized[key] = field
+		}
+		return normalized
+	case []any:
+		for i, item := range v {
+			v[i] = normalizeJSON(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func isEmptyJSON(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case map[string]any:
+		return len(v) == 0
+	case []any:
+		return len(v) == 0
+	default:
+		return false
+	}
+}
+
+// Recorder appends the upstream exchanges of a client to a JSONL cassette
+type Recorder struct {
+	path string
+	mu   sync.Mutex
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewRecorder creates a recorder appending to the cassette at path
+func NewRecorder(path string) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cassette: %w", err)
+	}
+	return &Recorder{path: path}, file.Close()
+}
+
+// ------------------------------------------------------------------------------------------------------
+// WithRecorder records every upstream exchange of the client. A nil recorder disables recording.
+func WithRecorder(recorder *Recorder) ClientOption {
+	return func(c *GroqClient) {
+		if recorder == nil {
+			return
+		}
+		base := c.httpClient.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		c.httpClient.Transport = &recordingTransport{base: base, recorder: recorder}
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// write appends entry to the cassette. The file is opened per entry so a crash loses at most one line.
+func (r *Recorder) write(entry CassetteEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+type recordingTransport struct {
+	base     http.RoundTripper
+	recorder *Recorder
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Only completions are recorded; health probes have no place in a replay
+	if req.Method != http.MethodPost {
+		return t.base.RoundTrip(req)
+	}
+
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := CassetteEntry{
+		RecordedAt: time.Now().UTC(),
+		Request:    json.RawMessage(body),
+		Status:     resp.StatusCode,
+		Header:     map[string]string{},
+	}
+	entry.Hash, _ = RequestHash(body)
+	for _, name := range recordedHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			entry.Header[name] = value
+		}
+	}
+
+	resp.Body = &recordingBody{
+		ReadCloser: resp.Body,
+		recorder:   t.recorder,
+		entry:      entry,
+		stream:     strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream"),
+		start:      time.Now(),
+	}
+	return resp, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// readRequestBody reads the request body and puts it back for the real transport
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// recordingBody captures a response body as the client reads it and records the exchange on Close.
+// A body the client stopped reading early is recorded as far as it was read.
+type recordingBody struct {
+	io.ReadCloser
+	recorder *Recorder
+	entry    CassetteEntry
+	stream   bool
+	start    time.Time
+	body     strings.Builder
+	once     sync.Once
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		if b.stream {
+			b.entry.Chunks = append(b.entry.Chunks, CassetteChunk{
+				OffsetMs: time.Since(b.start).Milliseconds(),
+				Data:     string(p[:n]),
+			})
+		} else {
+			b.body.Write(p[:n])
+		}
+	}
+	return n, err
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (b *recordingBody) Close() error {
+	b.once.Do(func() {
+		b.entry.Body = b.body.String()
+		// Recording is a debugging aid and must never fail the request
+		_ = b.recorder.write(b.entry)
+	})
+	return b.ReadCloser.Close()
+}
+
+// Cassette serves recorded exchanges by request hash. Identical requests get their recorded responses
+// in order; once those run out, the last one is repeated.
+type Cassette struct {
+	mu      sync.Mutex
+	entries map[string][]CassetteEntry
+	served  map[string]int
+}
+
+// ------------------------------------------------------------------------------------------------------
+// LoadCassette reads a JSONL cassette written by a Recorder
+func LoadCassette(path string) (*Cassette, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cassette: %w", err)
+	}
+	defer file.Close()
+
+	cassette := &Cassette{entries: map[string][]CassetteEntry{}, served: map[string]int{}}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry CassetteEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("invalid cassette entry on line %d: %w", line, err)
+		}
+		// Re-hash so cassettes stay valid if the normalization changes
+		if hash, err := RequestHash(entry.Request); err == nil {
+			entry.Hash = hash
+		}
+		cassette.entries[entry.Hash] = append(cassette.entries[entry.Hash], entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	return cassette, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Len returns the number of recorded exchanges
+func (c *Cassette) Len() int {
+	n := 0
+	for _, entries := range c.entries {
+		n += len(entries)
+	}
+	return n
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (c *Cassette) next(hash string) (CassetteEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries := c.entries[hash]
+	if len(entries) == 0 {
+		return CassetteEntry{}, false
+	}
+	i := min(c.served[hash], len(entries)-1)
+	c.served[hash]++
+	return entries[i], true
+}
+
+// ReplayClient implements Client by serving responses from a cassette instead of calling the
+// provider. Requests go through the regular GroqClient code path, so replayed responses are parsed,
+// traced and counted exactly like live ones.
+type ReplayClient struct {
+	*GroqClient
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewReplayClient creates a client for model answering from cassette. With realtime set, streamed
+// chunks are delayed as they were when recorded; otherwise they are served immediately.
+func NewReplayClient(cassette *Cassette, model string, realtime bool, opts ...ClientOption) *ReplayClient {
+	client := NewGroqClient("", "http://cassette.invalid/chat/completions", model, opts...)
+	client.httpClient.Transport = &replayTransport{cassette: cassette, realtime: realtime}
+	return &ReplayClient{GroqClient: client}
+}
+
+type replayTransport struct {
+	cassette *Cassette
+	realtime bool
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := RequestHash(body)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, ok := t.cassette.next(hash)
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrCassetteMiss, hash)
+	}
+
+	resp := &http.Response{
+		StatusCode: entry.Status,
+		Status:     fmt.Sprintf("%d %s", entry.Status, http.StatusText(entry.Status)),
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Request:    req,
+	}
+	for name, value := range entry.Header {
+		resp.Header.Set(name, value)
+	}
+
+	if len(entry.Chunks) == 0 {
+		resp.Body = io.NopCloser(strings.NewReader(entry.Body))
+		return resp, nil
+	}
+
+	reader, writer := io.Pipe()
+	go t.replayChunks(req, entry.Chunks, writer)
+	resp.Body = reader
+	return resp, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (t *replayTransport) replayChunks(req *http.Request, chunks []CassetteChunk, writer *io.PipeWriter) {
+	start := time.Now()
+	for _, chunk := range chunks {
+		if t.realtime {
+			select {
+			case <-time.After(time.Until(start.Add(time.Duration(chunk.OffsetMs) * time.Millisecond))):
+			case <-req.Context().Done():
+				writer.CloseWithError(req.Context().Err())
+				return
+			}
+		}
+		if _, err := io.WriteString(writer, chunk.Data); err != nil {
+			return // Reader closed
+		}
+	}
+	writer.Close()
+}
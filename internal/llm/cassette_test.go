@@ -0,0 +1,117 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	apperror "llm-chat-service/internal/error"
+	"llm-chat-service/internal/llm/fakellm"
+)
+
+func TestRequestHash(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  string
+		equal bool
+	}{
+		{"key order and whitespace", `{"model":"m","stream":true}`, `{ "stream": true, "model": "m" }`, true},
+		{"null and empty fields", `{"model":"m","tools":null,"messages":[]}`, `{"model":"m"}`, true},
+		{"different content", `{"messages":[{"role":"user","content":"a"}]}`, `{"messages":[{"role":"user","content":"b"}]}`, false},
+		{"streamed and not", `{"model":"m","stream":true}`, `{"model":"m","stream":false}`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := RequestHash([]byte(tt.a))
+			if err != nil {
+				t.Fatalf("RequestHash() error = %v", err)
+			}
+			b, err := RequestHash([]byte(tt.b))
+			if err != nil {
+				t.Fatalf("RequestHash() error = %v", err)
+			}
+			if (a == b) != tt.equal {
+				t.Errorf("hashes equal = %v, want %v", a == b, tt.equal)
+			}
+		})
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	server := fakellm.NewServer(
+		fakellm.Response{
+			Content:   "Recorded stream.",
+			Usage:     &fakellm.Usage{PromptTokens: 5, CompletionTokens: 3, TotalTokens: 8},
+			RequestID: "req_recorded",
+		},
+		fakellm.Response{Content: "First answer."},
+		fakellm.Response{Content: "Second answer."},
+	)
+
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+	recorder, err := NewRecorder(path)
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+
+	messages := []Message{{Role: "user", Content: "Hi"}}
+	stream := func(client Client) ([]string, *Result) {
+		t.Helper()
+		var tokens []string
+		result, err := client.StreamChat(context.Background(), messages, ChatOptions{}, func(token string) error {
+			tokens = append(tokens, token)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("StreamChat() error = %v", err)
+		}
+		return tokens, result
+	}
+	chat := func(client Client) string {
+		t.Helper()
+		result, err := client.Chat(context.Background(), messages, ChatOptions{})
+		if err != nil {
+			t.Fatalf("Chat() error = %v", err)
+		}
+		return result.Content
+	}
+
+	live := NewGroqClient("test-key", server.CompletionsURL(), "llama-test", WithRecorder(recorder))
+	liveTokens, liveResult := stream(live)
+	liveAnswers := []string{chat(live), chat(live)}
+
+	// Replay must not need the upstream any more
+	server.Close()
+
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatalf("LoadCassette() error = %v", err)
+	}
+	if cassette.Len() != 3 {
+		t.Fatalf("cassette has %d entries, want 3", cassette.Len())
+	}
+
+	replay := NewReplayClient(cassette, "llama-test", false)
+	tokens, result := stream(replay)
+	if !reflect.DeepEqual(tokens, liveTokens) {
+		t.Errorf("replayed tokens = %q, want %q", tokens, liveTokens)
+	}
+	if !reflect.DeepEqual(result, liveResult) {
+		t.Errorf("replayed result = %+v, want %+v", result, liveResult)
+	}
+
+	// Identical requests are answered in recorded order, then the last answer repeats
+	answers := []string{chat(replay), chat(replay), chat(replay)}
+	if want := append(liveAnswers, liveAnswers[1]); !reflect.DeepEqual(answers, want) {
+		t.Errorf("replayed answers = %q, want %q", answers, want)
+	}
+
+	_, err = replay.Chat(context.Background(), []Message{{Role: "user", Content: "Never recorded"}}, ChatOptions{})
+	var appErr *apperror.AppError
+	if !errors.As(err, &appErr) || !errors.Is(appErr.Err, ErrCassetteMiss) {
+		t.Errorf("Chat() error = %v, want ErrCassetteMiss", err)
+	}
+}
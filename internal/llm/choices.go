@@ -0,0 +1,22 @@
+package llm
+
+import "context"
+
+// MultipleChoicesClient is implemented by clients that can request several completions in one call
+type MultipleChoicesClient interface {
+	SupportsMultipleChoices() bool
+	ChatChoices(ctx context.Context, messages []Message, opts ChatOptions, n int) ([]*Result, error)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// SupportsMultipleChoices reports whether the provider honours n > 1
+func (c *GroqClient) SupportsMultipleChoices() bool {
+	return c.multipleChoicesSupported
+}
+
+// ------------------------------------------------------------------------------------------------------
+// ChatChoices performs a non-streaming chat completion returning n choices
+func (c *GroqClient) ChatChoices(ctx context.Context, messages []Message, opts ChatOptions, n int) ([]*Result, error) {
+	opts.N = n
+	return c.complete(ctx, "GroqClient.ChatChoices", messages, opts)
+}
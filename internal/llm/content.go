@@ -0,0 +1,29 @@
+package llm
+
+import "llm-chat-service/internal/contentpart"
+
+// ------------------------------------------------------------------------------------------------------
+// MarshalJSON sends content as a string, or as an array of parts for multimodal messages
+func (m Message) MarshalJSON() ([]byte, error) {
+	type plain Message
+	return contentpart.MarshalContent(plain(m), m.Parts)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// UnmarshalJSON accepts content as a string, null, or an array of parts (see contentpart.Decode)
+func (m *Message) UnmarshalJSON(data []byte) error {
+	type plain Message
+	var decoded plain
+	content, parts, err := contentpart.UnmarshalContent(data, &decoded)
+	if err != nil {
+		return err
+	}
+	*m = Message(decoded)
+	m.Content, m.Parts = content, parts
+	return nil
+}
+
+// VisionSupporter is implemented by clients that can report whether the model accepts image parts
+type VisionSupporter interface {
+	SupportsImages() bool
+}
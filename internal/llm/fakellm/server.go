@@ -0,0 +1,290 @@
+// Package fakellm provides a scriptable OpenAI/Groq-compatible chat completions server for tests.
+// It speaks just enough of the wire protocol to exercise the client, the stream scanner and the
+// transports end to end without network access or an API key.
+package fakellm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultContent is served when no response has been scripted
+const defaultContent = "Hello from the fake server."
+
+// Response scripts one reply of the fake server
+type Response struct {
+	// Status is the HTTP status code; anything but 200 is answered with Body as is
+	Status int
+	// Body replaces the generated response body
+	Body string
+
+	Content string
+	// Chunks are the streamed content deltas; by default Content is split after every space
+	Chunks       []string
+	ToolCalls    []ToolCall
+	FinishReason string // Defaults to "stop", or "tool_calls" when ToolCalls are set
+	Usage        *Usage
+	Model        string
+	RequestID    string // Sent as the X-Request-ID header
+
+	// Latency delays the response headers; ChunkDelay delays every streamed chunk
+	Latency    time.Duration
+	ChunkDelay time.Duration
+	// Malformed sends an unparsable chunk before every streamed chunk
+	Malformed bool
+	// Truncate ends the stream after the content, without the final chunk and [DONE]
+	Truncate bool
+}
+
+// ToolCall is a function call requested by the scripted model
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments string
+}
+
+// Usage is the token accounting reported with a response
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Request is a chat completion request received by the server
+type Request struct {
+	Header    http.Header       `json:"-"`
+	Model     string            `json:"model"`
+	Messages  []json.RawMessage `json:"messages"`
+	Stream    bool              `json:"stream"`
+	MaxTokens int               `json:"max_tokens"`
+	N         int               `json:"n"`
+	Tools     []json.RawMessage `json:"tools"`
+}
+
+// Server is a fake chat completions endpoint answering scripted responses in order
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	script     []Response
+	defaultRes Response
+	requests   []Request
+	discard    bool
+	served     int
+
+	modelsStatus int // Status of GET /models; 0 means 200
+	modelsServed int
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewServer starts a fake server that answers the given responses in order, then the default one
+func NewServer(responses ...Response) *Server {
+	s := &Server{script: responses, defaultRes: Response{Content: defaultContent}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// ------------------------------------------------------------------------------------------------------
+// CompletionsURL is the chat completions endpoint to configure the client with
+func (s *Server) CompletionsURL() string {
+	return s.URL + "/chat/completions"
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Enqueue scripts further responses
+func (s *Server) Enqueue(responses ...Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.script = append(s.script, responses...)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// SetDefault changes the response served once the script has run out
+func (s *Server) SetDefault(response Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.defaultRes = response
+}
+
+// ------------------------------------------------------------------------------------------------------
+// DiscardRequests stops keeping received requests, for long-running load tests
+func (s *Server) DiscardRequests() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.discard = true
+	s.requests = nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Requests returns the requests received so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// SetModelsStatus makes GET /models, the endpoint health probes use, answer with status
+func (s *Server) SetModelsStatus(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.modelsStatus = status
+}
+
+// ------------------------------------------------------------------------------------------------------
+// ModelsRequests returns how many times GET /models was called
+func (s *Server) ModelsRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.modelsServed
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (s *Server) handleModels(w http.ResponseWriter) {
+	s.mu.Lock()
+	s.modelsServed++
+	status := s.modelsStatus
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if status != 0 && status != http.StatusOK {
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"error":{"message":"scripted status %d"}}`, status)
+		return
+	}
+	fmt.Fprint(w, `{"object":"list","data":[{"id":"fake-model","object":"model"}]}`)
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/models") {
+		s.handleModels(w)
+		return
+	}
+
+	var req Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, `{"error":{"message":"invalid JSON body"}}`, http.StatusBadRequest)
+		return
+	}
+	req.Header = r.Header.Clone()
+
+	s.mu.Lock()
+	if !s.discard {
+		s.requests = append(s.requests, req)
+	}
+	response := s.defaultRes
+	if len(s.script) > 0 {
+		response, s.script = s.script[0], s.script[1:]
+	}
+	s.served++
+	id := fmt.Sprintf("chatcmpl-fake-%d", s.served)
+	s.mu.Unlock()
+
+	response.fillDefaults(req)
+
+	if !sleep(r, response.Latency) {
+		return
+	}
+	if response.RequestID != "" {
+		w.Header().Set("X-Request-ID", response.RequestID)
+	}
+
+	switch {
+	case response.Status != http.StatusOK:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(response.Status)
+		fmt.Fprint(w, response.Body)
+	case response.Body != "":
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, response.Body)
+	case req.Stream:
+		s.stream(w, r, id, response)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(completion(id, response, max(req.N, 1)))
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (r *Response) fillDefaults(req Request) {
+	if r.Status == 0 {
+		r.Status = http.StatusOK
+	}
+	if r.Model == "" {
+		r.Model = req.Model
+	}
+	if r.FinishReason == "" {
+		r.FinishReason = "stop"
+		if len(r.ToolCalls) > 0 {
+			r.FinishReason = "tool_calls"
+		}
+	}
+	if r.Chunks == nil && r.Content != "" {
+		r.Chunks = strings.SplitAfter(r.Content, " ")
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// sleep waits for d unless the client goes away first
+func sleep(r *http.Request, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	select {
+	case <-time.After(d):
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (s *Server) stream(w http.ResponseWriter, r *http.Request, id string, response Response) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	flusher, _ := w.(http.Flusher)
+
+	send := func(chunk any) bool {
+		if !sleep(r, response.ChunkDelay) {
+			return false
+		}
+		if response.Malformed {
+			fmt.Fprint(w, "data: {\"choices\": [\n\n")
+		}
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return true
+	}
+
+	if !send(chunk(id, response.Model, delta{Role: "assistant"}, "", nil)) {
+		return
+	}
+	for _, content := range response.Chunks {
+		if !send(chunk(id, response.Model, delta{Content: content}, "", nil)) {
+			return
+		}
+	}
+	if len(response.ToolCalls) > 0 {
+		if !send(chunk(id, response.Model, delta{ToolCalls: toolCallDeltas(response.ToolCalls)}, "", nil)) {
+			return
+		}
+	}
+	if response.Truncate {
+		return
+	}
+	if !send(chunk(id, response.Model, delta{}, response.FinishReason, response.Usage)) {
+		return
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
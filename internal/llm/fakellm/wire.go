@@ -0,0 +1,114 @@
+package fakellm
+
+// Wire format of OpenAI-compatible chat completions, as sent by the fake server
+
+type completionBody struct {
+	ID      string   `json:"id"`
+	Object  string   `json:"object"`
+	Created int64    `json:"created"`
+	Model   string   `json:"model"`
+	Choices []choice `json:"choices"`
+	Usage   *Usage   `json:"usage,omitempty"`
+	XGroq   *xGroq   `json:"x_groq,omitempty"`
+}
+
+type choice struct {
+	Index        int      `json:"index"`
+	Message      *message `json:"message,omitempty"`
+	Delta        *delta   `json:"delta,omitempty"`
+	FinishReason *string  `json:"finish_reason"`
+}
+
+type message struct {
+	Role      string         `json:"role"`
+	Content   string         `json:"content"`
+	ToolCalls []toolCallWire `json:"tool_calls,omitempty"`
+}
+
+type delta struct {
+	Role      string         `json:"role,omitempty"`
+	Content   string         `json:"content,omitempty"`
+	ToolCalls []toolCallWire `json:"tool_calls,omitempty"`
+}
+
+type toolCallWire struct {
+	Index    *int         `json:"index,omitempty"`
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function functionWire `json:"function"`
+}
+
+type functionWire struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// xGroq is where Groq reports usage on the final streamed chunk
+type xGroq struct {
+	ID    string `json:"id"`
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+// ------------------------------------------------------------------------------------------------------
+func completion(id string, response Response, n int) completionBody {
+	body := completionBody{
+		ID:     id,
+		Object: "chat.completion",
+		Model:  response.Model,
+		Usage:  response.Usage,
+	}
+	for i := 0; i < n; i++ {
+		finishReason := response.FinishReason
+		body.Choices = append(body.Choices, choice{
+			Index: i,
+			Message: &message{
+				Role:      "assistant",
+				Content:   response.Content,
+				ToolCalls: toolCallsWire(response.ToolCalls, false),
+			},
+			FinishReason: &finishReason,
+		})
+	}
+	return body
+}
+
+// ------------------------------------------------------------------------------------------------------
+func chunk(id, model string, d delta, finishReason string, usage *Usage) completionBody {
+	c := choice{Delta: &d}
+	if finishReason != "" {
+		c.FinishReason = &finishReason
+	}
+	body := completionBody{
+		ID:      id,
+		Object:  "chat.completion.chunk",
+		Model:   model,
+		Choices: []choice{c},
+	}
+	if usage != nil {
+		body.XGroq = &xGroq{ID: "req_" + id, Usage: usage}
+	}
+	return body
+}
+
+// ------------------------------------------------------------------------------------------------------
+func toolCallDeltas(calls []ToolCall) []toolCallWire {
+	return toolCallsWire(calls, true)
+}
+
+// ------------------------------------------------------------------------------------------------------
+func toolCallsWire(calls []ToolCall, indexed bool) []toolCallWire {
+	var wire []toolCallWire
+	for i, call := range calls {
+		tc := toolCallWire{
+			ID:       call.ID,
+			Type:     "function",
+			Function: functionWire{Name: call.Name, Arguments: call.Arguments},
+		}
+		if indexed {
+			index := i
+			tc.Index = &index
+		}
+		wire = append(wire, tc)
+	}
+	return wire
+}
@@ -2,51 +2,181 @@ package llm
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"sync/atomic"
 	"time"
 
+	"llm-chat-service/internal/contentpart"
 	apperror "llm-chat-service/internal/error"
+	"llm-chat-service/internal/logging"
+	"llm-chat-service/internal/metrics"
+	"llm-chat-service/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 )
 
 // Client interface for LLM operations
 type Client interface {
-	Chat(messages []Message, maxTokens int) (string, error)
-	StreamChat(messages []Message, maxTokens int, onToken func(string) error) (string, error)
+	Chat(ctx context.Context, messages []Message, opts ChatOptions) (*Result, error)
+	StreamChat(ctx context.Context, messages []Message, opts ChatOptions, onToken func(string) error) (*Result, error)
+}
+
+// ModelSwitcher is implemented by clients whose model can be changed while they serve requests
+type ModelSwitcher interface {
+	Model() string
+	SetModel(model string)
 }
 
+// ChatOptions holds per-request generation settings
+type ChatOptions struct {
+	MaxTokens      int
+	Tools          []Tool
+	ToolChoice     any
+	ResponseFormat *ResponseFormat
+	// N requests several completions in one call; only honoured by ChatChoices
+	N int
+}
+
+// Result is the outcome of a completion: either final content, tool calls, or both
+type Result struct {
+	Content   string
+	ToolCalls []ToolCall
+
+	// FinishReason is why generation stopped, e.g. "stop", "length" or "tool_calls"
+	FinishReason string
+	// Model is the model that served the request, as reported by the provider
+	Model string
+	// Usage is nil when the provider did not report token counts
+	Usage *Usage
+	// RequestID identifies the request on the provider side, for support tickets
+	RequestID string
+}
+
+// Finish reasons reported by the provider
+const (
+	FinishReasonStop      = "stop"
+	FinishReasonLength    = "length"
+	FinishReasonToolCalls = "tool_calls"
+)
+
 // GroqClient handles communication with Groq API
 type GroqClient struct {
 	apiKey     string
 	baseURL    string
 	httpClient *http.Client
-	model      string
+	model      atomic.Value // string; see SetModel
+
+	jsonSchemaSupported      bool
+	visionSupported          bool
+	multipleChoicesSupported bool
+}
+
+// ClientOption configures optional GroqClient behaviour
+type ClientOption func(*GroqClient)
+
+// defaultRequestTimeout bounds a whole upstream request, including a streamed body, when not configured
+const defaultRequestTimeout = 60 * time.Second
+
+// ------------------------------------------------------------------------------------------------------
+// WithTimeout bounds each upstream request, including the time spent reading a streamed answer
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *GroqClient) {
+		if timeout > 0 {
+			c.httpClient.Timeout = timeout
+		}
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// WithJSONSchemaSupport declares that the configured model enforces json_schema response formats
+func WithJSONSchemaSupport(supported bool) ClientOption {
+	return func(c *GroqClient) {
+		c.jsonSchemaSupported = supported
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// WithVisionSupport declares that the configured model accepts image content parts
+func WithVisionSupport(supported bool) ClientOption {
+	return func(c *GroqClient) {
+		c.visionSupported = supported
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// WithMultipleChoicesSupport declares that the provider honours n > 1 in a single request
+func WithMultipleChoicesSupport(supported bool) ClientOption {
+	return func(c *GroqClient) {
+		c.multipleChoicesSupported = supported
+	}
 }
 
 // NewGroqClient creates a new Groq client
-func NewGroqClient(apiKey string, baseURL string, model string) *GroqClient {
-	return &GroqClient{
+func NewGroqClient(apiKey string, baseURL string, model string, opts ...ClientOption) *GroqClient {
+	c := &GroqClient{
 		apiKey:  apiKey,
 		baseURL: baseURL,
 		httpClient: &http.Client{
-			Timeout: 60 * time.Second,
+			Timeout: defaultRequestTimeout,
 		},
-		model: model,
 	}
+	c.model.Store(model)
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
-// Message represents a chat message
+// ------------------------------------------------------------------------------------------------------
+// Model returns the model requests are sent to
+func (c *GroqClient) Model() string {
+	return c.model.Load().(string)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// SetModel switches the model for subsequent requests; requests in flight keep the previous one
+func (c *GroqClient) SetModel(model string) {
+	c.model.Store(model)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// SupportsResponseFormat reports whether the provider enforces the given response format natively
+func (c *GroqClient) SupportsResponseFormat(formatType string) bool {
+	switch formatType {
+	case ResponseFormatText, ResponseFormatJSONObject:
+		return true
+	case ResponseFormatJSONSchema:
+		return c.jsonSchemaSupported
+	}
+	return false
+}
+
+// Message represents a chat message. Multimodal messages carry Parts, which are sent as the content array.
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string             `json:"role"`
+	Content    string             `json:"content"`
+	Parts      []contentpart.Part `json:"-"`
+	Name       string             `json:"name,omitempty"`
+	ToolCalls  []ToolCall         `json:"tool_calls,omitempty"`
+	ToolCallID string             `json:"tool_call_id,omitempty"`
 }
 
 // ChatRequest represents the request to Groq API
 type ChatRequest struct {
-	Model     string    `json:"model"`
-	Messages  []Message `json:"messages"`
-	Stream    bool      `json:"stream"`
-	MaxTokens int       `json:"max_tokens,omitempty"`
+	Model      string    `json:"model"`
+	Messages   []Message `json:"messages"`
+	Stream     bool      `json:"stream"`
+	MaxTokens  int       `json:"max_tokens,omitempty"`
+	Tools      []Tool    `json:"tools,omitempty"`
+	ToolChoice any       `json:"tool_choice,omitempty"`
+
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+	N              int             `json:"n,omitempty"`
 }
 
 // ChatResponse represents a streaming response chunk
@@ -56,6 +186,33 @@ type ChatResponse struct {
 	Created int64    `json:"created"`
 	Model   string   `json:"model"`
 	Choices []Choice `json:"choices"`
+	Usage   *Usage   `json:"usage,omitempty"`
+	XGroq   *XGroq   `json:"x_groq,omitempty"`
+}
+
+// Usage represents token accounting reported by the provider
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// XGroq carries Groq-specific fields; streamed usage is reported here on the final chunk
+type XGroq struct {
+	ID    string `json:"id,omitempty"`
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+// ------------------------------------------------------------------------------------------------------
+// usage returns the usage block from either the OpenAI or the Groq location
+func (r *ChatResponse) usage() *Usage {
+	if r.Usage != nil {
+		return r.Usage
+	}
+	if r.XGroq != nil {
+		return r.XGroq.Usage
+	}
+	return nil
 }
 
 // Choice represents a choice in the response
@@ -68,69 +225,203 @@ type Choice struct {
 
 // Delta represents incremental content in streaming
 type Delta struct {
-	Role    string `json:"role,omitempty"`
-	Content string `json:"content,omitempty"`
+	Role      string          `json:"role,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	ToolCalls []ToolCallDelta `json:"tool_calls,omitempty"`
 }
 
 // ------------------------------------------------------------------------------------------------------
-func (c *GroqClient) StreamChat(messages []Message, maxTokens int, onToken func(string) error) (string, error) {
-	reqBody := ChatRequest{
-		Model:     c.model,
-		Messages:  messages,
-		Stream:    true,
-		MaxTokens: maxTokens,
-	}
+// SupportsImages reports whether the configured model accepts image content parts
+func (c *GroqClient) SupportsImages() bool {
+	return c.visionSupported
+}
 
-	resp, err := c.DoRequest(reqBody)
+// ------------------------------------------------------------------------------------------------------
+func (c *GroqClient) StreamChat(ctx context.Context, messages []Message, opts ChatOptions, onToken func(string) error) (_ *Result, err error) {
+	ctx, span := c.startSpan(ctx, "GroqClient.StreamChat", len(messages))
+	logger := c.logger(ctx)
+	defer func() {
+		if err != nil {
+			logger.Warn("LLM stream failed", zap.Error(err))
+		}
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	reqBody := c.newRequest(messages, opts, true)
+
+	start := time.Now()
+	resp, err := c.DoRequest(ctx, reqBody)
 	if err != nil {
-		return "", err 
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	scanner := bufio.NewScanner(resp.Body)
 
-	fullResponse, err := ScanStream(scanner, onToken)
+	var firstToken time.Time
+	result, err := ScanStream(scanner, func(token string) error {
+		if firstToken.IsZero() {
+			firstToken = time.Now()
+			metrics.LLMTimeToFirstToken.WithLabelValues(c.Model()).Observe(firstToken.Sub(start).Seconds())
+		}
+		return onToken(token)
+	})
 	if err != nil {
-		return "", apperror.NewLLMError("failed to process LLM stream", err)
+		// Errors raised by onToken (e.g. output moderation) are already classified
+		var appErr *apperror.AppError
+		if errors.As(err, &appErr) {
+			return nil, err
+		}
+		return nil, apperror.NewLLMError("failed to process LLM stream", err)
 	}
 
-	return fullResponse.String(), nil
+	var generation time.Duration
+	if !firstToken.IsZero() {
+		span.AddEvent("first_token", trace.WithTimestamp(firstToken))
+		generation = time.Since(firstToken)
+	}
+	recordUsage(c.Model(), result.Usage, result.Chunks, generation)
+
+	logger.Info("LLM stream completed",
+		zap.Duration("duration", time.Since(start)),
+		zap.Int("chunks", result.Chunks),
+		zap.Int("tool_calls", len(result.ToolCalls)),
+		zap.String("finish_reason", result.FinishReason),
+	)
+
+	return &Result{
+		Content:      result.Content,
+		ToolCalls:    result.ToolCalls,
+		FinishReason: result.FinishReason,
+		Model:        c.reportedModel(result.Model),
+		Usage:        result.Usage,
+		RequestID:    upstreamRequestID(resp, result.ID),
+	}, nil
 }
 
 // ------------------------------------------------------------------------------------------------------
 // Chat performs a non-streaming chat completion
-func (c *GroqClient) Chat(messages []Message, maxTokens int) (string, error) {
-	reqBody := ChatRequest{
-		Model:     c.model,
-		Messages:  messages,
-		Stream:    false,
-		MaxTokens: maxTokens,
+func (c *GroqClient) Chat(ctx context.Context, messages []Message, opts ChatOptions) (*Result, error) {
+	opts.N = 0
+	results, err := c.complete(ctx, "GroqClient.Chat", messages, opts)
+	if err != nil {
+		return nil, err
 	}
+	return results[0], nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// complete performs a non-streaming request and returns one result per choice. The usage reported for
+// the whole request is attached to the first result.
+func (c *GroqClient) complete(ctx context.Context, spanName string, messages []Message, opts ChatOptions) (_ []*Result, err error) {
+	ctx, span := c.startSpan(ctx, spanName, len(messages))
+	logger := c.logger(ctx)
+	defer func() {
+		if err != nil {
+			logger.Warn("LLM request failed", zap.Error(err))
+		}
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	reqBody := c.newRequest(messages, opts, false)
 
-	resp, err := c.DoRequest(reqBody)
+	start := time.Now()
+	resp, err := c.DoRequest(ctx, reqBody)
 	if err != nil {
-		return "", err // Already wrapped with AppError
+		return nil, err // Already wrapped with AppError
 	}
 	defer resp.Body.Close()
 
 	var chatResp ChatResponse
 	if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
-		return "", apperror.NewLLMError("failed to decode LLM API response", err)
+		return nil, apperror.NewLLMError("failed to decode LLM API response", err)
 	}
 
 	if len(chatResp.Choices) == 0 {
-		return "", apperror.NewLLMError("no choices in LLM response", nil)
+		return nil, apperror.NewLLMError("no choices in LLM response", nil)
 	}
 
-	choice := chatResp.Choices[0]
-	if choice.Message == nil {
-		return "", apperror.NewLLMError("message is nil in LLM response choice", nil)
+	results := make([]*Result, len(chatResp.Choices))
+	toolCalls := 0
+	for i, choice := range chatResp.Choices {
+		if choice.Message == nil {
+			return nil, apperror.NewLLMError("message is nil in LLM response choice", nil)
+		}
+		if choice.Message.Content == "" && len(choice.Message.ToolCalls) == 0 {
+			return nil, apperror.NewLLMError("empty content in LLM response", nil)
+		}
+		results[i] = &Result{
+			Content:      choice.Message.Content,
+			ToolCalls:    choice.Message.ToolCalls,
+			FinishReason: choice.FinishReason,
+			Model:        c.reportedModel(chatResp.Model),
+			RequestID:    upstreamRequestID(resp, chatResp.ID),
+		}
+		toolCalls += len(choice.Message.ToolCalls)
 	}
+	results[0].Usage = chatResp.usage()
 
-	content := choice.Message.Content
-	if content == "" {
-		return "", apperror.NewLLMError("empty content in LLM response", nil)
+	recordUsage(c.Model(), chatResp.usage(), 0, time.Since(start))
+
+	logger.Info("LLM request completed",
+		zap.Duration("duration", time.Since(start)),
+		zap.Int("choices", len(results)),
+		zap.Int("tool_calls", toolCalls),
+		zap.String("finish_reason", results[0].FinishReason),
+	)
+
+	return results, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// reportedModel falls back to the configured model when the provider does not name one
+func (c *GroqClient) reportedModel(model string) string {
+	if model == "" {
+		return c.Model()
+	}
+	return model
+}
+
+// ------------------------------------------------------------------------------------------------------
+// upstreamRequestID prefers the provider's request ID header over the completion ID in the body
+func upstreamRequestID(resp *http.Response, completionID string) string {
+	if requestID := resp.Header.Get("X-Request-ID"); requestID != "" {
+		return requestID
+	}
+	return completionID
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (c *GroqClient) newRequest(messages []Message, opts ChatOptions, stream bool) ChatRequest {
+	req := ChatRequest{
+		Model:          c.Model(),
+		Messages:       messages,
+		Stream:         stream,
+		MaxTokens:      opts.MaxTokens,
+		ResponseFormat: opts.ResponseFormat,
+	}
+	if opts.N > 1 {
+		req.N = opts.N
+	}
+	if len(opts.Tools) > 0 {
+		req.Tools = opts.Tools
+		req.ToolChoice = opts.ToolChoice
 	}
+	return req
+}
 
-	return content, nil
+// ------------------------------------------------------------------------------------------------------
+func (c *GroqClient) startSpan(ctx context.Context, name string, messageCount int) (context.Context, trace.Span) {
+	return tracing.StartSpan(ctx, name, trace.WithAttributes(
+		attribute.String("llm.model", c.Model()),
+		attribute.Int("llm.messages.count", messageCount),
+	))
+}
+
+// ------------------------------------------------------------------------------------------------------
+// logger returns the request-scoped logger annotated with the model name
+func (c *GroqClient) logger(ctx context.Context) *zap.Logger {
+	return logging.FromContext(ctx).With(zap.String(logging.FieldModel, c.Model()))
 }
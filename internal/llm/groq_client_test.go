@@ -0,0 +1,281 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	apperror "llm-chat-service/internal/error"
+	"llm-chat-service/internal/llm/fakellm"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files with the current output")
+
+// clientOutcome is what a golden file records about one client call
+type clientOutcome struct {
+	Tokens []string `json:"tokens,omitempty"`
+	Result *Result  `json:"result,omitempty"`
+	Error  *struct {
+		Type   apperror.ErrorType `json:"type"`
+		Status int                `json:"status"`
+	} `json:"error,omitempty"`
+}
+
+// ------------------------------------------------------------------------------------------------------
+func newOutcome(tokens []string, result *Result, err error) clientOutcome {
+	outcome := clientOutcome{Tokens: tokens, Result: result}
+	if err != nil {
+		var appErr *apperror.AppError
+		if !errors.As(err, &appErr) {
+			appErr = apperror.NewInternalError(err.Error(), nil)
+		}
+		outcome.Error = &struct {
+			Type   apperror.ErrorType `json:"type"`
+			Status int                `json:"status"`
+		}{appErr.Type, appErr.StatusCode}
+	}
+	return outcome
+}
+
+// ------------------------------------------------------------------------------------------------------
+// assertGolden compares got with testdata/<name>.golden, rewriting the file when -update is set
+func assertGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("failed to update golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file (run with -update to create it): %v", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("%s mismatch\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func marshalOutcome(t *testing.T, outcome clientOutcome) []byte {
+	t.Helper()
+	data, err := json.MarshalIndent(outcome, "", "  ")
+	if err != nil {
+		t.Fatalf("failed to marshal outcome: %v", err)
+	}
+	return append(data, '\n')
+}
+
+func TestGroqClient_StreamChat_Golden(t *testing.T) {
+	tests := []struct {
+		name     string
+		response fakellm.Response
+	}{
+		{
+			name: "stream_stop",
+			response: fakellm.Response{
+				Content:   "Paris is the capital of France.",
+				Usage:     &fakellm.Usage{PromptTokens: 12, CompletionTokens: 7, TotalTokens: 19},
+				Model:     "llama-test-served",
+				RequestID: "req_upstream_1",
+			},
+		},
+		{
+			name: "stream_length",
+			response: fakellm.Response{
+				Chunks:       []string{"The answer is", " cut"},
+				FinishReason: FinishReasonLength,
+			},
+		},
+		{
+			name: "stream_tool_calls",
+			response: fakellm.Response{
+				ToolCalls: []fakellm.ToolCall{{ID: "call_1", Name: "get_time", Arguments: `{"zone":"UTC"}`}},
+			},
+		},
+		{
+			name: "stream_malformed_chunks",
+			response: fakellm.Response{
+				Content:   "Garbage lines are skipped.",
+				Malformed: true,
+			},
+		},
+		{
+			name: "stream_truncated",
+			response: fakellm.Response{
+				Content:  "The connection dropped",
+				Truncate: true,
+			},
+		},
+		{name: "status_401", response: fakellm.Response{Status: http.StatusUnauthorized, Body: `{"error":{"message":"Invalid API Key"}}`}},
+		{name: "status_429", response: fakellm.Response{Status: http.StatusTooManyRequests, Body: `{"error":{"message":"Rate limit reached"}}`}},
+		{name: "status_500", response: fakellm.Response{Status: http.StatusInternalServerError, Body: `{"error":{"message":"Internal"}}`}},
+		{name: "status_504", response: fakellm.Response{Status: http.StatusGatewayTimeout}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakellm.NewServer(tt.response)
+			defer server.Close()
+
+			client := NewGroqClient("test-key", server.CompletionsURL(), "llama-test")
+
+			var tokens []string
+			result, err := client.StreamChat(context.Background(), []Message{{Role: "user", Content: "Hi"}}, ChatOptions{MaxTokens: 64},
+				func(token string) error {
+					tokens = append(tokens, token)
+					return nil
+				},
+			)
+
+			assertGolden(t, tt.name, marshalOutcome(t, newOutcome(tokens, result, err)))
+
+			requests := server.Requests()
+			if len(requests) != 1 || !requests[0].Stream || requests[0].Model != "llama-test" || requests[0].MaxTokens != 64 {
+				t.Errorf("Requests = %+v", requests)
+			}
+			if got := requests[0].Header.Get("Authorization"); got != "Bearer test-key" {
+				t.Errorf("Authorization = %q", got)
+			}
+		})
+	}
+}
+
+func TestGroqClient_Chat_Golden(t *testing.T) {
+	server := fakellm.NewServer(fakellm.Response{
+		Content: "Four.",
+		Usage:   &fakellm.Usage{PromptTokens: 9, CompletionTokens: 2, TotalTokens: 11},
+	})
+	defer server.Close()
+
+	client := NewGroqClient("test-key", server.CompletionsURL(), "llama-test")
+	result, err := client.Chat(context.Background(), []Message{{Role: "user", Content: "2+2?"}}, ChatOptions{})
+
+	assertGolden(t, "chat_stop", marshalOutcome(t, newOutcome(nil, result, err)))
+}
+
+func TestGroqClient_DoRequest_Latency(t *testing.T) {
+	server := fakellm.NewServer(fakellm.Response{Latency: time.Second})
+	defer server.Close()
+
+	client := NewGroqClient("test-key", server.CompletionsURL(), "llama-test")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := client.DoRequest(ctx, client.newRequest([]Message{{Role: "user", Content: "Hi"}}, ChatOptions{}, false))
+
+	var appErr *apperror.AppError
+	if !errors.As(err, &appErr) || appErr.Type != apperror.ErrorTypeTimeout {
+		t.Errorf("DoRequest() error = %v, want a timeout error", err)
+	}
+}
+
+func TestGroqClient_Ping(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr string
+	}{
+		{name: "ok", status: http.StatusOK},
+		{name: "bad key", status: http.StatusUnauthorized, wantErr: "LLM API rejected the credentials"},
+		{name: "outage", status: http.StatusServiceUnavailable, wantErr: "LLM API returned status 503"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakellm.NewServer()
+			defer server.Close()
+			server.SetModelsStatus(tt.status)
+
+			err := NewGroqClient("test-key", server.CompletionsURL(), "llama-test").Ping(context.Background())
+			if (err == nil) != (tt.wantErr == "") || (err != nil && err.Error() != tt.wantErr) {
+				t.Errorf("Ping() error = %v, want %q", err, tt.wantErr)
+			}
+			if server.ModelsRequests() != 1 || len(server.Requests()) != 0 {
+				t.Errorf("Ping() made %d model and %d completion requests, want 1 and 0", server.ModelsRequests(), len(server.Requests()))
+			}
+		})
+	}
+}
+
+func TestGroqClient_PropagatesTraceContext(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	}()
+
+	tests := []struct {
+		name string
+		call func(ctx context.Context, client *GroqClient) error
+	}{
+		{
+			name: "chat",
+			call: func(ctx context.Context, client *GroqClient) error {
+				_, err := client.Chat(ctx, []Message{{Role: "user", Content: "Hi"}}, ChatOptions{})
+				return err
+			},
+		},
+		{
+			name: "stream",
+			call: func(ctx context.Context, client *GroqClient) error {
+				_, err := client.StreamChat(ctx, []Message{{Role: "user", Content: "Hi"}}, ChatOptions{}, func(string) error { return nil })
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := fakellm.NewServer(fakellm.Response{Content: "Hello"})
+			defer server.Close()
+
+			ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+			err := tt.call(ctx, NewGroqClient("test-key", server.CompletionsURL(), "llama-test"))
+			parent.End()
+			if err != nil {
+				t.Fatalf("call error = %v", err)
+			}
+
+			var requestSpan sdktrace.ReadOnlySpan
+			for _, span := range recorder.Ended() {
+				if span.Name() == "GroqClient.DoRequest" && span.SpanContext().TraceID() == parent.SpanContext().TraceID() {
+					requestSpan = span
+				}
+			}
+			if requestSpan == nil {
+				t.Fatal("no GroqClient.DoRequest span recorded")
+			}
+
+			// The upstream sees the request span as its parent, within the caller's trace
+			want := fmt.Sprintf("00-%s-%s-01", parent.SpanContext().TraceID(), requestSpan.SpanContext().SpanID())
+			requests := server.Requests()
+			if len(requests) != 1 {
+				t.Fatalf("upstream requests = %d, want 1", len(requests))
+			}
+			if got := requests[0].Header.Get("traceparent"); got != want {
+				t.Errorf("traceparent = %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -3,79 +3,169 @@ package llm
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	apperror "llm-chat-service/internal/error"
+	"llm-chat-service/internal/logging"
+	"llm-chat-service/internal/metrics"
+	"llm-chat-service/internal/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// StreamResult holds everything accumulated while scanning an SSE stream
+type StreamResult struct {
+	Content      string
+	ToolCalls    []ToolCall
+	Usage        *Usage
+	Chunks       int
+	FinishReason string
+	Model        string
+	ID           string
+}
+
 // ------------------------------------------------------------------------------------------------------
-func (c *GroqClient) DoRequest(reqBody any) (*http.Response, error) {
+// DoRequest sends reqBody to the provider and returns the response once a 200 status has been received.
+// The caller's trace context is propagated upstream via the W3C traceparent header.
+func (c *GroqClient) DoRequest(ctx context.Context, reqBody any) (resp *http.Response, err error) {
+	ctx, span := tracing.StartSpan(ctx, "GroqClient.DoRequest",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(http.MethodPost),
+			semconv.URLFull(c.baseURL),
+			attribute.String("llm.model", c.Model()),
+		),
+	)
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, apperror.NewInternalError("failed to marshal LLM request", err)
 	}
 
-	req, err := http.NewRequest("POST", c.baseURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, apperror.NewInternalError("failed to create HTTP request", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.apiKey))
+	if requestID := logging.RequestIDFromContext(ctx); requestID != "" {
+		req.Header.Set("X-Request-ID", requestID)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
 	start := time.Now()
-	resp, err := c.httpClient.Do(req)
+	resp, err = c.httpClient.Do(req)
 	if err != nil {
-		if err.Error() == "context deadline exceeded" ||
+		var appErr *apperror.AppError
+		if errors.Is(err, context.DeadlineExceeded) ||
+			err.Error() == "context deadline exceeded" ||
 			strings.Contains(err.Error(), "timeout") ||
 			strings.Contains(err.Error(), "Client.Timeout exceeded") {
-			return nil, apperror.NewTimeoutError("LLM API request timed out", err)
+			appErr = apperror.NewTimeoutError("LLM API request timed out", err)
+		} else {
+			appErr = apperror.NewLLMError("failed to send request to LLM API", err)
 		}
-		return nil, apperror.NewLLMError("failed to send request to LLM API", err)
+		recordUpstreamResponse(0, appErr)
+		return nil, appErr
 	}
 
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
 		resp.Body.Close()
 
+		var appErr *apperror.AppError
 		switch resp.StatusCode {
 		case http.StatusUnauthorized, http.StatusForbidden:
-			return nil, apperror.NewUnauthorizedError(
-				apperror.ErrUnauthorized.Error(),
+			appErr = apperror.NewUnauthorizedError(
+				"LLM API rejected the credentials",
 				fmt.Errorf("response: %s", string(bodyBytes)),
 			)
 
 		case http.StatusTooManyRequests:
-			return nil, apperror.NewRateLimitError(
+			appErr = apperror.NewRateLimitError(
 				apperror.ErrRateLimit.Error(),
 				fmt.Errorf("status %d, response: %s", resp.StatusCode, string(bodyBytes)),
 			)
 
 		case http.StatusGatewayTimeout, http.StatusRequestTimeout:
 			duration := time.Since(start)
-			return nil, apperror.NewTimeoutError(
+			appErr = apperror.NewTimeoutError(
 				fmt.Sprintf("LLM API timed out after %v", duration),
 				fmt.Errorf("status %d", resp.StatusCode),
 			)
 
 		default:
-			return nil, apperror.NewLLMError(
-				apperror.ErrInternal.Error(),
+			appErr = apperror.NewLLMError(
+				fmt.Sprintf("LLM API returned status %d", resp.StatusCode),
 				fmt.Errorf("response: %s", string(bodyBytes)),
 			)
 		}
+		recordUpstreamResponse(resp.StatusCode, appErr)
+		return nil, appErr
 	}
 
+	recordUpstreamResponse(resp.StatusCode, nil)
 	return resp, nil
 }
 
-func ScanStream(scanner *bufio.Scanner, onToken func(string) error) (strings.Builder, error) {
+// ------------------------------------------------------------------------------------------------------
+// recordUpstreamResponse counts upstream responses by status code and AppError type
+func recordUpstreamResponse(statusCode int, err error) {
+	status := "none"
+	if statusCode > 0 {
+		status = strconv.Itoa(statusCode)
+	}
+
+	errorType := "none"
+	var appErr *apperror.AppError
+	if errors.As(err, &appErr) {
+		errorType = string(appErr.Type)
+	}
+
+	metrics.LLMUpstreamResponsesTotal.WithLabelValues(status, errorType).Inc()
+}
+
+// ------------------------------------------------------------------------------------------------------
+// recordUsage updates token counters and throughput. When the provider does not report usage,
+// the number of streamed chunks is used as an approximation of completion tokens.
+func recordUsage(model string, usage *Usage, chunks int, generation time.Duration) {
+	completionTokens := chunks
+	if usage != nil {
+		metrics.LLMPromptTokensTotal.WithLabelValues(model).Add(float64(usage.PromptTokens))
+		completionTokens = usage.CompletionTokens
+	}
+	metrics.LLMCompletionTokensTotal.WithLabelValues(model).Add(float64(completionTokens))
+
+	if completionTokens > 0 && generation > 0 {
+		metrics.LLMTokensPerSecond.WithLabelValues(model).Observe(float64(completionTokens) / generation.Seconds())
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// ScanStream reads an OpenAI-compatible SSE stream, forwarding content deltas to onToken
+func ScanStream(scanner *bufio.Scanner, onToken func(string) error) (*StreamResult, error) {
 	var fullResponse strings.Builder
+	var toolCalls toolCallAccumulator
+	result := &StreamResult{}
 	for scanner.Scan() {
 		line := scanner.Bytes()
 		if len(line) == 0 {
@@ -95,23 +185,45 @@ func ScanStream(scanner *bufio.Scanner, onToken func(string) error) (strings.Bui
 			continue
 		}
 
+		if usage := chatResp.usage(); usage != nil {
+			result.Usage = usage
+		}
+		if chatResp.Model != "" {
+			result.Model = chatResp.Model
+		}
+		if chatResp.ID != "" {
+			result.ID = chatResp.ID
+		}
+
 		if len(chatResp.Choices) > 0 {
 			choice := chatResp.Choices[0]
+			if choice.FinishReason != "" {
+				result.FinishReason = choice.FinishReason
+			}
 			var content string
 			if choice.Delta != nil {
 				content = choice.Delta.Content
+				toolCalls.add(choice.Delta.ToolCalls)
 			} else if choice.Message != nil {
 				content = choice.Message.Content
 			}
 
 			if content != "" {
 				fullResponse.WriteString(content)
+				result.Chunks++
 				if err := onToken(content); err != nil {
-					return strings.Builder{}, err
+					return nil, err
 				}
 			}
 		}
 	}
 
-	return fullResponse, nil
+	// A cancelled or broken stream must not pass for a complete answer
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	result.Content = fullResponse.String()
+	result.ToolCalls = toolCalls.result()
+	return result, nil
 }
@@ -0,0 +1,51 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Pinger is implemented by clients that can check the provider without generating tokens
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Ping lists the provider's models, which checks reachability and credentials without using tokens
+func (c *GroqClient) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.modelsURL(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("LLM API unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("LLM API rejected the credentials")
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("LLM API returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// modelsURL derives the OpenAI-compatible models endpoint from the chat completions URL
+func (c *GroqClient) modelsURL() string {
+	return strings.TrimSuffix(c.baseURL, "/chat/completions") + "/models"
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Ping always succeeds: replayed traffic has no provider to check
+func (c *ReplayClient) Ping(ctx context.Context) error {
+	return nil
+}
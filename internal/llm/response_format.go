@@ -0,0 +1,30 @@
+package llm
+
+import "encoding/json"
+
+// Response format types accepted by OpenAI-compatible providers
+const (
+	ResponseFormatText       = "text"
+	ResponseFormatJSONObject = "json_object"
+	ResponseFormatJSONSchema = "json_schema"
+)
+
+// ResponseFormat constrains the shape of the model's final answer
+type ResponseFormat struct {
+	Type       string      `json:"type"`
+	JSONSchema *JSONSchema `json:"json_schema,omitempty"`
+}
+
+// JSONSchema is a named JSON Schema the answer must conform to
+type JSONSchema struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Schema      json.RawMessage `json:"schema"`
+	Strict      *bool           `json:"strict,omitempty"`
+}
+
+// ResponseFormatSupporter is implemented by clients that can report which response formats
+// the upstream provider enforces natively
+type ResponseFormatSupporter interface {
+	SupportsResponseFormat(formatType string) bool
+}
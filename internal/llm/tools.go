@@ -0,0 +1,89 @@
+package llm
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// Tool describes a function the model may call
+type Tool struct {
+	Type     string             `json:"type"`
+	Function FunctionDefinition `json:"function"`
+}
+
+// FunctionDefinition is the JSON Schema description of a callable function
+type FunctionDefinition struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// ToolCall is a function invocation requested by the model
+type ToolCall struct {
+	ID       string       `json:"id"`
+	Type     string       `json:"type"`
+	Function FunctionCall `json:"function"`
+}
+
+// FunctionCall holds the function name and its JSON-encoded arguments
+type FunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ToolCallDelta is a streamed fragment of a tool call; fragments sharing an Index belong together
+type ToolCallDelta struct {
+	Index    int          `json:"index"`
+	ID       string       `json:"id,omitempty"`
+	Type     string       `json:"type,omitempty"`
+	Function FunctionCall `json:"function"`
+}
+
+// ToolTypeFunction is the only tool type supported by OpenAI-compatible providers
+const ToolTypeFunction = "function"
+
+// toolCallAccumulator merges streamed tool call fragments by index
+type toolCallAccumulator struct {
+	calls map[int]*ToolCall
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (a *toolCallAccumulator) add(deltas []ToolCallDelta) {
+	if a.calls == nil {
+		a.calls = make(map[int]*ToolCall)
+	}
+	for _, delta := range deltas {
+		call, ok := a.calls[delta.Index]
+		if !ok {
+			call = &ToolCall{Type: ToolTypeFunction}
+			a.calls[delta.Index] = call
+		}
+		if delta.ID != "" {
+			call.ID = delta.ID
+		}
+		if delta.Type != "" {
+			call.Type = delta.Type
+		}
+		call.Function.Name += delta.Function.Name
+		call.Function.Arguments += delta.Function.Arguments
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// result returns the accumulated calls ordered by index
+func (a *toolCallAccumulator) result() []ToolCall {
+	if len(a.calls) == 0 {
+		return nil
+	}
+	indexes := make([]int, 0, len(a.calls))
+	for index := range a.calls {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	calls := make([]ToolCall, 0, len(indexes))
+	for _, index := range indexes {
+		calls = append(calls, *a.calls[index])
+	}
+	return calls
+}
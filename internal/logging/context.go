@@ -0,0 +1,92 @@
+package logging
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+type contextKey int
+
+const (
+	loggerKey contextKey = iota
+	requestIDKey
+	conversationIDKey
+	tenantKey
+)
+
+// Field names shared by every request-scoped log line
+const (
+	FieldRequestID      = "request_id"
+	FieldConversationID = "conversation_id"
+	FieldTenant         = "tenant"
+	FieldModel          = "model"
+)
+
+// ------------------------------------------------------------------------------------------------------
+// WithLogger returns a copy of ctx carrying logger
+func WithLogger(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// FromContext returns the request-scoped logger, falling back to the global logger
+func FromContext(ctx context.Context) *zap.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(loggerKey).(*zap.Logger); ok && logger != nil {
+			return logger
+		}
+	}
+	if Logger != nil {
+		return Logger
+	}
+	return zap.NewNop()
+}
+
+// ------------------------------------------------------------------------------------------------------
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// RequestIDFromContext returns the request ID stored in ctx, or an empty string
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// ------------------------------------------------------------------------------------------------------
+// WithConversationID returns a copy of ctx carrying the conversation ID
+func WithConversationID(ctx context.Context, conversationID string) context.Context {
+	return context.WithValue(ctx, conversationIDKey, conversationID)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// ConversationIDFromContext returns the conversation ID stored in ctx, or an empty string
+func ConversationIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	conversationID, _ := ctx.Value(conversationIDKey).(string)
+	return conversationID
+}
+
+// ------------------------------------------------------------------------------------------------------
+// WithTenant returns a copy of ctx carrying the tenant
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey, tenant)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// TenantFromContext returns the tenant stored in ctx, or an empty string
+func TenantFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	tenant, _ := ctx.Value(tenantKey).(string)
+	return tenant
+}
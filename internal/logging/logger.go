@@ -1,22 +1,64 @@
 package logging
 
 import (
+	"fmt"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
+// Supported log encodings
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
 // Logger is the global logger instance
 var Logger *zap.Logger
 
+// Level controls the global logger's minimum level and can be changed at runtime
+var Level = zap.NewAtomicLevel()
+
+// Options configures the global logger
+type Options struct {
+	Level  string
+	Format string
+	// Sampling keeps the first SamplingInitial entries with the same level and message
+	// each second, then every SamplingThereafter-th. A zero SamplingInitial disables sampling.
+	SamplingInitial    int
+	SamplingThereafter int
+}
+
 // ------------------------------------------------------------------------------------------------------
-func Init() error {
+func Init(opts Options) error {
+	if err := ValidateOptions(opts); err != nil {
+		return err
+	}
+
 	config := zap.NewProductionConfig()
+	if opts.Format == FormatConsole {
+		config = zap.NewDevelopmentConfig()
+		config.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	}
 	config.EncoderConfig.TimeKey = "timestamp"
 	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
 	config.EncoderConfig.MessageKey = "message"
 	config.EncoderConfig.LevelKey = "level"
 	config.EncoderConfig.CallerKey = "caller"
 
+	config.Sampling = nil
+	if opts.SamplingInitial > 0 {
+		config.Sampling = &zap.SamplingConfig{
+			Initial:    opts.SamplingInitial,
+			Thereafter: opts.SamplingThereafter,
+		}
+	}
+
+	if opts.Level != "" {
+		_ = Level.UnmarshalText([]byte(opts.Level))
+	}
+	config.Level = Level
+
 	var err error
 	Logger, err = config.Build()
 	if err != nil {
@@ -26,6 +68,28 @@ func Init() error {
 	return nil
 }
 
+// ------------------------------------------------------------------------------------------------------
+// ValidateOptions checks level and format without building a logger
+func ValidateOptions(opts Options) error {
+	if opts.Level != "" {
+		if _, err := zapcore.ParseLevel(opts.Level); err != nil {
+			return fmt.Errorf("invalid log level %q: %w", opts.Level, err)
+		}
+	}
+
+	switch opts.Format {
+	case "", FormatJSON, FormatConsole:
+	default:
+		return fmt.Errorf("invalid log format %q: must be %q or %q", opts.Format, FormatJSON, FormatConsole)
+	}
+
+	if opts.SamplingInitial < 0 || opts.SamplingThereafter < 0 {
+		return fmt.Errorf("log sampling values must not be negative")
+	}
+
+	return nil
+}
+
 // ------------------------------------------------------------------------------------------------------
 func Sync() {
 	if Logger != nil {
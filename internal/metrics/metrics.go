@@ -0,0 +1,203 @@
+package metrics
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	HTTPRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Total number of HTTP requests",
+		},
+		[]string{"method", "endpoint", "status"},
+	)
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request duration in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"method", "endpoint"},
+	)
+
+	ChatRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chat_requests_total",
+			Help: "Total number of chat requests",
+		},
+		[]string{"stream_type"},
+	)
+
+	ActiveStreams = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "chat_active_streams",
+			Help: "Number of chat streams currently being served",
+		},
+		[]string{"stream_type"},
+	)
+
+	LLMTimeToFirstToken = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "llm_time_to_first_token_seconds",
+			Help:    "Time from sending the upstream request to receiving the first streamed token",
+			Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 30},
+		},
+		[]string{"model"},
+	)
+
+	LLMTokensPerSecond = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "llm_tokens_per_second",
+			Help:    "Completion tokens generated per second",
+			Buckets: []float64{10, 25, 50, 100, 200, 400, 800, 1600},
+		},
+		[]string{"model"},
+	)
+
+	LLMPromptTokensTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "llm_prompt_tokens_total",
+			Help: "Total number of prompt tokens sent to the LLM provider",
+		},
+		[]string{"model"},
+	)
+
+	LLMCompletionTokensTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "llm_completion_tokens_total",
+			Help: "Total number of completion tokens received from the LLM provider",
+		},
+		[]string{"model"},
+	)
+
+	LLMUpstreamResponsesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "llm_upstream_responses_total",
+			Help: "Upstream LLM responses by HTTP status code and error type",
+		},
+		[]string{"status_code", "error_type"},
+	)
+
+	ToolCallsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chat_tool_calls_total",
+			Help: "Tool calls executed on behalf of the model by tool and outcome",
+		},
+		[]string{"tool", "outcome"},
+	)
+
+	StructuredOutputTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chat_structured_output_total",
+			Help: "Structured output turns by outcome (valid, repaired, failed)",
+		},
+		[]string{"outcome"},
+	)
+
+	ChatContinuationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "chat_continuations_total",
+			Help: "Answers auto-continued after hitting max_tokens, by outcome (completed, capped)",
+		},
+		[]string{"outcome"},
+	)
+
+	RAGDocumentsIngestedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rag_documents_ingested_total",
+			Help: "Documents ingested into knowledge bases by format",
+		},
+		[]string{"format"},
+	)
+
+	ModerationResultsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "moderation_results_total",
+			Help: "Moderation results by stage (input, output) and outcome (pass, block, redact, flag, error)",
+		},
+		[]string{"stage", "outcome"},
+	)
+
+	PromptInjectionDetectionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "prompt_injection_detections_total",
+			Help: "Suspected prompt injections by source (user, retrieved) and action taken (log, warn, reject)",
+		},
+		[]string{"source", "action"},
+	)
+
+	AuditRecordsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "audit_records_total",
+			Help: "Audit records by outcome (written, dropped, failed)",
+		},
+		[]string{"outcome"},
+	)
+
+	HealthComponentUp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "health_component_up",
+			Help: "Whether a dependency passed its last readiness check (1) or not (0), by component",
+		},
+		[]string{"component"},
+	)
+
+	ConfigReloadsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "config_reloads_total",
+			Help: "Configuration reloads by outcome (applied, rejected)",
+		},
+		[]string{"outcome"},
+	)
+
+	RedisUp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "redis_up",
+			Help: "Whether a Redis client is connected (1) or failing fast while it reconnects (0), by client",
+		},
+		[]string{"client"},
+	)
+
+	RedisConnectionLostTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "redis_connection_lost_total",
+			Help: "Times a Redis client lost its connection and started reconnecting, by client",
+		},
+		[]string{"client"},
+	)
+)
+
+var registerOnce sync.Once
+
+// ------------------------------------------------------------------------------------------------------
+// Register registers all collectors with the default Prometheus registry. It is safe to call more than once.
+func Register() {
+	registerOnce.Do(func() {
+		prometheus.MustRegister(
+			HTTPRequestsTotal,
+			HTTPRequestDuration,
+			ChatRequestsTotal,
+			ActiveStreams,
+			LLMTimeToFirstToken,
+			LLMTokensPerSecond,
+			LLMPromptTokensTotal,
+			LLMCompletionTokensTotal,
+			LLMUpstreamResponsesTotal,
+			ToolCallsTotal,
+			StructuredOutputTotal,
+			ChatContinuationsTotal,
+			RAGDocumentsIngestedTotal,
+			ModerationResultsTotal,
+			PromptInjectionDetectionsTotal,
+			AuditRecordsTotal,
+			HealthComponentUp,
+			ConfigReloadsTotal,
+			RedisUp,
+			RedisConnectionLostTotal,
+		)
+	})
+}
@@ -0,0 +1,74 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+)
+
+// Verdict is a classifier's judgement of a piece of text
+type Verdict struct {
+	Flagged    bool
+	Categories []string
+	// Spans locate the offending text when the classifier can tell; redaction falls back to the
+	// whole text when a flagged verdict has none
+	Spans []Span
+}
+
+// Span is a byte range [Start, End) of the classified text
+type Span struct {
+	Start int
+	End   int
+}
+
+// Classifier decides whether text violates the content policy
+type Classifier interface {
+	Classify(ctx context.Context, text string) (Verdict, error)
+}
+
+// Action is what happens to flagged content
+type Action string
+
+const (
+	ActionBlock  Action = "block"  // Reject the request or stop the response
+	ActionRedact Action = "redact" // Replace the offending text and continue
+	ActionFlag   Action = "flag"   // Log and count, but pass the content through
+)
+
+// ------------------------------------------------------------------------------------------------------
+// ParseAction validates an action name
+func ParseAction(name string) (Action, error) {
+	switch action := Action(name); action {
+	case ActionBlock, ActionRedact, ActionFlag:
+		return action, nil
+	default:
+		return "", fmt.Errorf("unknown moderation action %q: must be block, redact or flag", name)
+	}
+}
+
+// MultiClassifier flags text when any of its classifiers does, merging their categories and spans
+type MultiClassifier []Classifier
+
+// ------------------------------------------------------------------------------------------------------
+func (m MultiClassifier) Classify(ctx context.Context, text string) (Verdict, error) {
+	var merged Verdict
+	wholeText := false
+	for _, classifier := range m {
+		verdict, err := classifier.Classify(ctx, text)
+		if err != nil {
+			return Verdict{}, err
+		}
+		if !verdict.Flagged {
+			continue
+		}
+		merged.Flagged = true
+		merged.Categories = append(merged.Categories, verdict.Categories...)
+		merged.Spans = append(merged.Spans, verdict.Spans...)
+		wholeText = wholeText || len(verdict.Spans) == 0
+	}
+
+	// One classifier without spans means the whole text is suspect
+	if wholeText {
+		merged.Spans = nil
+	}
+	return merged, nil
+}
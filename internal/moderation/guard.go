@@ -0,0 +1,52 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"llm-chat-service/internal/llm"
+)
+
+// GuardClassifier asks a guard model (Llama Guard style) to judge text. The model answers "safe",
+// or "unsafe" followed by a line of comma-separated category codes such as "S1,S10".
+type GuardClassifier struct {
+	client llm.Client
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewGuardClassifier creates a classifier backed by a client configured with a guard model
+func NewGuardClassifier(client llm.Client) *GuardClassifier {
+	return &GuardClassifier{client: client}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (g *GuardClassifier) Classify(ctx context.Context, text string) (Verdict, error) {
+	result, err := g.client.Chat(ctx, []llm.Message{{Role: "user", Content: text}}, llm.ChatOptions{MaxTokens: 32})
+	if err != nil {
+		return Verdict{}, err
+	}
+	return parseGuardResponse(result.Content)
+}
+
+// ------------------------------------------------------------------------------------------------------
+func parseGuardResponse(content string) (Verdict, error) {
+	lines := strings.Split(strings.TrimSpace(content), "\n")
+
+	switch strings.ToLower(strings.TrimSpace(lines[0])) {
+	case "safe":
+		return Verdict{}, nil
+	case "unsafe":
+		verdict := Verdict{Flagged: true}
+		if len(lines) > 1 {
+			for _, category := range strings.Split(lines[1], ",") {
+				if category = strings.TrimSpace(category); category != "" {
+					verdict.Categories = append(verdict.Categories, category)
+				}
+			}
+		}
+		return verdict, nil
+	default:
+		return Verdict{}, fmt.Errorf("unexpected guard model response %q", content)
+	}
+}
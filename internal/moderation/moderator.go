@@ -0,0 +1,254 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	apperror "llm-chat-service/internal/error"
+	"llm-chat-service/internal/logging"
+	"llm-chat-service/internal/metrics"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// Stage is where in the chat turn content is moderated
+type Stage string
+
+const (
+	StageInput  Stage = "input"
+	StageOutput Stage = "output"
+)
+
+// RedactedText replaces redacted content
+const RedactedText = "[redacted]"
+
+// defaultOutputWindow is how many characters of streamed output are moderated at once
+const defaultOutputWindow = 200
+
+// Moderator applies a classifier to chat inputs and outputs and enforces the configured actions.
+// Classifier failures are logged and let the content through, so a guard model outage does not
+// take the chat down with it.
+type Moderator struct {
+	classifier   Classifier
+	inputAction  Action
+	outputAction Action
+	window       int
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewModerator creates a moderator releasing streamed output in windows of window characters
+func NewModerator(classifier Classifier, inputAction, outputAction Action, window int) *Moderator {
+	if window <= 0 {
+		window = defaultOutputWindow
+	}
+	return &Moderator{
+		classifier:   classifier,
+		inputAction:  inputAction,
+		outputAction: outputAction,
+		window:       window,
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Check moderates text and returns the text to continue with, which is redacted when the action is
+// redact. Blocked content yields a moderation AppError.
+func (m *Moderator) Check(ctx context.Context, stage Stage, text string) (string, error) {
+	text, _, err := m.check(ctx, stage, "", text)
+	return text, err
+}
+
+// ------------------------------------------------------------------------------------------------------
+// check moderates text preceded by released, text that already passed moderation and is classified
+// again only so that content spanning both is caught. Spans found entirely within released were dealt
+// with when it was checked and do not flag text. It also returns the verdict when text was flagged.
+func (m *Moderator) check(ctx context.Context, stage Stage, released, text string) (string, Verdict, error) {
+	if strings.TrimSpace(text) == "" {
+		return text, Verdict{}, nil
+	}
+
+	logger := logging.FromContext(ctx).With(zap.String("moderation_stage", string(stage)))
+
+	verdict, err := m.classifier.Classify(ctx, released+text)
+	if err != nil {
+		logger.Warn("Moderation classifier failed, letting content through", zap.Error(err))
+		metrics.ModerationResultsTotal.WithLabelValues(string(stage), "error").Inc()
+		return text, Verdict{}, nil
+	}
+	spans := shiftSpans(verdict.Spans, len(released))
+	if !verdict.Flagged || len(verdict.Spans) > 0 && len(spans) == 0 {
+		metrics.ModerationResultsTotal.WithLabelValues(string(stage), "pass").Inc()
+		return text, Verdict{}, nil
+	}
+
+	action := m.inputAction
+	if stage == StageOutput {
+		action = m.outputAction
+	}
+
+	metrics.ModerationResultsTotal.WithLabelValues(string(stage), string(action)).Inc()
+	trace.SpanFromContext(ctx).AddEvent("moderation", trace.WithAttributes(
+		attribute.String("moderation.stage", string(stage)),
+		attribute.String("moderation.action", string(action)),
+		attribute.StringSlice("moderation.categories", verdict.Categories),
+	))
+	logger.Warn("Content flagged by moderation",
+		zap.String("action", string(action)),
+		zap.Strings("categories", verdict.Categories),
+	)
+
+	switch action {
+	case ActionRedact:
+		return redact(text, spans), verdict, nil
+	case ActionFlag:
+		return text, verdict, nil
+	default:
+		subject := "message"
+		if stage == StageOutput {
+			subject = "response"
+		}
+		return "", verdict, apperror.NewModerationError(
+			fmt.Sprintf("%s was blocked by content moderation (%s)", subject, strings.Join(verdict.Categories, ", ")),
+			nil,
+		)
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// shiftSpans moves spans found in a text back by offset bytes, dropping those that end before it and
+// clipping those that start before it
+func shiftSpans(spans []Span, offset int) []Span {
+	var shifted []Span
+	for _, span := range spans {
+		if span.End <= offset {
+			continue
+		}
+		shifted = append(shifted, Span{Start: max(span.Start-offset, 0), End: span.End - offset})
+	}
+	return shifted
+}
+
+// ------------------------------------------------------------------------------------------------------
+// redact replaces spans of text, or all of it when no spans are known
+func redact(text string, spans []Span) string {
+	if len(spans) == 0 {
+		return RedactedText
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start < spans[j].Start })
+
+	var b strings.Builder
+	last := 0
+	for _, span := range spans {
+		if span.Start < last {
+			// Overlaps the previous span, whose marker is already written
+			last = max(last, span.End)
+			continue
+		}
+		b.WriteString(text[last:span.Start])
+		b.WriteString(RedactedText)
+		last = span.End
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// Stream holds back streamed output and releases it to onToken one moderated window at a time, so
+// blocked or redacted text never reaches the client. Each window is classified together with the
+// end of the released text, up to a window long, so content spanning two windows is still caught.
+type Stream struct {
+	ctx          context.Context
+	moderator    *Moderator
+	onToken      func(string) error
+	pending      strings.Builder
+	released     strings.Builder
+	messageStart int // Offset in released of the current message
+	flaggedEnd   int // Offset in released up to which text was flagged without spans and released as is
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewStream wraps onToken with output moderation
+func (m *Moderator) NewStream(ctx context.Context, onToken func(string) error) *Stream {
+	return &Stream{ctx: ctx, moderator: m, onToken: onToken}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Write buffers token and releases a window once enough text is pending. Windows end at whitespace
+// so a word is never split between two classifier calls.
+func (s *Stream) Write(token string) error {
+	s.pending.WriteString(token)
+	if s.pending.Len() < s.moderator.window {
+		return nil
+	}
+
+	text := s.pending.String()
+	cut := strings.LastIndexAny(text, " \t\n") + 1
+	if cut == 0 {
+		cut = len(text)
+	}
+	return s.release(text[:cut], text[cut:])
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Flush releases whatever is still pending; call it once the upstream response is complete
+func (s *Stream) Flush() error {
+	return s.release(s.pending.String(), "")
+}
+
+// ------------------------------------------------------------------------------------------------------
+// EndMessage releases the pending text of a message that is complete although the response is not,
+// e.g. one that ended in tool calls. Text then only returns what is released afterwards.
+func (s *Stream) EndMessage() error {
+	if err := s.Flush(); err != nil {
+		return err
+	}
+	s.messageStart = s.released.Len()
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Text returns the current message as released so far, after moderation
+func (s *Stream) Text() string {
+	return s.released.String()[s.messageStart:]
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (s *Stream) release(window, rest string) error {
+	moderated, verdict, err := s.moderator.check(s.ctx, StageOutput, s.tail(), window)
+	if err != nil {
+		return err
+	}
+
+	s.pending.Reset()
+	s.pending.WriteString(rest)
+
+	if moderated == "" {
+		return nil
+	}
+	s.released.WriteString(moderated)
+	if verdict.Flagged && len(verdict.Spans) == 0 && moderated == window {
+		s.flaggedEnd = s.released.Len()
+	}
+	return s.onToken(moderated)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// tail returns the end of the released text that is classified again with the next window. It is at
+// most a window long and starts at a word, so a cut word cannot match a keyword on its own. Text
+// flagged without spans and released as is stays out of it, or the classifier would flag it again
+// with the following windows since nothing tells where in it the flagged content is.
+func (s *Stream) tail() string {
+	text := s.released.String()[s.flaggedEnd:]
+	if len(text) <= s.moderator.window {
+		return text
+	}
+	text = text[len(text)-s.moderator.window:]
+	cut := strings.IndexAny(text, " \t\n")
+	if cut < 0 {
+		return ""
+	}
+	return text[cut+1:]
+}
@@ -0,0 +1,318 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	apperror "llm-chat-service/internal/error"
+	"llm-chat-service/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// failingClassifier simulates a guard model outage
+type failingClassifier struct{}
+
+func (failingClassifier) Classify(ctx context.Context, text string) (Verdict, error) {
+	return Verdict{}, errors.New("guard unavailable")
+}
+
+// wordClassifier flags any text containing word without locating it, like the guard classifier
+type wordClassifier struct{ word string }
+
+func (c wordClassifier) Classify(ctx context.Context, text string) (Verdict, error) {
+	return Verdict{Flagged: strings.Contains(text, c.word), Categories: []string{"test"}}, nil
+}
+
+func TestPatternClassifier_Classify(t *testing.T) {
+	classifier, err := NewPatternClassifier([]string{"secret", "top-secret"}, []string{`\d{4}-\d{4}`})
+	if err != nil {
+		t.Fatalf("NewPatternClassifier() error = %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		text           string
+		wantFlagged    bool
+		wantCategories []string
+		wantSpans      []Span
+	}{
+		{name: "clean", text: "nothing to see here"},
+		{name: "keyword substring", text: "secretary"},
+		{
+			name:           "keyword any case",
+			text:           "the SECRET plan",
+			wantFlagged:    true,
+			wantCategories: []string{CategoryKeyword},
+			wantSpans:      []Span{{4, 10}},
+		},
+		{
+			name:           "keyword and pattern",
+			text:           "secret 1234-5678",
+			wantFlagged:    true,
+			wantCategories: []string{CategoryKeyword, CategoryPattern},
+			wantSpans:      []Span{{0, 6}, {7, 16}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict, err := classifier.Classify(context.Background(), tt.text)
+			if err != nil {
+				t.Fatalf("Classify() error = %v", err)
+			}
+			if verdict.Flagged != tt.wantFlagged {
+				t.Errorf("Flagged = %v, want %v", verdict.Flagged, tt.wantFlagged)
+			}
+			if !reflect.DeepEqual(verdict.Categories, tt.wantCategories) {
+				t.Errorf("Categories = %v, want %v", verdict.Categories, tt.wantCategories)
+			}
+			if !reflect.DeepEqual(verdict.Spans, tt.wantSpans) {
+				t.Errorf("Spans = %v, want %v", verdict.Spans, tt.wantSpans)
+			}
+		})
+	}
+
+	if _, err := NewPatternClassifier(nil, []string{"("}); err == nil {
+		t.Error("Expected an error for an invalid pattern")
+	}
+}
+
+func TestModerator_Check(t *testing.T) {
+	classifier, _ := NewPatternClassifier([]string{"secret"}, nil)
+
+	tests := []struct {
+		name       string
+		classifier Classifier
+		action     Action
+		text       string
+		want       string
+		wantErr    bool
+	}{
+		{name: "pass", classifier: classifier, action: ActionBlock, text: "hello", want: "hello"},
+		{name: "block", classifier: classifier, action: ActionBlock, text: "a secret", wantErr: true},
+		{name: "redact", classifier: classifier, action: ActionRedact, text: "a secret and a Secret!", want: "a [redacted] and a [redacted]!"},
+		{name: "flag", classifier: classifier, action: ActionFlag, text: "a secret", want: "a secret"},
+		{name: "classifier failure fails open", classifier: failingClassifier{}, action: ActionBlock, text: "a secret", want: "a secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			moderator := NewModerator(tt.classifier, tt.action, tt.action, 0)
+			got, err := moderator.Check(context.Background(), StageInput, tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				var appErr *apperror.AppError
+				if !errors.As(err, &appErr) || appErr.Type != apperror.ErrorTypeModeration {
+					t.Errorf("Check() error = %v, want a moderation error", err)
+				}
+			}
+			if got != tt.want {
+				t.Errorf("Check() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		spans []Span
+		want  string
+	}{
+		{name: "no spans", text: "anything", want: RedactedText},
+		{name: "unsorted", text: "abcdef", spans: []Span{{4, 5}, {0, 1}}, want: "[redacted]bcd[redacted]f"},
+		{name: "overlapping", text: "abcdef", spans: []Span{{1, 3}, {2, 4}}, want: "a[redacted]ef"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := redact(tt.text, tt.spans); got != tt.want {
+				t.Errorf("redact() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStream_Windows(t *testing.T) {
+	classifier, _ := NewPatternClassifier([]string{"secret"}, nil)
+	moderator := NewModerator(classifier, ActionBlock, ActionRedact, 10)
+
+	var emitted []string
+	stream := moderator.NewStream(context.Background(), func(token string) error {
+		emitted = append(emitted, token)
+		return nil
+	})
+
+	// The keyword straddles two tokens but is still caught because windows end at whitespace
+	for _, token := range []string{"the sec", "ret plan ", "is ready"} {
+		if err := stream.Write(token); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := stream.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	want := []string{"the [redacted] plan ", "is ready"}
+	if !reflect.DeepEqual(emitted, want) {
+		t.Errorf("Emitted = %q, want %q", emitted, want)
+	}
+	if got := stream.Text(); got != strings.Join(want, "") {
+		t.Errorf("Text() = %q", got)
+	}
+}
+
+func TestStream_Block(t *testing.T) {
+	classifier, _ := NewPatternClassifier([]string{"secret"}, nil)
+	moderator := NewModerator(classifier, ActionBlock, ActionBlock, 5)
+
+	var emitted []string
+	stream := moderator.NewStream(context.Background(), func(token string) error {
+		emitted = append(emitted, token)
+		return nil
+	})
+
+	if err := stream.Write("fine words "); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := stream.Write("a secret "); err == nil {
+		t.Fatal("Expected the window with the keyword to be blocked")
+	}
+	if !reflect.DeepEqual(emitted, []string{"fine words "}) {
+		t.Errorf("Emitted = %q, blocked text must not reach the client", emitted)
+	}
+}
+
+func TestStream_PhraseSpanningWindows(t *testing.T) {
+	tests := []struct {
+		name        string
+		action      Action
+		wantEmitted []string
+		wantErr     bool
+	}{
+		{name: "block", action: ActionBlock, wantEmitted: []string{"here are the launch "}, wantErr: true},
+		{name: "redact", action: ActionRedact, wantEmitted: []string{"here are the launch ", "[redacted] for ", "you"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			classifier, _ := NewPatternClassifier([]string{"launch codes"}, nil)
+			moderator := NewModerator(classifier, ActionBlock, tt.action, 10)
+
+			var emitted []string
+			stream := moderator.NewStream(context.Background(), func(token string) error {
+				emitted = append(emitted, token)
+				return nil
+			})
+
+			// The phrase starts in the first window and ends in the second
+			var err error
+			for _, token := range []string{"here are the launch ", "codes for you"} {
+				if err = stream.Write(token); err != nil {
+					break
+				}
+			}
+			if err == nil {
+				err = stream.Flush()
+			}
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(emitted, tt.wantEmitted) {
+				t.Errorf("Emitted = %q, want %q", emitted, tt.wantEmitted)
+			}
+		})
+	}
+}
+
+func TestStream_FlaggedWithoutSpans(t *testing.T) {
+	moderator := NewModerator(wordClassifier{word: "bad"}, ActionBlock, ActionFlag, 10)
+	flags := metrics.ModerationResultsTotal.WithLabelValues(string(StageOutput), string(ActionFlag))
+	before := testutil.ToFloat64(flags)
+
+	var emitted []string
+	stream := moderator.NewStream(context.Background(), func(token string) error {
+		emitted = append(emitted, token)
+		return nil
+	})
+
+	tokens := []string{"this is bad ", "then fine words ", "and more words ", "the end"}
+	for _, token := range tokens {
+		if err := stream.Write(token); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := stream.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(emitted, tokens) {
+		t.Errorf("Emitted = %q, want %q", emitted, tokens)
+	}
+	// The flagged window must not flag the windows classified after it
+	if got := testutil.ToFloat64(flags) - before; got != 1 {
+		t.Errorf("flagged %v windows, want 1", got)
+	}
+}
+
+func TestStream_EndMessage(t *testing.T) {
+	moderator := NewModerator(wordClassifier{word: "bad"}, ActionBlock, ActionRedact, 100)
+
+	var emitted []string
+	stream := moderator.NewStream(context.Background(), func(token string) error {
+		emitted = append(emitted, token)
+		return nil
+	})
+
+	for _, step := range []func() error{
+		func() error { return stream.Write("Let me check. ") },
+		stream.EndMessage,
+		func() error { return stream.Write("It is fine") },
+		stream.Flush,
+	} {
+		if err := step(); err != nil {
+			t.Fatalf("error = %v", err)
+		}
+	}
+
+	if want := []string{"Let me check. ", "It is fine"}; !reflect.DeepEqual(emitted, want) {
+		t.Errorf("Emitted = %q, want %q", emitted, want)
+	}
+	if got := stream.Text(); got != "It is fine" {
+		t.Errorf("Text() = %q, want only the message after EndMessage", got)
+	}
+}
+
+func TestParseGuardResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    Verdict
+		wantErr bool
+	}{
+		{name: "safe", content: "safe", want: Verdict{}},
+		{name: "unsafe with categories", content: "\nunsafe\nS1, S10\n", want: Verdict{Flagged: true, Categories: []string{"S1", "S10"}}},
+		{name: "unsafe without categories", content: "unsafe", want: Verdict{Flagged: true}},
+		{name: "unexpected", content: "I cannot help with that", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseGuardResponse(tt.content)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseGuardResponse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseGuardResponse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Categories reported by the pattern classifier
+const (
+	CategoryKeyword = "keyword"
+	CategoryPattern = "pattern"
+)
+
+type patternRule struct {
+	category string
+	pattern  *regexp.Regexp
+}
+
+// PatternClassifier flags text containing blocked keywords or matching custom regular expressions
+type PatternClassifier struct {
+	rules []patternRule
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewPatternClassifier creates a classifier from whole-word, case-insensitive keywords and custom patterns
+func NewPatternClassifier(keywords, patterns []string) (*PatternClassifier, error) {
+	c := &PatternClassifier{}
+
+	var quoted []string
+	for _, keyword := range keywords {
+		if keyword = strings.TrimSpace(keyword); keyword != "" {
+			quoted = append(quoted, regexp.QuoteMeta(keyword))
+		}
+	}
+	if len(quoted) > 0 {
+		c.rules = append(c.rules, patternRule{
+			category: CategoryKeyword,
+			pattern:  regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`),
+		})
+	}
+
+	for _, expr := range patterns {
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid moderation pattern %q: %w", expr, err)
+		}
+		c.rules = append(c.rules, patternRule{category: CategoryPattern, pattern: pattern})
+	}
+
+	return c, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (c *PatternClassifier) Classify(ctx context.Context, text string) (Verdict, error) {
+	var verdict Verdict
+	for _, rule := range c.rules {
+		matches := rule.pattern.FindAllStringIndex(text, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		verdict.Flagged = true
+		verdict.Categories = append(verdict.Categories, rule.category)
+		for _, match := range matches {
+			verdict.Spans = append(verdict.Spans, Span{Start: match[0], End: match[1]})
+		}
+	}
+	return verdict, nil
+}
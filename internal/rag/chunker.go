@@ -0,0 +1,62 @@
+package rag
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tiktoken-go/tokenizer"
+)
+
+// Chunker splits text into overlapping windows of tokens
+type Chunker struct {
+	codec   tokenizer.Codec
+	size    int
+	overlap int
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewChunker creates a chunker producing windows of size tokens, each sharing overlap tokens with the
+// previous one. Tokens are counted with the same cl100k_base encoding used for the token cache.
+func NewChunker(size, overlap int) (*Chunker, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("chunk size must be positive, got %d", size)
+	}
+	if overlap < 0 || overlap >= size {
+		return nil, fmt.Errorf("chunk overlap must be between 0 and %d, got %d", size-1, overlap)
+	}
+
+	codec, err := tokenizer.Get(tokenizer.Cl100kBase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tokenizer: %w", err)
+	}
+
+	return &Chunker{codec: codec, size: size, overlap: overlap}, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Split returns the non-empty chunks of text in order
+func (c *Chunker) Split(text string) ([]string, error) {
+	tokens, _, err := c.codec.Encode(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode text: %w", err)
+	}
+
+	var chunks []string
+	for start := 0; start < len(tokens); start += c.size - c.overlap {
+		end := min(start+c.size, len(tokens))
+
+		piece, err := c.codec.Decode(tokens[start:end])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode chunk: %w", err)
+		}
+		// A window boundary can fall inside a multi-byte character
+		if piece = strings.TrimSpace(strings.ToValidUTF8(piece, "")); piece != "" {
+			chunks = append(chunks, piece)
+		}
+
+		if end == len(tokens) {
+			break
+		}
+	}
+	return chunks, nil
+}
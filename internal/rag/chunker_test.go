@@ -0,0 +1,75 @@
+package rag
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChunker_Split(t *testing.T) {
+	chunker, err := NewChunker(10, 2)
+	if err != nil {
+		t.Fatalf("NewChunker() error = %v", err)
+	}
+
+	text := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 10)
+	chunks, err := chunker.Split(text)
+	if err != nil {
+		t.Fatalf("Split() error = %v", err)
+	}
+	// Windows of 10 tokens advance by 8, so the last window starts before the final 10 tokens
+	tokens, _, _ := chunker.codec.Encode(text)
+	want := (len(tokens)-10+7)/8 + 1
+	if len(chunks) != want {
+		t.Fatalf("Expected %d chunks for %d tokens, got %d", want, len(tokens), len(chunks))
+	}
+	if !strings.HasPrefix(text, chunks[0]) {
+		t.Errorf("First chunk %q is not a prefix of the text", chunks[0])
+	}
+}
+
+func TestChunker_SplitEmpty(t *testing.T) {
+	chunker, err := NewChunker(10, 0)
+	if err != nil {
+		t.Fatalf("NewChunker() error = %v", err)
+	}
+
+	chunks, err := chunker.Split("   \n  ")
+	if err != nil {
+		t.Fatalf("Split() error = %v", err)
+	}
+	if len(chunks) != 0 {
+		t.Errorf("Expected no chunks for blank text, got %q", chunks)
+	}
+}
+
+func TestNewChunker_InvalidOverlap(t *testing.T) {
+	if _, err := NewChunker(10, 10); err == nil {
+		t.Error("Expected an error when overlap is not smaller than size")
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		contentType string
+		filename    string
+		want        string
+		wantErr     bool
+	}{
+		{contentType: "text/plain; charset=utf-8", want: FormatText},
+		{contentType: "text/markdown", want: FormatMarkdown},
+		{contentType: "application/pdf", want: FormatPDF},
+		{contentType: "application/octet-stream", filename: "guide.MD", want: FormatMarkdown},
+		{contentType: "image/png", filename: "logo.png", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := DetectFormat(tt.contentType, tt.filename)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("DetectFormat(%q, %q) error = %v, wantErr %v", tt.contentType, tt.filename, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("DetectFormat(%q, %q) = %q, want %q", tt.contentType, tt.filename, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package rag
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// Supported document formats
+const (
+	FormatText     = "text"
+	FormatMarkdown = "markdown"
+	FormatPDF      = "pdf"
+)
+
+// ------------------------------------------------------------------------------------------------------
+// DetectFormat picks the document format from the content type, falling back to the file extension
+func DetectFormat(contentType, filename string) (string, error) {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		switch mediaType {
+		case "text/plain":
+			return FormatText, nil
+		case "text/markdown", "text/x-markdown":
+			return FormatMarkdown, nil
+		case "application/pdf":
+			return FormatPDF, nil
+		}
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".txt", ".text":
+		return FormatText, nil
+	case ".md", ".markdown":
+		return FormatMarkdown, nil
+	case ".pdf":
+		return FormatPDF, nil
+	}
+
+	return "", fmt.Errorf("unsupported document type %q: expected plain text, Markdown or PDF", contentType)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// ExtractText returns the text content of a document. Markdown is kept as is; the model reads it fine.
+func ExtractText(format string, data []byte) (string, error) {
+	switch format {
+	case FormatText, FormatMarkdown:
+		if !utf8.Valid(data) {
+			return "", errors.New("document is not valid UTF-8")
+		}
+		return string(data), nil
+	case FormatPDF:
+		return extractPDFText(data)
+	default:
+		return "", fmt.Errorf("unsupported document format %q", format)
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// extractPDFText returns the plain text layer of a PDF. Scanned PDFs without a text layer yield nothing.
+func extractPDFText(data []byte) (text string, err error) {
+	// The PDF parser panics on some malformed inputs
+	defer func() {
+		if r := recover(); r != nil {
+			text, err = "", fmt.Errorf("malformed PDF: %v", r)
+		}
+	}()
+
+	reader, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("failed to open PDF: %w", err)
+	}
+
+	plain, err := reader.GetPlainText()
+	if err != nil {
+		return "", fmt.Errorf("failed to extract PDF text: %w", err)
+	}
+
+	content, err := io.ReadAll(plain)
+	if err != nil {
+		return "", fmt.Errorf("failed to extract PDF text: %w", err)
+	}
+	return strings.ToValidUTF8(string(content), ""), nil
+}
@@ -0,0 +1,66 @@
+package rag
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// HashEmbedder is a local bag-of-words embedder using feature hashing. It needs no external service,
+// which makes it useful for development and tests, but only matches on shared words.
+type HashEmbedder struct {
+	dimensions int
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewHashEmbedder creates an embedder producing vectors with the given number of dimensions
+func NewHashEmbedder(dimensions int) *HashEmbedder {
+	if dimensions <= 0 {
+		dimensions = 256
+	}
+	return &HashEmbedder{dimensions: dimensions}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (e *HashEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = e.embed(text)
+	}
+	return vectors, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (e *HashEmbedder) embed(text string) []float32 {
+	vector := make([]float32, e.dimensions)
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		h := fnv.New64a()
+		h.Write([]byte(word))
+		sum := h.Sum64()
+
+		// The top bit picks the sign so colliding words tend to cancel out instead of adding up
+		sign := float32(1)
+		if sum>>63 == 1 {
+			sign = -1
+		}
+		vector[sum%uint64(e.dimensions)] += sign
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm > 0 {
+		scale := float32(1 / math.Sqrt(norm))
+		for i := range vector {
+			vector[i] *= scale
+		}
+	}
+	return vector
+}
@@ -0,0 +1,115 @@
+package rag
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	apperror "llm-chat-service/internal/error"
+	"llm-chat-service/internal/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HTTPEmbedder calls an OpenAI-compatible /embeddings endpoint
+type HTTPEmbedder struct {
+	url        string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+}
+
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewHTTPEmbedder creates an embedder for the endpoint at url
+func NewHTTPEmbedder(url, apiKey, model string) *HTTPEmbedder {
+	return &HTTPEmbedder{
+		url:    url,
+		apiKey: apiKey,
+		model:  model,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (e *HTTPEmbedder) Embed(ctx context.Context, texts []string) (_ [][]float32, err error) {
+	ctx, span := tracing.StartSpan(ctx, "HTTPEmbedder.Embed",
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("embedding.model", e.model),
+			attribute.Int("embedding.inputs", len(texts)),
+		),
+	)
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	body, err := json.Marshal(embeddingRequest{Model: e.model, Input: texts})
+	if err != nil {
+		return nil, apperror.NewInternalError("failed to marshal embedding request", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, apperror.NewInternalError("failed to create embedding request", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, apperror.NewLLMError("failed to send embedding request", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, apperror.NewLLMError(
+			"embedding request failed",
+			fmt.Errorf("status %d, response: %s", resp.StatusCode, string(bodyBytes)),
+		)
+	}
+
+	var embeddings embeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embeddings); err != nil {
+		return nil, apperror.NewLLMError("failed to decode embedding response", err)
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, item := range embeddings.Data {
+		if item.Index < 0 || item.Index >= len(texts) {
+			return nil, apperror.NewLLMError("embedding response has an out-of-range index", nil)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	for i, vector := range vectors {
+		if len(vector) == 0 {
+			return nil, apperror.NewLLMError(fmt.Sprintf("embedding response is missing input %d", i), nil)
+		}
+	}
+	return vectors, nil
+}
@@ -0,0 +1,56 @@
+package rag
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+)
+
+// Document is a source file submitted for ingestion
+type Document struct {
+	ID     string
+	Source string // File name or caller-supplied title, shown in citations
+	Format string
+	Text   string
+}
+
+// Chunk is an embedded slice of a document
+type Chunk struct {
+	ID            string    `json:"id"`
+	KnowledgeBase string    `json:"knowledge_base"`
+	DocumentID    string    `json:"document_id"`
+	Source        string    `json:"source"`
+	Index         int       `json:"index"`
+	Text          string    `json:"text"`
+	Vector        []float32 `json:"vector"`
+}
+
+// SearchResult is a chunk ranked by cosine similarity to the query
+type SearchResult struct {
+	Chunk Chunk
+	Score float64
+}
+
+// Embedder turns texts into vectors; the i-th vector belongs to the i-th text
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Index stores chunk vectors per knowledge base
+type Index interface {
+	Upsert(ctx context.Context, chunks []Chunk) error
+	DeleteDocument(ctx context.Context, knowledgeBase, documentID string) error
+	Search(ctx context.Context, knowledgeBase string, vector []float32, k int) ([]SearchResult, error)
+	Close() error
+}
+
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ------------------------------------------------------------------------------------------------------
+// ValidateName checks a knowledge base or document name; names are used verbatim in index keys
+func ValidateName(kind, name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("invalid %s '%s': must be 1-64 letters, digits, '-' or '_'", kind, name)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package rag
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+)
+
+// MemoryIndex keeps chunks in process memory and searches them exhaustively
+type MemoryIndex struct {
+	mu     sync.RWMutex
+	chunks map[string]map[string]Chunk // knowledge base -> chunk ID -> chunk
+}
+
+// ------------------------------------------------------------------------------------------------------
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{chunks: make(map[string]map[string]Chunk)}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (m *MemoryIndex) Upsert(ctx context.Context, chunks []Chunk) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, chunk := range chunks {
+		kb := m.chunks[chunk.KnowledgeBase]
+		if kb == nil {
+			kb = make(map[string]Chunk)
+			m.chunks[chunk.KnowledgeBase] = kb
+		}
+		kb[chunk.ID] = chunk
+	}
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (m *MemoryIndex) DeleteDocument(ctx context.Context, knowledgeBase, documentID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, chunk := range m.chunks[knowledgeBase] {
+		if chunk.DocumentID == documentID {
+			delete(m.chunks[knowledgeBase], id)
+		}
+	}
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (m *MemoryIndex) Search(ctx context.Context, knowledgeBase string, vector []float32, k int) ([]SearchResult, error) {
+	m.mu.RLock()
+	chunks := make([]Chunk, 0, len(m.chunks[knowledgeBase]))
+	for _, chunk := range m.chunks[knowledgeBase] {
+		chunks = append(chunks, chunk)
+	}
+	m.mu.RUnlock()
+
+	return rank(chunks, vector, k), nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (m *MemoryIndex) Close() error {
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// rank returns the k chunks most similar to vector, best first
+func rank(chunks []Chunk, vector []float32, k int) []SearchResult {
+	results := make([]SearchResult, 0, len(chunks))
+	for _, chunk := range chunks {
+		results = append(results, SearchResult{Chunk: chunk, Score: cosine(vector, chunk.Vector)})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Chunk.ID < results[j].Chunk.ID
+	})
+
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+// ------------------------------------------------------------------------------------------------------
+// cosine returns the cosine similarity of a and b, or 0 when their dimensions differ
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
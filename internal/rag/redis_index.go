@@ -0,0 +1,120 @@
+package rag
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"llm-chat-service/internal/redisconn"
+	"llm-chat-service/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RedisIndex stores each knowledge base as a Redis hash of chunk ID -> JSON chunk. Search loads the
+// whole hash and ranks it in process, which suits knowledge bases of up to a few thousand chunks.
+type RedisIndex struct {
+	client *redisconn.Client
+	prefix string
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewRedisIndex creates an index storing knowledge bases under "<prefix>:<knowledge base>"
+func NewRedisIndex(client *redisconn.Client, prefix string) *RedisIndex {
+	return &RedisIndex{client: client, prefix: prefix}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (r *RedisIndex) Upsert(ctx context.Context, chunks []Chunk) (err error) {
+	ctx, span := startRedisSpan(ctx, "RedisIndex.Upsert", "HSET")
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	pipe := r.client.TxPipeline()
+	for _, chunk := range chunks {
+		data, err := json.Marshal(chunk)
+		if err != nil {
+			return fmt.Errorf("failed to marshal chunk: %w", err)
+		}
+		pipe.HSet(ctx, r.key(chunk.KnowledgeBase), chunk.ID, data)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (r *RedisIndex) DeleteDocument(ctx context.Context, knowledgeBase, documentID string) (err error) {
+	ctx, span := startRedisSpan(ctx, "RedisIndex.DeleteDocument", "HDEL")
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	key := r.key(knowledgeBase)
+	iter := r.client.HScan(ctx, key, 0, chunkIDPrefix(documentID)+"*", 100).Iterator()
+
+	var fields []string
+	for iter.Next(ctx) {
+		// HSCAN yields field, value pairs; only the fields are needed
+		fields = append(fields, iter.Val())
+		iter.Next(ctx)
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	return r.client.HDel(ctx, key, fields...).Err()
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (r *RedisIndex) Search(ctx context.Context, knowledgeBase string, vector []float32, k int) (_ []SearchResult, err error) {
+	ctx, span := startRedisSpan(ctx, "RedisIndex.Search", "HVALS")
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	values, err := r.client.HVals(ctx, r.key(knowledgeBase)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make([]Chunk, 0, len(values))
+	for _, value := range values {
+		var chunk Chunk
+		if err := json.Unmarshal([]byte(value), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal chunk: %w", err)
+		}
+		chunks = append(chunks, chunk)
+	}
+	span.SetAttributes(attribute.Int("rag.chunks.scanned", len(chunks)))
+
+	return rank(chunks, vector, k), nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (r *RedisIndex) Close() error {
+	return r.client.Close()
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (r *RedisIndex) key(knowledgeBase string) string {
+	return r.prefix + ":" + knowledgeBase
+}
+
+// ------------------------------------------------------------------------------------------------------
+// startRedisSpan starts a client span describing a single Redis command
+func startRedisSpan(ctx context.Context, name, command string) (context.Context, trace.Span) {
+	return tracing.StartSpan(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "redis"),
+			attribute.String("db.operation", command),
+		),
+	)
+}
@@ -0,0 +1,170 @@
+package rag
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	apperror "llm-chat-service/internal/error"
+	"llm-chat-service/internal/logging"
+	"llm-chat-service/internal/metrics"
+	"llm-chat-service/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// embedBatchSize caps how many chunks are sent to the embedder per call
+const embedBatchSize = 64
+
+// Service ingests documents into knowledge bases and retrieves the chunks relevant to a query
+type Service struct {
+	chunker  *Chunker
+	embedder Embedder
+	index    Index
+	topK     int
+}
+
+// IngestResult describes a stored document
+type IngestResult struct {
+	KnowledgeBase string `json:"knowledge_base"`
+	DocumentID    string `json:"document_id"`
+	Source        string `json:"source"`
+	Format        string `json:"format"`
+	Chunks        int    `json:"chunks"`
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewService creates a service retrieving topK chunks per query
+func NewService(chunker *Chunker, embedder Embedder, index Index, topK int) *Service {
+	if topK <= 0 {
+		topK = 4
+	}
+	return &Service{
+		chunker:  chunker,
+		embedder: embedder,
+		index:    index,
+		topK:     topK,
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Ingest chunks, embeds and stores doc, replacing any earlier version with the same document ID.
+// Documents without an ID are identified by a hash of their text, so re-uploads are idempotent.
+func (s *Service) Ingest(ctx context.Context, knowledgeBase string, doc Document) (_ *IngestResult, err error) {
+	ctx, span := tracing.StartSpan(ctx, "RAGService.Ingest", trace.WithAttributes(
+		attribute.String("rag.knowledge_base", knowledgeBase),
+		attribute.String("rag.document.format", doc.Format),
+	))
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	if err := ValidateName("knowledge base", knowledgeBase); err != nil {
+		return nil, apperror.NewValidationError(err.Error(), nil)
+	}
+	if doc.ID == "" {
+		hash := sha256.Sum256([]byte(doc.Text))
+		doc.ID = hex.EncodeToString(hash[:8])
+	}
+	if err := ValidateName("document ID", doc.ID); err != nil {
+		return nil, apperror.NewValidationError(err.Error(), nil)
+	}
+	if doc.Source == "" {
+		doc.Source = doc.ID
+	}
+
+	texts, err := s.chunker.Split(doc.Text)
+	if err != nil {
+		return nil, apperror.NewInternalError("failed to chunk document", err)
+	}
+	if len(texts) == 0 {
+		return nil, apperror.NewValidationError("document contains no text", nil)
+	}
+
+	chunks := make([]Chunk, 0, len(texts))
+	for start := 0; start < len(texts); start += embedBatchSize {
+		batch := texts[start:min(start+embedBatchSize, len(texts))]
+
+		vectors, err := s.embedder.Embed(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+
+		for i, text := range batch {
+			index := start + i
+			chunks = append(chunks, Chunk{
+				ID:            fmt.Sprintf("%s%04d", chunkIDPrefix(doc.ID), index),
+				KnowledgeBase: knowledgeBase,
+				DocumentID:    doc.ID,
+				Source:        doc.Source,
+				Index:         index,
+				Text:          text,
+				Vector:        vectors[i],
+			})
+		}
+	}
+
+	if err := s.index.DeleteDocument(ctx, knowledgeBase, doc.ID); err != nil {
+		return nil, apperror.NewInternalError("failed to replace document", err)
+	}
+	if err := s.index.Upsert(ctx, chunks); err != nil {
+		return nil, apperror.NewInternalError("failed to store document", err)
+	}
+
+	metrics.RAGDocumentsIngestedTotal.WithLabelValues(doc.Format).Inc()
+	span.SetAttributes(attribute.Int("rag.chunks.count", len(chunks)))
+	logging.FromContext(ctx).Info("Document ingested",
+		zap.String("knowledge_base", knowledgeBase),
+		zap.String("document_id", doc.ID),
+		zap.Int("chunks", len(chunks)),
+	)
+
+	return &IngestResult{
+		KnowledgeBase: knowledgeBase,
+		DocumentID:    doc.ID,
+		Source:        doc.Source,
+		Format:        doc.Format,
+		Chunks:        len(chunks),
+	}, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Retrieve returns the chunks of knowledgeBase most similar to query, best first
+func (s *Service) Retrieve(ctx context.Context, knowledgeBase, query string) (_ []SearchResult, err error) {
+	ctx, span := tracing.StartSpan(ctx, "RAGService.Retrieve", trace.WithAttributes(
+		attribute.String("rag.knowledge_base", knowledgeBase),
+		attribute.Int("rag.top_k", s.topK),
+	))
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	vectors, err := s.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := s.index.Search(ctx, knowledgeBase, vectors[0], s.topK)
+	if err != nil {
+		return nil, apperror.NewInternalError("knowledge base search failed", err)
+	}
+
+	span.SetAttributes(attribute.Int("rag.results.count", len(results)))
+	return results, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (s *Service) Close() error {
+	return s.index.Close()
+}
+
+// ------------------------------------------------------------------------------------------------------
+// chunkIDPrefix is shared by all chunk IDs of a document so they can be found by prefix
+func chunkIDPrefix(documentID string) string {
+	return documentID + ":"
+}
@@ -0,0 +1,99 @@
+package rag
+
+import (
+	"context"
+	"testing"
+)
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	chunker, err := NewChunker(20, 0)
+	if err != nil {
+		t.Fatalf("NewChunker() error = %v", err)
+	}
+	return NewService(chunker, NewHashEmbedder(256), NewMemoryIndex(), 2)
+}
+
+func TestService_IngestAndRetrieve(t *testing.T) {
+	ctx := context.Background()
+	service := newTestService(t)
+
+	_, err := service.Ingest(ctx, "docs", Document{
+		ID:     "billing",
+		Source: "billing.md",
+		Format: FormatMarkdown,
+		Text:   "Invoices are sent on the first day of every month by email.",
+	})
+	if err != nil {
+		t.Fatalf("Ingest() error = %v", err)
+	}
+	_, err = service.Ingest(ctx, "docs", Document{
+		Source: "security.md",
+		Format: FormatMarkdown,
+		Text:   "Passwords must be rotated every ninety days.",
+	})
+	if err != nil {
+		t.Fatalf("Ingest() error = %v", err)
+	}
+
+	results, err := service.Retrieve(ctx, "docs", "When are invoices sent?")
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+	if len(results) == 0 || results[0].Chunk.Source != "billing.md" {
+		t.Fatalf("Expected billing.md to rank first, got %+v", results)
+	}
+
+	// Knowledge bases are isolated
+	results, err = service.Retrieve(ctx, "other", "When are invoices sent?")
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no results from an empty knowledge base, got %d", len(results))
+	}
+}
+
+func TestService_IngestReplacesDocument(t *testing.T) {
+	ctx := context.Background()
+	service := newTestService(t)
+
+	doc := Document{ID: "faq", Format: FormatText, Text: "Support is open on weekdays."}
+	if _, err := service.Ingest(ctx, "docs", doc); err != nil {
+		t.Fatalf("Ingest() error = %v", err)
+	}
+	doc.Text = "Support is open every day of the week."
+	if _, err := service.Ingest(ctx, "docs", doc); err != nil {
+		t.Fatalf("Ingest() error = %v", err)
+	}
+
+	results, err := service.Retrieve(ctx, "docs", "When is support open?")
+	if err != nil {
+		t.Fatalf("Retrieve() error = %v", err)
+	}
+	if len(results) != 1 || results[0].Chunk.Text != doc.Text {
+		t.Errorf("Expected only the new version of the document, got %+v", results)
+	}
+}
+
+func TestService_IngestValidation(t *testing.T) {
+	service := newTestService(t)
+
+	tests := []struct {
+		name string
+		kb   string
+		doc  Document
+	}{
+		{name: "invalid knowledge base", kb: "../etc", doc: Document{Text: "hello"}},
+		{name: "invalid document ID", kb: "docs", doc: Document{ID: "a b", Text: "hello"}},
+		{name: "blank document", kb: "docs", doc: Document{Text: "  "}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := service.Ingest(context.Background(), tt.kb, tt.doc); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+// Package redisconn connects to Redis in standalone, Sentinel or Cluster mode and keeps reconnecting in
+// the background while Redis is unreachable, so a Redis outage degrades the service instead of
+// disabling its Redis-backed stores until a restart
+package redisconn
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"llm-chat-service/internal/metrics"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// Deployment modes
+const (
+	ModeStandalone = "standalone"
+	ModeSentinel   = "sentinel" // Addrs are the Sentinels, MasterName the monitored master
+	ModeCluster    = "cluster"  // Addrs are seed nodes
+)
+
+// Reconnection attempts back off exponentially between these bounds
+const (
+	defaultMinBackoff = 500 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+	pingTimeout       = 2 * time.Second
+)
+
+// ErrUnavailable is returned without contacting Redis while the client is reconnecting
+var ErrUnavailable = errors.New("redis unavailable, reconnecting in the background")
+
+// Options selects the deployment mode and carries the connection settings
+type Options struct {
+	Mode string
+	redis.UniversalOptions
+}
+
+// Client is a Redis client that fails fast while Redis is unreachable. The first command failing
+// for lack of a connection marks the client down and starts a background loop pinging Redis until
+// it answers again.
+type Client struct {
+	redis.UniversalClient
+	name   string
+	logger *zap.Logger
+
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	up           atomic.Bool
+	reconnecting atomic.Bool
+	closed       chan struct{}
+	closeOnce    sync.Once
+}
+
+// ------------------------------------------------------------------------------------------------------
+// New creates a client named after its use (e.g. "cache"), which labels its metrics. It does not fail
+// when Redis is unreachable: the client starts down and connects once Redis answers.
+func New(name string, opts *Options, logger *zap.Logger) *Client {
+	return newClient(name, opts, logger, defaultMinBackoff, defaultMaxBackoff)
+}
+
+// ------------------------------------------------------------------------------------------------------
+func newClient(name string, opts *Options, logger *zap.Logger, minBackoff, maxBackoff time.Duration) *Client {
+	c := &Client{
+		UniversalClient: newUniversalClient(opts),
+		name:            name,
+		logger:          logger.With(zap.String("redis_client", name)),
+		minBackoff:      minBackoff,
+		maxBackoff:      maxBackoff,
+		closed:          make(chan struct{}),
+	}
+	c.AddHook(availabilityHook{c})
+	metrics.RedisUp.WithLabelValues(name).Set(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	if err := c.Check(ctx); err != nil && !c.Available() {
+		c.logger.Warn("Redis is unreachable, reconnecting in the background", zap.Error(err))
+		c.markDown(err)
+	}
+	return c
+}
+
+// ------------------------------------------------------------------------------------------------------
+func newUniversalClient(opts *Options) redis.UniversalClient {
+	switch opts.Mode {
+	case ModeSentinel:
+		return redis.NewFailoverClient(opts.Failover())
+	case ModeCluster:
+		return redis.NewClusterClient(opts.Cluster())
+	default:
+		return redis.NewClient(opts.Simple())
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Available reports whether commands are sent to Redis or failed fast
+func (c *Client) Available() bool {
+	return c.up.Load()
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Check pings Redis even while the client is down, so health checks see the actual state
+func (c *Client) Check(ctx context.Context) error {
+	return c.Ping(context.WithValue(ctx, probeKey{}, true)).Err()
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Close stops reconnecting and closes the connections
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return c.UniversalClient.Close()
+}
+
+// ------------------------------------------------------------------------------------------------------
+// observe updates the client state from the outcome of a command
+func (c *Client) observe(ctx context.Context, err error) {
+	switch {
+	case isConnectionError(ctx, err):
+		c.markDown(err)
+	case err == nil || isReply(err):
+		c.markUp()
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (c *Client) markUp() {
+	if !c.up.Swap(true) {
+		metrics.RedisUp.WithLabelValues(c.name).Set(1)
+		c.logger.Info("Connected to Redis")
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (c *Client) markDown(err error) {
+	if c.up.Swap(false) {
+		metrics.RedisUp.WithLabelValues(c.name).Set(0)
+		metrics.RedisConnectionLostTotal.WithLabelValues(c.name).Inc()
+		c.logger.Warn("Lost the Redis connection, reconnecting in the background", zap.Error(err))
+	}
+	if c.reconnecting.CompareAndSwap(false, true) {
+		go c.reconnect()
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// reconnect pings Redis with exponential backoff until it answers or the client is closed
+func (c *Client) reconnect() {
+	backoff := c.minBackoff
+	for {
+		select {
+		case <-c.closed:
+			c.reconnecting.Store(false)
+			return
+		case <-time.After(backoff):
+		}
+
+		// The hook marks the client up when the ping is answered
+		ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+		err := c.Check(ctx)
+		cancel()
+		if err == nil || isReply(err) {
+			c.reconnecting.Store(false)
+			// A command failing since the ping found this loop still running; take over for it
+			if c.up.Load() || !c.reconnecting.CompareAndSwap(false, true) {
+				return
+			}
+			backoff = c.minBackoff
+			continue
+		}
+
+		c.logger.Debug("Redis reconnection attempt failed", zap.Error(err), zap.Duration("next_attempt_in", backoff))
+		backoff = min(2*backoff, c.maxBackoff)
+	}
+}
+
+// probeKey marks the commands that go through while the client is down
+type probeKey struct{}
+
+// ------------------------------------------------------------------------------------------------------
+func isProbe(ctx context.Context) bool {
+	probe, _ := ctx.Value(probeKey{}).(bool)
+	return probe
+}
+
+// ------------------------------------------------------------------------------------------------------
+// isReply reports whether err is an answer from Redis (redis.Nil included) rather than a failure to
+// reach it
+func isReply(err error) bool {
+	var reply redis.Error
+	return errors.As(err, &reply)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// isConnectionError reports whether err means Redis could not be reached. Commands cut short by their
+// caller's context say nothing about Redis, unlike probes timing out.
+func isConnectionError(ctx context.Context, err error) bool {
+	if err == nil || isReply(err) || errors.Is(err, ErrUnavailable) || errors.Is(err, redis.ErrClosed) {
+		return false
+	}
+	return ctx.Err() == nil || isProbe(ctx)
+}
+
+// availabilityHook fails commands fast while the client is down and watches their outcome
+type availabilityHook struct {
+	c *Client
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (h availabilityHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (h availabilityHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if !h.c.up.Load() && !isProbe(ctx) {
+			cmd.SetErr(ErrUnavailable)
+			return ErrUnavailable
+		}
+		err := next(ctx, cmd)
+		h.c.observe(ctx, err)
+		return err
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (h availabilityHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if !h.c.up.Load() && !isProbe(ctx) {
+			for _, cmd := range cmds {
+				cmd.SetErr(ErrUnavailable)
+			}
+			return ErrUnavailable
+		}
+		err := next(ctx, cmds)
+		h.c.observe(ctx, err)
+		return err
+	}
+}
@@ -0,0 +1,119 @@
+package redisconn
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// ------------------------------------------------------------------------------------------------------
+// newTestClient connects to addr, retrying every few milliseconds while it is unreachable
+func newTestClient(t *testing.T, addr string) *Client {
+	t.Helper()
+	opts := &Options{Mode: ModeStandalone, UniversalOptions: redis.UniversalOptions{
+		Addrs:       []string{addr},
+		DialTimeout: 100 * time.Millisecond,
+		MaxRetries:  -1,
+	}}
+	client := newClient("test", opts, zap.NewNop(), 5*time.Millisecond, 20*time.Millisecond)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// ------------------------------------------------------------------------------------------------------
+func waitAvailable(t *testing.T, client *Client) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !client.Available() {
+		if time.Now().After(deadline) {
+			t.Fatal("client did not reconnect")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func TestClient_ReconnectsAfterOutage(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := newTestClient(t, server.Addr())
+	ctx := context.Background()
+
+	if !client.Available() {
+		t.Fatal("client is not available with Redis up")
+	}
+	if err := client.Set(ctx, "key", "value", 0).Err(); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	server.Close()
+	if err := client.Get(ctx, "key").Err(); err == nil {
+		t.Fatal("Get() succeeded with Redis down")
+	}
+	if client.Available() {
+		t.Fatal("client still available after a connection error")
+	}
+	if err := client.Get(ctx, "key").Err(); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Get() while down error = %v, want ErrUnavailable", err)
+	}
+	if err := client.Check(ctx); err == nil || errors.Is(err, ErrUnavailable) {
+		t.Errorf("Check() while down error = %v, want the actual connection error", err)
+	}
+
+	if err := server.Restart(); err != nil {
+		t.Fatalf("failed to restart Redis: %v", err)
+	}
+	waitAvailable(t, client)
+	if err := client.Set(ctx, "key", "again", 0).Err(); err != nil {
+		t.Errorf("Set() after reconnecting error = %v", err)
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func TestClient_StartsWithRedisDown(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to reserve an address: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	client := newTestClient(t, addr)
+	if client.Available() {
+		t.Fatal("client available with Redis down")
+	}
+	if err := client.Get(context.Background(), "key").Err(); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Get() error = %v, want ErrUnavailable", err)
+	}
+
+	server := miniredis.NewMiniRedis()
+	if err := server.StartAddr(addr); err != nil {
+		t.Fatalf("failed to start Redis: %v", err)
+	}
+	t.Cleanup(server.Close)
+
+	waitAvailable(t, client)
+}
+
+// ------------------------------------------------------------------------------------------------------
+func TestClient_RedisErrorsKeepTheConnection(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := newTestClient(t, server.Addr())
+	ctx := context.Background()
+
+	if err := client.Get(ctx, "missing").Err(); !errors.Is(err, redis.Nil) {
+		t.Fatalf("Get() error = %v, want redis.Nil", err)
+	}
+	server.SetError("LOADING Redis is loading the dataset in memory")
+	if err := client.Get(ctx, "key").Err(); err == nil {
+		t.Fatal("Get() succeeded while Redis reports an error")
+	}
+	if !client.Available() {
+		t.Error("an error answered by Redis marked the client down")
+	}
+}
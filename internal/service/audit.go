@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"llm-chat-service/internal/audit"
+	"llm-chat-service/internal/logging"
+)
+
+// ------------------------------------------------------------------------------------------------------
+// recordAudit hands the finished turn to the auditor; the write itself happens asynchronously
+func (s *chatService) recordAudit(ctx context.Context, req *ChatRequest, prompt, response string, err error, start time.Time) {
+	s.writeAudit(newAuditRecord(ctx, req, prompt, response, err, start))
+}
+
+// ------------------------------------------------------------------------------------------------------
+// writeAudit hands a record to the auditor, if any
+func (s *chatService) writeAudit(record audit.Record) {
+	if s.auditor != nil {
+		s.auditor.Record(record)
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// newAuditRecord describes a finished turn
+func newAuditRecord(ctx context.Context, req *ChatRequest, prompt, response string, err error, start time.Time) audit.Record {
+	record := audit.Record{
+		Timestamp:      start.UTC(),
+		RequestID:      logging.RequestIDFromContext(ctx),
+		ConversationID: logging.ConversationIDFromContext(ctx),
+		Tenant:         logging.TenantFromContext(ctx),
+		Stream:         req.Stream,
+		Prompt:         prompt,
+		Response:       response,
+		DurationMs:     time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	return record
+}
@@ -0,0 +1,290 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"llm-chat-service/internal/audit"
+	apperror "llm-chat-service/internal/error"
+	"llm-chat-service/internal/llm"
+	"llm-chat-service/internal/storage"
+)
+
+// defaultMaxCandidates caps n when not configured
+const defaultMaxCandidates = 4
+
+// Candidate is one of several answers generated for a turn with n > 1
+type Candidate struct {
+	Index        int    `json:"index"`
+	Content      string `json:"response"`
+	FinishReason string `json:"finish_reason,omitempty"`
+}
+
+// pendingSelection is a turn with several candidates waiting for the client to choose the one stored
+// in the history
+type pendingSelection struct {
+	id         string
+	candidates []Candidate
+	audit      audit.Record // Audit record of the turn, written once a candidate is stored
+}
+
+// CandidateTokenFunc receives a streamed token of the candidate at index
+type CandidateTokenFunc func(index int, token string) error
+
+// ------------------------------------------------------------------------------------------------------
+// candidates returns how many answers the request asks for
+func (r *ChatRequest) candidates() int {
+	return max(r.N, 1)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// awaitsSelection reports whether the request leaves the choice of the persisted candidate to a
+// SelectCandidate call made once the candidates are known
+func (r *ChatRequest) awaitsSelection() bool {
+	return r.candidates() > 1 && r.PersistIndex == nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// persistIndex returns the candidate to store in the conversation history, or the default one of a
+// turn awaiting selection
+func (r *ChatRequest) persistIndex() int {
+	if r.PersistIndex == nil {
+		return 0
+	}
+	return *r.PersistIndex
+}
+
+// ------------------------------------------------------------------------------------------------------
+// candidateTokenFunc routes streamed tokens to the transport: all candidates go to OnCandidateToken
+// when it is set, otherwise the persisted candidate goes to onToken
+func (r *ChatRequest) candidateTokenFunc(onToken func(string) error) CandidateTokenFunc {
+	if r.candidates() > 1 && r.OnCandidateToken != nil {
+		return r.OnCandidateToken
+	}
+	persist := r.persistIndex()
+	return func(index int, token string) error {
+		if index != persist {
+			return nil
+		}
+		return onToken(token)
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// answer generates one moderated answer, streaming it to onToken when set, with the tool-call turns
+// that led to it
+func (s *chatService) answer(ctx context.Context, req *ChatRequest, messages []llm.Message, onToken func(string) error) (*llm.Result, []llm.Message, error) {
+	if onToken == nil {
+		result, toolTurns, err := s.generate(ctx, req, messages, nil, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		if result.Content, err = s.moderateOutput(ctx, result.Content); err != nil {
+			return nil, nil, err
+		}
+		return result, toolTurns, nil
+	}
+
+	onToken, endMessage, finishModeration := s.moderateStream(ctx, onToken)
+	result, toolTurns, err := s.generate(ctx, req, messages, onToken, endMessage)
+	var content string
+	if err == nil {
+		content = result.Content
+	}
+	if content, err = finishModeration(content, err); err != nil {
+		return nil, nil, err
+	}
+	result.Content = content
+	return result, toolTurns, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// answerCandidates generates the requested number of answers. Several answers come from one upstream
+// call when the provider supports n and nothing is streamed, and from parallel calls otherwise; the
+// first failure cancels the other calls. onToken may be nil for non-streaming requests and is never
+// called concurrently. Tool-call turns are only returned for a single answer, as several candidates
+// would each bring their own.
+func (s *chatService) answerCandidates(ctx context.Context, req *ChatRequest, messages []llm.Message, onToken CandidateTokenFunc) ([]*llm.Result, []llm.Message, error) {
+	n := req.candidates()
+	if n == 1 {
+		var tokenFunc func(string) error
+		if onToken != nil {
+			tokenFunc = func(token string) error { return onToken(0, token) }
+		}
+		result, toolTurns, err := s.answer(ctx, req, messages, tokenFunc)
+		if err != nil {
+			return nil, nil, err
+		}
+		return []*llm.Result{result}, toolTurns, nil
+	}
+
+	if client, ok := s.llmClient.(llm.MultipleChoicesClient); ok && client.SupportsMultipleChoices() && onToken == nil && req.ResponseFormat == nil {
+		results, err := s.answerChoices(ctx, client, messages, n)
+		return results, nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var failed sync.Once
+	var firstErr error
+	results := make([]*llm.Result, n)
+	for i := 0; i < n; i++ {
+		i := i
+		var tokenFunc func(string) error
+		if onToken != nil {
+			tokenFunc = func(token string) error {
+				mu.Lock()
+				defer mu.Unlock()
+				return onToken(i, token)
+			}
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, _, err := s.answer(ctx, req, messages, tokenFunc)
+			if err != nil {
+				// The first failure is the cause; the others are mostly cancellations it triggered
+				failed.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, nil, firstErr
+	}
+	return results, nil, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// answerChoices requests n choices in a single upstream call
+func (s *chatService) answerChoices(ctx context.Context, client llm.MultipleChoicesClient, messages []llm.Message, n int) ([]*llm.Result, error) {
+	opts := llm.ChatOptions{MaxTokens: s.limits.Load().MaxTokens}
+	results, err := client.ChatChoices(ctx, messages, opts, n)
+	if err != nil {
+		return nil, err
+	}
+	for i, result := range results {
+		if result, err = s.autoContinue(ctx, messages, opts, result, nil); err != nil {
+			return nil, err
+		}
+		if result.Content, err = s.moderateOutput(ctx, result.Content); err != nil {
+			return nil, err
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// newChatResponse builds the response from the generated answers. Metadata describes the persisted
+// answer, except usage, which covers all of them.
+func newChatResponse(req *ChatRequest, results []*llm.Result, citations []Citation, warnings []Warning, start time.Time) *ChatResponse {
+	persisted := results[req.persistIndex()]
+	response := &ChatResponse{
+		Content:          persisted.Content,
+		Citations:        citations,
+		Warnings:         warnings,
+		ResponseMetadata: newResponseMetadata(persisted, time.Since(start)),
+	}
+	if len(results) == 1 {
+		return response
+	}
+
+	var usage *llm.Usage
+	for i, result := range results {
+		response.Candidates = append(response.Candidates, Candidate{
+			Index:        i,
+			Content:      result.Content,
+			FinishReason: result.FinishReason,
+		})
+		usage = addUsage(usage, result.Usage)
+	}
+	response.Usage = usage
+	return response
+}
+
+// ------------------------------------------------------------------------------------------------------
+// holdSelection keeps the candidates of a turn until the client selects one and returns the handle to
+// select it with. A turn still awaiting selection is settled first.
+func (s *chatService) holdSelection(candidates []Candidate, record audit.Record) string {
+	s.selectionMu.Lock()
+	defer s.selectionMu.Unlock()
+
+	s.settleSelectionLocked()
+	s.pending = &pendingSelection{id: newSelectionID(), candidates: candidates, audit: record}
+	return s.pending.id
+}
+
+// ------------------------------------------------------------------------------------------------------
+// SelectCandidate stores the candidate at index of the turn identified by selectionID in the history
+func (s *chatService) SelectCandidate(ctx context.Context, selectionID string, index int) (*Candidate, error) {
+	s.selectionMu.Lock()
+	defer s.selectionMu.Unlock()
+
+	if s.pending == nil || s.pending.id != selectionID {
+		return nil, apperror.NewNotFoundError(
+			"unknown candidate selection; a selection is only open until the next chat turn starts", nil,
+		)
+	}
+	if index < 0 || index >= len(s.pending.candidates) {
+		return nil, apperror.NewValidationError(
+			fmt.Sprintf("index must be between 0 and %d, got %d", len(s.pending.candidates)-1, index), nil,
+		)
+	}
+
+	candidate := s.pending.candidates[index]
+	s.storeSelectionLocked(candidate)
+	return &candidate, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// settleSelection stores the default candidate of a turn still awaiting selection, so the history
+// never holds a question without its answer when the next turn reads it
+func (s *chatService) settleSelection() {
+	s.selectionMu.Lock()
+	defer s.selectionMu.Unlock()
+	s.settleSelectionLocked()
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (s *chatService) settleSelectionLocked() {
+	if s.pending == nil {
+		return
+	}
+	s.storeSelectionLocked(s.pending.candidates[0])
+}
+
+// ------------------------------------------------------------------------------------------------------
+// storeSelectionLocked stores candidate as the answer of the pending turn, audits the turn with it and
+// closes the selection
+func (s *chatService) storeSelectionLocked(candidate Candidate) {
+	s.messageStore.AddMessage(storage.Message{Role: "assistant", Content: candidate.Content})
+	record := s.pending.audit
+	record.Response = candidate.Content
+	s.writeAudit(record)
+	s.pending = nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// newSelectionID returns a random 128-bit hex identifier
+func newSelectionID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,322 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"llm-chat-service/internal/audit"
+	"llm-chat-service/internal/llm"
+	"llm-chat-service/internal/storage"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// choicesClient is a mock client for a provider that honours n natively
+type choicesClient struct {
+	mockGroqClient
+	calls int
+}
+
+func (c *choicesClient) SupportsMultipleChoices() bool { return true }
+
+func (c *choicesClient) ChatChoices(ctx context.Context, messages []llm.Message, opts llm.ChatOptions, n int) ([]*llm.Result, error) {
+	c.calls++
+	results := make([]*llm.Result, n)
+	for i := range results {
+		results[i] = &llm.Result{Content: fmt.Sprintf("choice %d", i), FinishReason: llm.FinishReasonStop}
+	}
+	results[0].Usage = &llm.Usage{PromptTokens: 10, CompletionTokens: 3 * n, TotalTokens: 10 + 3*n}
+	return results, nil
+}
+
+func intPtr(i int) *int { return &i }
+
+// recordingSink keeps the audit records written to it
+type recordingSink struct {
+	mu      sync.Mutex
+	records []audit.Record
+}
+
+func (s *recordingSink) Write(ctx context.Context, record audit.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, record)
+	return nil
+}
+
+func (s *recordingSink) Close() error { return nil }
+
+func TestChatService_ProcessChat_Candidates(t *testing.T) {
+	var calls atomic.Int32
+	mockClient := &mockGroqClient{
+		chatFunc: func(messages []llm.Message, opts llm.ChatOptions) (*llm.Result, error) {
+			n := calls.Add(1)
+			return &llm.Result{
+				Content: fmt.Sprintf("answer %d", n),
+				Usage:   &llm.Usage{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12},
+			}, nil
+		},
+	}
+
+	memoryStore := storage.NewMemoryStore(20)
+	service := NewChatService(memoryStore, nil, mockClient, 1024)
+	response, err := service.ProcessChat(context.Background(), &ChatRequest{
+		Messages:     []storage.Message{{Role: "user", Content: "Write a tagline"}},
+		N:            3,
+		PersistIndex: intPtr(2),
+	})
+	if err != nil {
+		t.Fatalf("ProcessChat() error = %v", err)
+	}
+
+	if calls.Load() != 3 {
+		t.Errorf("Upstream calls = %d, want 3 parallel calls", calls.Load())
+	}
+	if len(response.Candidates) != 3 {
+		t.Fatalf("Candidates = %+v, want 3", response.Candidates)
+	}
+	seen := map[string]bool{}
+	for i, candidate := range response.Candidates {
+		if candidate.Index != i {
+			t.Errorf("Candidate %d has index %d", i, candidate.Index)
+		}
+		seen[candidate.Content] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("Expected distinct candidates, got %+v", response.Candidates)
+	}
+	if response.Content != response.Candidates[2].Content {
+		t.Errorf("Content = %q, want the persisted candidate %q", response.Content, response.Candidates[2].Content)
+	}
+	if response.Usage == nil || response.Usage.TotalTokens != 36 {
+		t.Errorf("Usage = %+v, want the sum over all candidates", response.Usage)
+	}
+
+	history := memoryStore.GetMessages()
+	if len(history) != 2 || history[1].Content != response.Candidates[2].Content {
+		t.Errorf("Expected only the persisted candidate in history, got %+v", history)
+	}
+}
+
+func TestChatService_ProcessChat_NativeCandidates(t *testing.T) {
+	client := &choicesClient{}
+	service := NewChatService(storage.NewMemoryStore(20), nil, client, 1024)
+	response, err := service.ProcessChat(context.Background(), &ChatRequest{
+		Messages: []storage.Message{{Role: "user", Content: "Write a tagline"}},
+		N:        2,
+	})
+	if err != nil {
+		t.Fatalf("ProcessChat() error = %v", err)
+	}
+	if client.calls != 1 {
+		t.Errorf("ChatChoices calls = %d, want 1", client.calls)
+	}
+	if len(response.Candidates) != 2 || response.Candidates[1].Content != "choice 1" || response.Content != "choice 0" {
+		t.Errorf("Unexpected candidates %+v", response)
+	}
+}
+
+func TestChatService_SelectCandidate(t *testing.T) {
+	memoryStore := storage.NewMemoryStore(20)
+	service := NewChatService(memoryStore, nil, &choicesClient{}, 1024)
+	selector := service.(CandidateSelector)
+
+	response, err := service.ProcessChat(context.Background(), &ChatRequest{
+		Messages: []storage.Message{{Role: "user", Content: "Write a tagline"}},
+		N:        3,
+	})
+	if err != nil {
+		t.Fatalf("ProcessChat() error = %v", err)
+	}
+	if response.SelectionID == "" {
+		t.Fatal("Expected a selection ID without persist_index")
+	}
+	if history := memoryStore.GetMessages(); len(history) != 1 {
+		t.Fatalf("Expected no answer in history before selection, got %+v", history)
+	}
+
+	if _, err := selector.SelectCandidate(context.Background(), "unknown", 1); err == nil {
+		t.Error("Expected an unknown selection ID to be refused")
+	}
+	if _, err := selector.SelectCandidate(context.Background(), response.SelectionID, 3); err == nil {
+		t.Error("Expected an out-of-range index to be refused")
+	}
+
+	candidate, err := selector.SelectCandidate(context.Background(), response.SelectionID, 2)
+	if err != nil {
+		t.Fatalf("SelectCandidate() error = %v", err)
+	}
+	if candidate.Index != 2 || candidate.Content != "choice 2" {
+		t.Errorf("Selected %+v, want candidate 2", candidate)
+	}
+	history := memoryStore.GetMessages()
+	if len(history) != 2 || history[1].Content != "choice 2" {
+		t.Errorf("Expected the selected candidate in history, got %+v", history)
+	}
+
+	if _, err := selector.SelectCandidate(context.Background(), response.SelectionID, 1); err == nil {
+		t.Error("Expected a selection to be usable once")
+	}
+}
+
+func TestChatService_SelectCandidate_NextTurnSettlesDefault(t *testing.T) {
+	memoryStore := storage.NewMemoryStore(20)
+	service := NewChatService(memoryStore, nil, &choicesClient{}, 1024)
+
+	first, err := service.ProcessChat(context.Background(), &ChatRequest{
+		Messages: []storage.Message{{Role: "user", Content: "Write a tagline"}},
+		N:        2,
+	})
+	if err != nil {
+		t.Fatalf("ProcessChat() error = %v", err)
+	}
+	if _, err := service.ProcessChat(context.Background(), &ChatRequest{
+		Messages: []storage.Message{{Role: "user", Content: "Shorter"}},
+	}); err != nil {
+		t.Fatalf("ProcessChat() error = %v", err)
+	}
+
+	history := memoryStore.GetMessages()
+	if len(history) != 4 || history[1].Role != "assistant" || history[1].Content != "choice 0" {
+		t.Errorf("Expected the default candidate persisted before the next turn, got %+v", history)
+	}
+	if _, err := service.(CandidateSelector).SelectCandidate(context.Background(), first.SelectionID, 1); err == nil {
+		t.Error("Expected the selection to close when the next turn starts")
+	}
+}
+
+func TestChatService_SelectCandidate_AuditsStoredCandidate(t *testing.T) {
+	sink := &recordingSink{}
+	auditor := audit.NewAuditor(sink, nil, 10, zap.NewNop())
+	service := NewChatService(storage.NewMemoryStore(20), nil, &choicesClient{}, 1024, WithAuditor(auditor))
+
+	ask := func(prompt string) *ChatResponse {
+		t.Helper()
+		response, err := service.ProcessChat(context.Background(), &ChatRequest{
+			Messages: []storage.Message{{Role: "user", Content: prompt}},
+			N:        2,
+		})
+		if err != nil {
+			t.Fatalf("ProcessChat() error = %v", err)
+		}
+		return response
+	}
+
+	first := ask("Write a tagline")
+	if _, err := service.(CandidateSelector).SelectCandidate(context.Background(), first.SelectionID, 1); err != nil {
+		t.Fatalf("SelectCandidate() error = %v", err)
+	}
+	ask("Write another")
+	ask("And one more") // Settles the second turn with its default candidate
+	auditor.Close()
+
+	// The third turn still awaits a selection and is not audited yet
+	want := []string{"Write a tagline: choice 1", "Write another: choice 0"}
+	var got []string
+	for _, record := range sink.records {
+		got = append(got, record.Prompt+": "+record.Response)
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Audited %q, want %q", got, want)
+	}
+}
+
+func TestChatService_ProcessChatStream_Candidates(t *testing.T) {
+	mockClient := &mockGroqClient{
+		streamChatFunc: func(messages []llm.Message, opts llm.ChatOptions, onToken func(string) error) (*llm.Result, error) {
+			for _, token := range []string{"same ", "answer"} {
+				if err := onToken(token); err != nil {
+					return nil, err
+				}
+			}
+			return &llm.Result{Content: "same answer"}, nil
+		},
+	}
+	service := NewChatService(storage.NewMemoryStore(20), nil, mockClient, 1024)
+
+	var mu sync.Mutex
+	streamed := map[int]*strings.Builder{}
+	req := &ChatRequest{
+		Messages: []storage.Message{{Role: "user", Content: "hi"}},
+		Stream:   true,
+		N:        3,
+		OnCandidateToken: func(index int, token string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if streamed[index] == nil {
+				streamed[index] = &strings.Builder{}
+			}
+			streamed[index].WriteString(token)
+			return nil
+		},
+	}
+	if _, err := service.ProcessChatStream(context.Background(), req, func(string) error {
+		t.Error("Plain tokens must not be sent when candidate tokens are handled")
+		return nil
+	}); err != nil {
+		t.Fatalf("ProcessChatStream() error = %v", err)
+	}
+
+	if len(streamed) != 3 {
+		t.Fatalf("Streamed candidates = %d, want 3", len(streamed))
+	}
+	for index, text := range streamed {
+		if text.String() != "same answer" {
+			t.Errorf("Candidate %d streamed %q", index, text.String())
+		}
+	}
+}
+
+func TestChatService_ProcessChat_CandidateFailure(t *testing.T) {
+	var calls atomic.Int32
+	mockClient := &mockGroqClient{
+		chatFunc: func(messages []llm.Message, opts llm.ChatOptions) (*llm.Result, error) {
+			if calls.Add(1) == 2 {
+				return nil, errors.New("upstream failed")
+			}
+			return &llm.Result{Content: "ok"}, nil
+		},
+	}
+	memoryStore := storage.NewMemoryStore(20)
+	service := NewChatService(memoryStore, nil, mockClient, 1024)
+	if _, err := service.ProcessChat(context.Background(), &ChatRequest{
+		Messages: []storage.Message{{Role: "user", Content: "hi"}},
+		N:        3,
+	}); err == nil {
+		t.Fatal("Expected the failed candidate to fail the turn")
+	}
+	for _, msg := range memoryStore.GetMessages() {
+		if msg.Role == "assistant" {
+			t.Errorf("Expected no answer in history, got %+v", msg)
+		}
+	}
+}
+
+func TestChatService_Validate_Candidates(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     ChatRequest
+		wantErr bool
+	}{
+		{name: "default", req: ChatRequest{}},
+		{name: "n within limit", req: ChatRequest{N: 4, PersistIndex: intPtr(3)}},
+		{name: "n over limit", req: ChatRequest{N: 5}, wantErr: true},
+		{name: "negative n", req: ChatRequest{N: -1}, wantErr: true},
+		{name: "persist index out of range", req: ChatRequest{N: 2, PersistIndex: intPtr(2)}, wantErr: true},
+		{name: "persist index without n", req: ChatRequest{PersistIndex: intPtr(1)}, wantErr: true},
+	}
+
+	service := NewChatService(storage.NewMemoryStore(20), nil, &mockGroqClient{}, 1024).(*chatService)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.Messages = []storage.Message{{Role: "user", Content: "hi"}}
+			if err := service.validate(&tt.req); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
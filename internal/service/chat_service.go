@@ -1,10 +1,26 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"llm-chat-service/internal/audit"
+	apperror "llm-chat-service/internal/error"
 	"llm-chat-service/internal/llm"
+	"llm-chat-service/internal/logging"
+	"llm-chat-service/internal/moderation"
+	"llm-chat-service/internal/rag"
 	"llm-chat-service/internal/storage"
+	"llm-chat-service/internal/tracing"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
 )
 
 // chatService handles chat business logic
@@ -12,7 +28,22 @@ type chatService struct {
 	messageStore storage.MessageStore
 	cacheStore   storage.CacheStore // Can be nil if caching is not available
 	llmClient    llm.Client
-	maxTokens    int
+	limits       atomic.Pointer[Limits]
+	auditor      *audit.Auditor // Can be nil if auditing is disabled
+
+	tools *ToolRegistry // Can be nil if no server-side tools are registered
+
+	knowledge *rag.Service // Can be nil if retrieval-augmented generation is disabled
+
+	moderator *moderation.Moderator // Can be nil if content moderation is disabled
+	injection *InjectionDetector    // Can be nil if prompt-injection detection is disabled
+
+	tokenizer *Tokenizer
+
+	// pending is the last multi-candidate turn, until the client selects its answer or the next turn
+	// starts
+	selectionMu sync.Mutex
+	pending     *pendingSelection
 }
 
 // ------------------------------------------------------------------------------------------------------
@@ -21,62 +52,200 @@ func NewChatService(
 	cacheStore storage.CacheStore, // Can be nil
 	llmClient llm.Client,
 	maxTokens int,
+	opts ...Option,
 ) ChatService {
-	return &chatService{
+	s := &chatService{
 		messageStore: messageStore,
 		cacheStore:   cacheStore,
 		llmClient:    llmClient,
-		maxTokens:    maxTokens,
+		tokenizer:    NewTokenizer(),
+	}
+	s.limits.Store(&Limits{
+		MaxTokens:               maxTokens,
+		MaxToolIterations:       defaultMaxToolIterations,
+		StructuredOutputRetries: defaultStructuredOutputRetries,
+		MaxContinuationTokens:   defaultMaxContinuationTokens,
+		MaxCandidates:           defaultMaxCandidates,
+	})
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
 // ------------------------------------------------------------------------------------------------------
 type ChatRequest struct {
 	Messages []storage.Message `json:"messages"`
 	Stream   bool              `json:"stream"`
+
+	// Tools are declared by the client and executed client-side through ClientToolExecutor
+	Tools []llm.Tool `json:"tools,omitempty"`
+
+	// ClientToolExecutor is set by transports that can round-trip tool calls to the client
+	ClientToolExecutor ClientToolExecutor `json:"-"`
+
+	// ResponseFormat requests a JSON answer, optionally constrained by a JSON Schema
+	ResponseFormat *llm.ResponseFormat `json:"response_format,omitempty"`
+
+	// KnowledgeBase grounds the answer in the most relevant chunks of the named knowledge base
+	KnowledgeBase string `json:"knowledge_base,omitempty"`
+
+	// N asks for several candidate answers. PersistIndex picks the one stored in history up front;
+	// without it the response carries a selection ID to choose one once they are known.
+	N            int  `json:"n,omitempty"`
+	PersistIndex *int `json:"persist_index,omitempty"`
+
+	// OnCandidateToken is set by streaming transports to receive the tokens of every candidate when
+	// N > 1; without it only the persisted candidate is streamed
+	OnCandidateToken CandidateTokenFunc `json:"-"`
+
+	// responseSchema is the compiled ResponseFormat schema, set during validation
+	responseSchema *jsonschema.Schema
+}
+
+// ChatResponse is the assistant's answer to a chat turn
+type ChatResponse struct {
+	Content   string     `json:"response"`
+	Citations []Citation `json:"citations,omitempty"`
+	Warnings  []Warning  `json:"warnings,omitempty"`
+	// Candidates lists every answer when the request asked for n > 1; Content is the persisted one
+	Candidates []Candidate `json:"candidates,omitempty"`
+	ResponseMetadata
+}
+
+// ResponseMetadata describes how the answer was generated. A finish_reason of "length" means the
+// answer was cut off by max_tokens.
+type ResponseMetadata struct {
+	FinishReason      string     `json:"finish_reason,omitempty"`
+	Model             string     `json:"model,omitempty"`
+	Usage             *llm.Usage `json:"usage,omitempty"`
+	UpstreamRequestID string     `json:"upstream_request_id,omitempty"`
+	LatencyMs         int64      `json:"latency_ms"`
+
+	// SelectionID is the handle for choosing the candidate to persist, set when n > 1 and no
+	// persist_index was given
+	SelectionID string `json:"selection_id,omitempty"`
 }
 
+// ClientToolExecutor forwards a tool call to the client and returns the client's result
+type ClientToolExecutor func(ctx context.Context, call llm.ToolCall) (string, error)
+
 // ------------------------------------------------------------------------------------------------------
-func (s *chatService) ProcessChat(req *ChatRequest) (string, error) {
-	if err := req.Validate(); err != nil {
-		return "", err
+func (s *chatService) ProcessChat(ctx context.Context, req *ChatRequest) (_ *ChatResponse, err error) {
+	ctx, span := startServiceSpan(ctx, "ChatService.ProcessChat", req)
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	if err := s.validate(req); err != nil {
+		return nil, err
 	}
 
+	s.settleSelection()
 	history := s.messageStore.GetMessages()
+	logging.FromContext(ctx).Debug("Processing chat request", zap.Int("history_length", len(history)))
 
-	newUserMsg := req.Messages[len(req.Messages)-1]
+	newUserMsg, err := s.moderateInput(ctx, req.Messages[len(req.Messages)-1])
+	if err != nil {
+		return nil, err
+	}
+	warnings, err := s.inspectUserMessage(ctx, newUserMsg.Content)
+	if err != nil {
+		return nil, err
+	}
 	s.messageStore.AddMessage(newUserMsg)
 
 	llmMessages := append(history, newUserMsg)
 
-	groqMessages := make([]llm.Message, len(llmMessages))
-	for i, msg := range llmMessages {
-		groqMessages[i] = llm.Message{
-			Role:    msg.Role,
-			Content: msg.Content,
-		}
-	}
+	groqMessages := toLLMMessages(llmMessages)
 
 	// Check cache for token count
-	if s.cacheStore != nil {
+	s.cacheTokenCount(ctx, llmMessages)
 
-		cachedCount, found, err := s.cacheStore.GetTokenCount(llmMessages)
-		if err == nil && found {
-			_ = cachedCount
-		} else if err == nil {
-
-			tokenCount, err := s.cacheStore.CountTokens(llmMessages)
-			if err == nil {
-				_ = s.cacheStore.SetTokenCount(llmMessages, tokenCount, 24*time.Hour)
-			}
-		}
+	groqMessages, citations, retrievalWarnings, err := s.retrieveKnowledge(ctx, req, newUserMsg.Content, groqMessages)
+	if err != nil {
+		return nil, err
 	}
+	warnings = append(warnings, retrievalWarnings...)
 
 	// Call LLM API
-	response, err := s.llmClient.Chat(groqMessages, s.maxTokens)
+	start := time.Now()
+	results, toolTurns, err := s.answerCandidates(ctx, req, groqMessages, nil)
+	return s.finishTurn(ctx, req, newUserMsg, results, toolTurns, err, citations, warnings, start)
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (s *chatService) ProcessChatStream(ctx context.Context, req *ChatRequest, onToken func(string) error) (_ *ChatResponse, err error) {
+	ctx, span := startServiceSpan(ctx, "ChatService.ProcessChatStream", req)
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	if err := s.validate(req); err != nil {
+		return nil, err
+	}
+
+	s.settleSelection()
+	history := s.messageStore.GetMessages()
+	logging.FromContext(ctx).Debug("Processing chat stream request", zap.Int("history_length", len(history)))
+
+	// Add new user message to history
+	newUserMsg, err := s.moderateInput(ctx, req.Messages[len(req.Messages)-1])
+	if err != nil {
+		return nil, err
+	}
+	warnings, err := s.inspectUserMessage(ctx, newUserMsg.Content)
+	if err != nil {
+		return nil, err
+	}
+	s.messageStore.AddMessage(newUserMsg)
+
+	// Prepare messages for LLM
+	llmMessages := append(history, newUserMsg)
+
+	// Convert to LLM message format
+	groqMessages := toLLMMessages(llmMessages)
+
+	// Check cache for token count
+	s.cacheTokenCount(ctx, llmMessages)
+
+	groqMessages, citations, retrievalWarnings, err := s.retrieveKnowledge(ctx, req, newUserMsg.Content, groqMessages)
 	if err != nil {
-		return "", err // Already wrapped with AppError from LLM client
+		return nil, err
 	}
+	warnings = append(warnings, retrievalWarnings...)
+
+	// Stream from LLM API
+	start := time.Now()
+	results, toolTurns, err := s.answerCandidates(ctx, req, groqMessages, req.candidateTokenFunc(onToken))
+	return s.finishTurn(ctx, req, newUserMsg, results, toolTurns, err, citations, warnings, start)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// finishTurn audits the turn and, when it succeeded, stores the tool-call turns and the chosen answer in
+// the history, or holds the candidates until the client selects one. Nothing but the user message is
+// stored for a failed turn.
+func (s *chatService) finishTurn(ctx context.Context, req *ChatRequest, userMsg storage.Message, results []*llm.Result, toolTurns []llm.Message, err error, citations []Citation, warnings []Warning, start time.Time) (*ChatResponse, error) {
+	if err != nil {
+		s.recordAudit(ctx, req, userMsg.Content, "", err, start)
+		return nil, err // Already wrapped with AppError from LLM client
+	}
+	response := results[req.persistIndex()].Content
+
+	for _, msg := range toolTurns {
+		s.messageStore.AddMessage(fromLLMMessage(msg))
+	}
+
+	chatResponse := newChatResponse(req, results, citations, warnings, start)
+	if req.awaitsSelection() {
+		// The turn is audited with the candidate that ends up in the history
+		record := newAuditRecord(ctx, req, userMsg.Content, "", nil, start)
+		chatResponse.SelectionID = s.holdSelection(chatResponse.Candidates, record)
+		return chatResponse, nil
+	}
+	s.recordAudit(ctx, req, userMsg.Content, response, nil, start)
 
 	// Add assistant response to history
 	assistantMsg := storage.Message{
@@ -85,58 +254,218 @@ func (s *chatService) ProcessChat(req *ChatRequest) (string, error) {
 	}
 	s.messageStore.AddMessage(assistantMsg)
 
-	return response, nil
+	return chatResponse, nil
 }
 
 // ------------------------------------------------------------------------------------------------------
-func (s *chatService) ProcessChatStream(req *ChatRequest, onToken func(string) error) (string, error) {
+// validate checks the request itself and its compatibility with the service configuration
+func (s *chatService) validate(req *ChatRequest) error {
 	if err := req.Validate(); err != nil {
-		return "", err
+		return err
 	}
 
-	history := s.messageStore.GetMessages()
+	for _, tool := range req.Tools {
+		if s.tools.Has(tool.Function.Name) {
+			return apperror.NewValidationError(
+				fmt.Sprintf("tool name '%s' is reserved by a server-side tool", tool.Function.Name),
+				nil,
+			)
+		}
+	}
 
-	// Add new user message to history
-	newUserMsg := req.Messages[len(req.Messages)-1]
-	s.messageStore.AddMessage(newUserMsg)
+	for _, msg := range req.Messages {
+		if msg.HasImages() && !s.supportsImages() {
+			return apperror.NewValidationError("the configured model does not accept images", nil)
+		}
+	}
 
-	// Prepare messages for LLM
-	llmMessages := append(history, newUserMsg)
+	if req.KnowledgeBase != "" && s.knowledge == nil {
+		return apperror.NewValidationError("knowledge_base is not enabled on this server", nil)
+	}
 
-	// Convert to LLM message format
-	groqMessages := make([]llm.Message, len(llmMessages))
-	for i, msg := range llmMessages {
-		groqMessages[i] = llm.Message{
-			Role:    msg.Role,
-			Content: msg.Content,
-		}
+	if maxCandidates := s.limits.Load().MaxCandidates; req.N > maxCandidates {
+		return apperror.NewValidationError(
+			fmt.Sprintf("n must be at most %d, got %d", maxCandidates, req.N),
+			nil,
+		)
 	}
 
-	// Check cache for token count
-	if s.cacheStore != nil {
-		cachedCount, found, err := s.cacheStore.GetTokenCount(llmMessages)
-		if err == nil && found {
-			_ = cachedCount
-		} else if err == nil {
-			tokenCount, err := s.cacheStore.CountTokens(llmMessages)
-			if err == nil {
-				_ = s.cacheStore.SetTokenCount(llmMessages, tokenCount, 24*time.Hour)
+	schema, err := compileResponseSchema(req.ResponseFormat)
+	if err != nil {
+		return err
+	}
+	req.responseSchema = schema
+
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (s *chatService) supportsImages() bool {
+	supporter, ok := s.llmClient.(llm.VisionSupporter)
+	return ok && supporter.SupportsImages()
+}
+
+// ------------------------------------------------------------------------------------------------------
+// generate produces the final answer for a turn and the tool-call turns that led to it, enforcing the
+// request's response format when set. endMessage, when set, is called after each streamed message that
+// ended in tool calls.
+func (s *chatService) generate(ctx context.Context, req *ChatRequest, messages []llm.Message, onToken func(string) error, endMessage func() error) (*llm.Result, []llm.Message, error) {
+	if req.ResponseFormat != nil {
+		return s.generateStructured(ctx, req, messages, onToken)
+	}
+	return s.runToolLoop(ctx, req, messages, nil, onToken, endMessage)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// runToolLoop calls the LLM, executing requested tool calls and looping back to the model until it
+// produces a final answer. It returns the intermediate assistant and tool messages with the answer,
+// for the caller to persist once the whole turn has succeeded. A nil onToken selects the non-streaming
+// API. The returned result carries the usage of every call.
+func (s *chatService) runToolLoop(ctx context.Context, req *ChatRequest, messages []llm.Message, format *llm.ResponseFormat, onToken func(string) error, endMessage func() error) (*llm.Result, []llm.Message, error) {
+	limits := s.limits.Load()
+	opts := llm.ChatOptions{
+		MaxTokens:      limits.MaxTokens,
+		ResponseFormat: format,
+	}
+	// Candidates are generated concurrently and must not interleave tool turns in the history
+	if req.candidates() == 1 {
+		opts.Tools = append(s.tools.Definitions(), req.Tools...)
+	}
+
+	var usage *llm.Usage
+	var toolTurns []llm.Message
+	for iteration := 0; ; iteration++ {
+		var result *llm.Result
+		var err error
+		if onToken != nil {
+			result, err = s.llmClient.StreamChat(ctx, messages, opts, onToken)
+		} else {
+			result, err = s.llmClient.Chat(ctx, messages, opts)
+		}
+		if err != nil {
+			return nil, nil, err // Already wrapped with AppError from LLM client
+		}
+
+		if len(result.ToolCalls) == 0 {
+			result, err = s.autoContinue(ctx, messages, opts, result, onToken)
+			if err != nil {
+				return nil, nil, err
+			}
+			result.Usage = addUsage(usage, result.Usage)
+			return result, toolTurns, nil
+		}
+		usage = addUsage(usage, result.Usage)
+
+		if iteration >= limits.MaxToolIterations {
+			return nil, nil, apperror.NewLLMError(
+				"model did not produce a final answer within the tool call limit",
+				fmt.Errorf("exceeded %d tool iterations", limits.MaxToolIterations),
+			)
+		}
+
+		// Text streamed with the tool calls belongs to their message, not to the answer
+		if endMessage != nil {
+			if err := endMessage(); err != nil {
+				return nil, nil, err
 			}
 		}
+
+		assistantMsg := llm.Message{
+			Role:      "assistant",
+			Content:   result.Content,
+			ToolCalls: result.ToolCalls,
+		}
+		messages = append(messages, assistantMsg)
+		toolTurns = append(toolTurns, assistantMsg)
+
+		for _, call := range result.ToolCalls {
+			output, err := s.executeTool(ctx, req, call)
+			if err != nil {
+				return nil, nil, err
+			}
+			toolMsg := llm.Message{
+				Role:       "tool",
+				Name:       call.Function.Name,
+				ToolCallID: call.ID,
+				Content:    output,
+			}
+			messages = append(messages, toolMsg)
+			toolTurns = append(toolTurns, toolMsg)
+		}
 	}
+}
 
-	// Stream from LLM API
-	response, err := s.llmClient.StreamChat(groqMessages, s.maxTokens, onToken)
+// ------------------------------------------------------------------------------------------------------
+// executeTool runs a tool call on the server, or on the client when the client declared the tool.
+// Failures are returned to the model as content rather than aborting the turn, except for a client
+// that stopped answering, which ends it.
+func (s *chatService) executeTool(ctx context.Context, req *ChatRequest, call llm.ToolCall) (string, error) {
+	logger := logging.FromContext(ctx).With(zap.String("tool", call.Function.Name))
+
+	var output string
+	var err error
+	if req.hasClientTool(call.Function.Name) {
+		output, err = executeClientTool(ctx, req.ClientToolExecutor, call)
+	} else {
+		output, err = s.tools.Execute(ctx, call)
+	}
+	if errors.Is(err, ErrClientToolTimeout) {
+		return "", apperror.NewTimeoutError(
+			fmt.Sprintf("client did not return a result for tool '%s' in time", call.Function.Name),
+			err,
+		)
+	}
+	if errors.Is(err, ErrClientToolUnavailable) {
+		return "", apperror.NewUnavailableError(
+			fmt.Sprintf("client is no longer available to run tool '%s'", call.Function.Name),
+			err,
+		)
+	}
 	if err != nil {
-		return "", err // Already wrapped with AppError from LLM client
+		logger.Warn("Tool execution failed", zap.Error(err))
+		return toolErrorContent(err), nil
 	}
 
-	// Add assistant response to history
-	assistantMsg := storage.Message{
-		Role:    "assistant",
-		Content: response,
+	logger.Debug("Tool executed", zap.Int("output_length", len(output)))
+	return output, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// cacheTokenCount makes sure the token count of messages is cached. Cache failures are logged, never returned.
+func (s *chatService) cacheTokenCount(ctx context.Context, messages []storage.Message) {
+	if s.cacheStore == nil {
+		return
 	}
-	s.messageStore.AddMessage(assistantMsg)
 
-	return response, nil
+	logger := logging.FromContext(ctx)
+
+	model := s.model()
+	encoding := string(EncodingForModel(model))
+
+	cachedCount, found, err := s.cacheStore.GetTokenCount(ctx, encoding, messages)
+	if err != nil {
+		logger.Warn("Token count cache lookup failed", zap.Error(err))
+		return
+	}
+	if found {
+		logger.Debug("Token count cache hit", zap.Int("tokens", cachedCount))
+		return
+	}
+
+	tokenCount, err := s.tokenizer.Count(ctx, model, messages)
+	if err != nil {
+		logger.Warn("Failed to count tokens", zap.Error(err))
+		return
+	}
+	if err := s.cacheStore.SetTokenCount(ctx, encoding, messages, tokenCount.PromptTokens, 24*time.Hour); err != nil {
+		logger.Warn("Failed to cache token count", zap.Error(err))
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func startServiceSpan(ctx context.Context, name string, req *ChatRequest) (context.Context, trace.Span) {
+	return tracing.StartSpan(ctx, name, trace.WithAttributes(
+		attribute.Int("chat.messages.count", len(req.Messages)),
+		attribute.Bool("chat.stream", req.Stream),
+	))
 }
@@ -1,28 +1,34 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"llm-chat-service/internal/llm"
 	"llm-chat-service/internal/storage"
 	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 // Mock GroqClient for testing
 type mockGroqClient struct {
-	chatFunc       func([]llm.Message, int) (string, error)
-	streamChatFunc func([]llm.Message, int, func(string) error) (string, error)
+	chatFunc       func([]llm.Message, llm.ChatOptions) (*llm.Result, error)
+	streamChatFunc func([]llm.Message, llm.ChatOptions, func(string) error) (*llm.Result, error)
 }
 
-func (m *mockGroqClient) Chat(messages []llm.Message, maxTokens int) (string, error) {
+func (m *mockGroqClient) Chat(ctx context.Context, messages []llm.Message, opts llm.ChatOptions) (*llm.Result, error) {
 	if m.chatFunc != nil {
-		return m.chatFunc(messages, maxTokens)
+		return m.chatFunc(messages, opts)
 	}
-	return "mock response", nil
+	return &llm.Result{Content: "mock response"}, nil
 }
 
-func (m *mockGroqClient) StreamChat(messages []llm.Message, maxTokens int, onToken func(string) error) (string, error) {
+func (m *mockGroqClient) StreamChat(ctx context.Context, messages []llm.Message, opts llm.ChatOptions, onToken func(string) error) (*llm.Result, error) {
 	if m.streamChatFunc != nil {
-		return m.streamChatFunc(messages, maxTokens, onToken)
+		return m.streamChatFunc(messages, opts, onToken)
 	}
 	// Default behavior: call onToken with response
 	if onToken != nil {
@@ -30,7 +36,7 @@ func (m *mockGroqClient) StreamChat(messages []llm.Message, maxTokens int, onTok
 		_ = onToken(" stream")
 		_ = onToken(" response")
 	}
-	return "mock stream response", nil
+	return &llm.Result{Content: "mock stream response"}, nil
 }
 
 func TestChatRequest_Validate(t *testing.T) {
@@ -97,8 +103,8 @@ func TestChatRequest_Validate(t *testing.T) {
 func TestChatService_ProcessChat(t *testing.T) {
 	memoryStore := storage.NewMemoryStore(20)
 	mockClient := &mockGroqClient{
-		chatFunc: func(messages []llm.Message, maxTokens int) (string, error) {
-			return "test response", nil
+		chatFunc: func(messages []llm.Message, opts llm.ChatOptions) (*llm.Result, error) {
+			return &llm.Result{Content: "test response"}, nil
 		},
 	}
 
@@ -110,12 +116,12 @@ func TestChatService_ProcessChat(t *testing.T) {
 		},
 	}
 
-	response, err := service.ProcessChat(req)
+	response, err := service.ProcessChat(context.Background(), req)
 	if err != nil {
 		t.Errorf("ProcessChat() error = %v", err)
 	}
-	if response != "test response" {
-		t.Errorf("ProcessChat() response = %v, want 'test response'", response)
+	if response.Content != "test response" {
+		t.Errorf("ProcessChat() response = %v, want 'test response'", response.Content)
 	}
 
 	// Check that message was added to history
@@ -128,8 +134,8 @@ func TestChatService_ProcessChat(t *testing.T) {
 func TestChatService_ProcessChat_Error(t *testing.T) {
 	memoryStore := storage.NewMemoryStore(20)
 	mockClient := &mockGroqClient{
-		chatFunc: func(messages []llm.Message, maxTokens int) (string, error) {
-			return "", errors.New("API error")
+		chatFunc: func(messages []llm.Message, opts llm.ChatOptions) (*llm.Result, error) {
+			return nil, errors.New("API error")
 		},
 	}
 
@@ -141,7 +147,7 @@ func TestChatService_ProcessChat_Error(t *testing.T) {
 		},
 	}
 
-	_, err := service.ProcessChat(req)
+	_, err := service.ProcessChat(context.Background(), req)
 	if err == nil {
 		t.Error("ProcessChat() expected error, got nil")
 	}
@@ -152,11 +158,11 @@ func TestChatService_ProcessChatStream(t *testing.T) {
 	tokens := []string{}
 
 	mockClient := &mockGroqClient{
-		streamChatFunc: func(messages []llm.Message, maxTokens int, onToken func(string) error) (string, error) {
+		streamChatFunc: func(messages []llm.Message, opts llm.ChatOptions, onToken func(string) error) (*llm.Result, error) {
 			// Simulate streaming tokens
 			onToken("Hello")
 			onToken(" World")
-			return "Hello World", nil
+			return &llm.Result{Content: "Hello World"}, nil
 		},
 	}
 
@@ -169,7 +175,7 @@ func TestChatService_ProcessChatStream(t *testing.T) {
 		Stream: true,
 	}
 
-	response, err := service.ProcessChatStream(req, func(token string) error {
+	response, err := service.ProcessChatStream(context.Background(), req, func(token string) error {
 		tokens = append(tokens, token)
 		return nil
 	})
@@ -177,10 +183,34 @@ func TestChatService_ProcessChatStream(t *testing.T) {
 	if err != nil {
 		t.Errorf("ProcessChatStream() error = %v", err)
 	}
-	if response != "Hello World" {
-		t.Errorf("ProcessChatStream() response = %v, want 'Hello World'", response)
+	if response.Content != "Hello World" {
+		t.Errorf("ProcessChatStream() response = %v, want 'Hello World'", response.Content)
 	}
 	if len(tokens) != 2 {
 		t.Errorf("Expected 2 tokens, got %d", len(tokens))
 	}
 }
+
+func TestChatService_ProcessChat_Spans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	service := NewChatService(storage.NewMemoryStore(20), nil, &mockGroqClient{}, 1024)
+
+	req := &ChatRequest{
+		Messages: []storage.Message{
+			{Role: "user", Content: "Hello"},
+		},
+	}
+
+	if _, err := service.ProcessChat(context.Background(), req); err != nil {
+		t.Fatalf("ProcessChat() error = %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Name() != "ChatService.ProcessChat" {
+		t.Fatalf("Expected a single ChatService.ProcessChat span, got %d spans", len(spans))
+	}
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"llm-chat-service/internal/llm"
+	"llm-chat-service/internal/logging"
+	"llm-chat-service/internal/metrics"
+
+	"go.uber.org/zap"
+)
+
+// continuePrompt asks the model to pick up a truncated answer
+const continuePrompt = "Your previous answer was cut off. Continue it exactly where it stopped, " +
+	"without repeating any of it and without any preamble."
+
+// Bounds of the text a continuation may repeat from the end of the answer so far. Repeats shorter
+// than minContinuationOverlap are kept, since they are as likely to be a legitimate continuation.
+const (
+	minContinuationOverlap = 10
+	maxContinuationOverlap = 200
+)
+
+// defaultMaxContinuationTokens caps the completion tokens of an auto-continued answer when not configured
+const defaultMaxContinuationTokens = 4096
+
+// ------------------------------------------------------------------------------------------------------
+// autoContinue asks the model to continue an answer that stopped at max_tokens, until it finishes or
+// the continuation or token cap is reached. Continuations are streamed to onToken as part of the same
+// answer. The returned result carries the usage of every call.
+func (s *chatService) autoContinue(ctx context.Context, messages []llm.Message, opts llm.ChatOptions, result *llm.Result, onToken func(string) error) (*llm.Result, error) {
+	limits := s.limits.Load()
+	if limits.MaxContinuations == 0 || result.FinishReason != llm.FinishReasonLength {
+		return result, nil
+	}
+
+	// Continuations finish the text; they must not start calling tools
+	opts.ToolChoice = nil
+	if len(opts.Tools) > 0 {
+		opts.ToolChoice = "none"
+	}
+
+	spent := completionTokens(result)
+	continuations := 0
+	for continuations < limits.MaxContinuations && result.FinishReason == llm.FinishReasonLength {
+		remaining := limits.MaxContinuationTokens - spent
+		if remaining <= 0 {
+			break
+		}
+		opts.MaxTokens = min(limits.MaxTokens, remaining)
+
+		followUp := append(messages[:len(messages):len(messages)],
+			llm.Message{Role: "assistant", Content: result.Content},
+			llm.Message{Role: "user", Content: continuePrompt},
+		)
+
+		var next *llm.Result
+		var addition string
+		var err error
+		if onToken != nil {
+			stitcher := &continuationStitcher{previous: result.Content, onToken: onToken}
+			next, err = s.llmClient.StreamChat(ctx, followUp, opts, stitcher.write)
+			if err == nil {
+				err = stitcher.flush()
+			}
+			addition = stitcher.emitted.String()
+		} else {
+			next, err = s.llmClient.Chat(ctx, followUp, opts)
+			if err == nil {
+				addition = trimOverlap(result.Content, next.Content)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		continuations++
+		spent += completionTokens(next)
+		result = &llm.Result{
+			Content:      result.Content + addition,
+			FinishReason: next.FinishReason,
+			Model:        next.Model,
+			Usage:        addUsage(result.Usage, next.Usage),
+			RequestID:    next.RequestID,
+		}
+	}
+
+	outcome := "completed"
+	if result.FinishReason == llm.FinishReasonLength {
+		outcome = "capped"
+	}
+	metrics.ChatContinuationsTotal.WithLabelValues(outcome).Inc()
+	logging.FromContext(ctx).Debug("Continued truncated answer",
+		zap.Int("continuations", continuations),
+		zap.Int("completion_tokens", spent),
+		zap.String("outcome", outcome),
+	)
+	return result, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// completionTokens returns the reported completion tokens, or an estimate of about four characters
+// per token when the provider does not report usage
+func completionTokens(result *llm.Result) int {
+	if result.Usage != nil {
+		return result.Usage.CompletionTokens
+	}
+	return (len(result.Content) + 3) / 4
+}
+
+// ------------------------------------------------------------------------------------------------------
+// trimOverlap drops the start of next when it repeats the end of previous
+func trimOverlap(previous, next string) string {
+	for k := min(maxContinuationOverlap, len(previous), len(next)); k >= minContinuationOverlap; k-- {
+		if strings.HasSuffix(previous, next[:k]) {
+			return next[k:]
+		}
+	}
+	return next
+}
+
+// continuationStitcher holds back the start of a streamed continuation until any text it repeats
+// from the answer so far can be trimmed, then passes tokens straight through
+type continuationStitcher struct {
+	previous string
+	onToken  func(string) error
+	pending  strings.Builder
+	emitted  strings.Builder
+	released bool
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (c *continuationStitcher) write(token string) error {
+	if c.released {
+		c.emitted.WriteString(token)
+		return c.onToken(token)
+	}
+	c.pending.WriteString(token)
+	if c.pending.Len() < maxContinuationOverlap {
+		return nil
+	}
+	return c.flush()
+}
+
+// ------------------------------------------------------------------------------------------------------
+// flush releases the held-back start of the continuation
+func (c *continuationStitcher) flush() error {
+	if c.released {
+		return nil
+	}
+	c.released = true
+	text := trimOverlap(c.previous, c.pending.String())
+	if text == "" {
+		return nil
+	}
+	c.emitted.WriteString(text)
+	return c.onToken(text)
+}
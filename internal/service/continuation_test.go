@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"llm-chat-service/internal/llm"
+	"llm-chat-service/internal/storage"
+	"strings"
+	"testing"
+)
+
+func TestTrimOverlap(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous string
+		next     string
+		want     string
+	}{
+		{name: "no overlap", previous: "The quick brown fox", next: " jumps over", want: " jumps over"},
+		{name: "repeated tail", previous: "The quick brown fox jumps", next: "brown fox jumps over the dog", want: " over the dog"},
+		{name: "short repeat kept", previous: "one two", next: "two three", want: "two three"},
+		{name: "empty next", previous: "anything", next: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trimOverlap(tt.previous, tt.next); got != tt.want {
+				t.Errorf("trimOverlap() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChatService_ProcessChat_AutoContinue(t *testing.T) {
+	parts := []string{"The first part of a long answer", " and the second part", " and the end."}
+
+	tests := []struct {
+		name             string
+		maxContinuations int
+		maxTokens        int
+		want             string
+		wantFinish       string
+		wantCalls        int
+	}{
+		{name: "disabled", maxContinuations: 0, want: parts[0], wantFinish: llm.FinishReasonLength, wantCalls: 1},
+		{name: "completes", maxContinuations: 3, want: strings.Join(parts, ""), wantFinish: llm.FinishReasonStop, wantCalls: 3},
+		{name: "continuation cap", maxContinuations: 1, want: parts[0] + parts[1], wantFinish: llm.FinishReasonLength, wantCalls: 2},
+		{name: "token cap", maxContinuations: 3, maxTokens: 15, want: parts[0] + parts[1], wantFinish: llm.FinishReasonLength, wantCalls: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			mockClient := &mockGroqClient{
+				chatFunc: func(messages []llm.Message, opts llm.ChatOptions) (*llm.Result, error) {
+					if calls > 0 {
+						last := messages[len(messages)-1]
+						previous := messages[len(messages)-2]
+						if last.Content != continuePrompt || previous.Role != "assistant" || previous.Content != strings.Join(parts[:calls], "") {
+							t.Errorf("Expected the answer so far and a continue prompt, got %+v", messages[len(messages)-2:])
+						}
+					}
+					finish := llm.FinishReasonLength
+					if calls == len(parts)-1 {
+						finish = llm.FinishReasonStop
+					}
+					result := &llm.Result{
+						Content:      parts[calls],
+						FinishReason: finish,
+						Usage:        &llm.Usage{CompletionTokens: 10},
+					}
+					calls++
+					return result, nil
+				},
+			}
+
+			service := NewChatService(storage.NewMemoryStore(20), nil, mockClient, 1024, WithAutoContinue(tt.maxContinuations, tt.maxTokens))
+			response, err := service.ProcessChat(context.Background(), &ChatRequest{
+				Messages: []storage.Message{{Role: "user", Content: "Tell me everything"}},
+			})
+			if err != nil {
+				t.Fatalf("ProcessChat() error = %v", err)
+			}
+			if response.Content != tt.want {
+				t.Errorf("Content = %q, want %q", response.Content, tt.want)
+			}
+			if response.FinishReason != tt.wantFinish {
+				t.Errorf("FinishReason = %q, want %q", response.FinishReason, tt.wantFinish)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("Upstream calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if response.Usage.CompletionTokens != 10*tt.wantCalls {
+				t.Errorf("CompletionTokens = %d, want %d", response.Usage.CompletionTokens, 10*tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestChatService_ProcessChatStream_AutoContinueStitches(t *testing.T) {
+	calls := 0
+	mockClient := &mockGroqClient{
+		streamChatFunc: func(messages []llm.Message, opts llm.ChatOptions, onToken func(string) error) (*llm.Result, error) {
+			calls++
+			tokens := []string{"Rivers flow ", "into the sea ", "and the sea"}
+			finish := llm.FinishReasonLength
+			if calls == 2 {
+				// The model repeats the end of the answer before continuing
+				tokens = []string{"into the sea and ", "the sea never fills."}
+				finish = llm.FinishReasonStop
+			}
+			for _, token := range tokens {
+				if err := onToken(token); err != nil {
+					return nil, err
+				}
+			}
+			return &llm.Result{Content: strings.Join(tokens, ""), FinishReason: finish}, nil
+		},
+	}
+
+	service := NewChatService(storage.NewMemoryStore(20), nil, mockClient, 1024, WithAutoContinue(2, 0))
+
+	var streamed strings.Builder
+	response, err := service.ProcessChatStream(context.Background(), &ChatRequest{
+		Messages: []storage.Message{{Role: "user", Content: "Tell me about rivers"}},
+		Stream:   true,
+	}, func(token string) error {
+		streamed.WriteString(token)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ProcessChatStream() error = %v", err)
+	}
+
+	want := "Rivers flow into the sea and the sea never fills."
+	if streamed.String() != want {
+		t.Errorf("Streamed %q, want %q", streamed.String(), want)
+	}
+	if response.Content != want {
+		t.Errorf("Content = %q, want %q", response.Content, want)
+	}
+}
@@ -0,0 +1,26 @@
+package service
+
+import (
+	"context"
+
+	"llm-chat-service/internal/llm"
+	"llm-chat-service/internal/storage"
+)
+
+// ------------------------------------------------------------------------------------------------------
+// PingStore checks the conversation store, if it supports checks
+func (s *chatService) PingStore(ctx context.Context) error {
+	if pinger, ok := s.messageStore.(storage.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// PingLLM checks the LLM provider, if the client supports checks
+func (s *chatService) PingLLM(ctx context.Context) error {
+	if pinger, ok := s.llmClient.(llm.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
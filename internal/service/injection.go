@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+
+	apperror "llm-chat-service/internal/error"
+	"llm-chat-service/internal/logging"
+	"llm-chat-service/internal/metrics"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+// InjectionAction is what happens when content looks like a prompt injection
+type InjectionAction string
+
+const (
+	InjectionActionLog    InjectionAction = "log"    // Log and count only
+	InjectionActionWarn   InjectionAction = "warn"   // Also report it in the response warnings
+	InjectionActionReject InjectionAction = "reject" // Refuse the message, or leave out the retrieved excerpt
+)
+
+// Sources of inspected content
+const (
+	injectionSourceUser      = "user"
+	injectionSourceRetrieved = "retrieved"
+)
+
+// defaultInjectionThreshold is the score from which content is treated as an injection attempt
+const defaultInjectionThreshold = 0.5
+
+// Warning reports something suspicious about the chat turn that did not stop it
+type Warning struct {
+	Type    string   `json:"type"`
+	Source  string   `json:"source"`
+	Score   float64  `json:"score"`
+	Signals []string `json:"signals"`
+	// DocumentID identifies the excerpt for warnings about retrieved content
+	DocumentID string `json:"document_id,omitempty"`
+}
+
+// WarningTypePromptInjection marks warnings raised by the injection detector
+const WarningTypePromptInjection = "prompt_injection"
+
+type injectionSignal struct {
+	name     string
+	weight   float64
+	patterns []*regexp.Regexp
+}
+
+// injectionSignals are the heuristics the detector scores. Weights are the chance a single signal on
+// its own indicates an attack; signals found together compound.
+var injectionSignals = []injectionSignal{
+	{
+		name:   "instruction_override",
+		weight: 0.6,
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)\b(ignore|disregard|forget|override|bypass)\b[^.\n]{0,40}\b(previous|prior|above|earlier|preceding|all|your|the|system)\b[^.\n]{0,20}\b(instructions?|prompts?|rules|directions|guidelines|context)\b`),
+			regexp.MustCompile(`(?i)\b(new|updated|real) (system )?instructions\s*:`),
+		},
+	},
+	{
+		name:   "prompt_leak",
+		weight: 0.5,
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)\b(reveal|print|show|repeat|output|leak)\b[^.\n]{0,30}\b(system prompt|initial instructions|hidden instructions|original instructions)\b`),
+		},
+	},
+	{
+		name:   "role_play_escape",
+		weight: 0.5,
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`(?i)\b(you are now|from now on,? you|pretend (to be|you are)|act as|role-?play as)\b[^.\n]{0,60}\b(unrestricted|unfiltered|uncensored|jailbroken|without (any )?(restrictions|limits|rules|filters)|DAN)\b`),
+			regexp.MustCompile(`(?i)\b(developer|god|jailbreak|DAN) mode\b`),
+			regexp.MustCompile(`(?i)\bdo anything now\b`),
+		},
+	},
+	{
+		name:   "role_marker",
+		weight: 0.4,
+		patterns: []*regexp.Regexp{
+			regexp.MustCompile(`(?im)^\s*#*\s*(system|assistant)\s*:`),
+			regexp.MustCompile(`<\|im_(start|end)\|>|\[/?INST\]|<</?SYS>>|<\|(system|assistant|user)\|>`),
+		},
+	},
+}
+
+var (
+	base64Run    = regexp.MustCompile(`[A-Za-z0-9+/]{32,}={0,2}`)
+	hexEscapeRun = regexp.MustCompile(`(?i)(\\x[0-9a-f]{2}){8,}`)
+
+	// Zero-width characters and Unicode tags can smuggle instructions past a human reviewer
+	hiddenChars = &unicode.RangeTable{
+		R16: []unicode.Range16{
+			{Lo: 0x200b, Hi: 0x200f, Stride: 1},
+			{Lo: 0x2060, Hi: 0x2064, Stride: 1},
+			{Lo: 0xfeff, Hi: 0xfeff, Stride: 1},
+		},
+		R32: []unicode.Range32{
+			{Lo: 0xe0000, Hi: 0xe007f, Stride: 1},
+		},
+	}
+)
+
+// InjectionDetector scores text for prompt-injection and jailbreak patterns
+type InjectionDetector struct {
+	action    InjectionAction
+	threshold float64
+}
+
+// ------------------------------------------------------------------------------------------------------
+// ParseInjectionAction validates an action name
+func ParseInjectionAction(name string) (InjectionAction, error) {
+	switch action := InjectionAction(name); action {
+	case InjectionActionLog, InjectionActionWarn, InjectionActionReject:
+		return action, nil
+	default:
+		return "", fmt.Errorf("unknown prompt injection action %q: must be log, warn or reject", name)
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewInjectionDetector creates a detector acting on content scoring at least threshold (0 to 1)
+func NewInjectionDetector(action InjectionAction, threshold float64) *InjectionDetector {
+	if threshold <= 0 || threshold > 1 {
+		threshold = defaultInjectionThreshold
+	}
+	return &InjectionDetector{action: action, threshold: threshold}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Score rates text between 0 (benign) and 1 and names the signals found
+func (d *InjectionDetector) Score(text string) (float64, []string) {
+	signals := matchInjectionSignals(text)
+
+	if payload := decodedPayload(text); payload != "" {
+		signals = append(signals, "encoded_payload")
+		// An encoded instruction is worse than a plain one, so its signals count too
+		for _, signal := range matchInjectionSignals(payload) {
+			if !containsString(signals, signal) {
+				signals = append(signals, signal)
+			}
+		}
+	}
+	if strings.IndexFunc(text, func(r rune) bool { return unicode.Is(hiddenChars, r) }) >= 0 {
+		signals = append(signals, "hidden_characters")
+	}
+
+	benign := 1.0
+	for _, signal := range signals {
+		benign *= 1 - injectionSignalWeight(signal)
+	}
+	return math.Round((1-benign)*100) / 100, signals
+}
+
+// ------------------------------------------------------------------------------------------------------
+func matchInjectionSignals(text string) []string {
+	var signals []string
+	for _, signal := range injectionSignals {
+		for _, pattern := range signal.patterns {
+			if pattern.MatchString(text) {
+				signals = append(signals, signal.name)
+				break
+			}
+		}
+	}
+	return signals
+}
+
+// ------------------------------------------------------------------------------------------------------
+func injectionSignalWeight(name string) float64 {
+	for _, signal := range injectionSignals {
+		if signal.name == name {
+			return signal.weight
+		}
+	}
+	// encoded_payload and hidden_characters only hint at an attempt to get past filters
+	return 0.3
+}
+
+// ------------------------------------------------------------------------------------------------------
+// decodedPayload returns the readable text hidden in base64 runs or hex escapes, if any
+func decodedPayload(text string) string {
+	var decoded []string
+	for _, run := range base64Run.FindAllString(text, -1) {
+		data, err := base64.StdEncoding.DecodeString(run)
+		if err != nil {
+			data, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(run, "="))
+		}
+		if err == nil && isReadable(data) {
+			decoded = append(decoded, string(data))
+		}
+	}
+	for _, run := range hexEscapeRun.FindAllString(text, -1) {
+		data, err := hex.DecodeString(strings.NewReplacer(`\x`, "", `\X`, "").Replace(run))
+		if err == nil && isReadable(data) {
+			decoded = append(decoded, string(data))
+		}
+	}
+	return strings.Join(decoded, "\n")
+}
+
+// ------------------------------------------------------------------------------------------------------
+// isReadable reports whether data is mostly printable ASCII, which random tokens and binary are not
+func isReadable(data []byte) bool {
+	printable := 0
+	for _, b := range data {
+		if b == '\n' || b == '\t' || (b >= 0x20 && b < 0x7f) {
+			printable++
+		}
+	}
+	return len(data) > 0 && printable*10 >= len(data)*9 && strings.Contains(string(data), " ")
+}
+
+// ------------------------------------------------------------------------------------------------------
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ------------------------------------------------------------------------------------------------------
+// detectInjection scores text from source and returns a warning when it crosses the threshold. The
+// detection is logged and counted; acting on it is left to the caller.
+func (s *chatService) detectInjection(ctx context.Context, source, text string) *Warning {
+	if s.injection == nil {
+		return nil
+	}
+
+	score, signals := s.injection.Score(text)
+	if score < s.injection.threshold {
+		return nil
+	}
+
+	action := string(s.injection.action)
+	metrics.PromptInjectionDetectionsTotal.WithLabelValues(source, action).Inc()
+	trace.SpanFromContext(ctx).AddEvent("prompt_injection", trace.WithAttributes(
+		attribute.String("injection.source", source),
+		attribute.Float64("injection.score", score),
+		attribute.StringSlice("injection.signals", signals),
+	))
+	logging.FromContext(ctx).Warn("Suspected prompt injection",
+		zap.String("source", source),
+		zap.Float64("score", score),
+		zap.Strings("signals", signals),
+		zap.String("action", action),
+	)
+
+	return &Warning{Type: WarningTypePromptInjection, Source: source, Score: score, Signals: signals}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// inspectUserMessage applies the injection detector to the new user message
+func (s *chatService) inspectUserMessage(ctx context.Context, text string) ([]Warning, error) {
+	warning := s.detectInjection(ctx, injectionSourceUser, text)
+	if warning == nil {
+		return nil, nil
+	}
+
+	switch s.injection.action {
+	case InjectionActionReject:
+		return nil, apperror.NewPromptInjectionError(
+			fmt.Sprintf("message looks like a prompt injection attempt (%s)", strings.Join(warning.Signals, ", ")),
+			nil,
+		)
+	case InjectionActionWarn:
+		return []Warning{*warning}, nil
+	default:
+		return nil, nil
+	}
+}
@@ -1,7 +1,40 @@
 package service
 
+import (
+	"context"
+
+	"llm-chat-service/internal/storage"
+)
+
 // ChatService defines the interface for chat operations
 type ChatService interface {
-	ProcessChat(req *ChatRequest) (string, error)
-	ProcessChatStream(req *ChatRequest, onToken func(string) error) (string, error)
+	ProcessChat(ctx context.Context, req *ChatRequest) (*ChatResponse, error)
+	ProcessChatStream(ctx context.Context, req *ChatRequest, onToken func(string) error) (*ChatResponse, error)
+}
+
+// Reconfigurable is implemented by chat services whose limits, model and history size can change
+// while they serve requests
+type Reconfigurable interface {
+	Limits() Limits
+	SetLimits(limits Limits)
+	SetModel(model string) error
+	SetMaxExchanges(maxExchanges int) error
+}
+
+// TokenCounter is implemented by chat services that can count prompt tokens the way their model does
+type TokenCounter interface {
+	CountTokens(ctx context.Context, model string, messages []storage.Message) (*TokenCount, error)
+}
+
+// CandidateSelector is implemented by chat services that let clients choose which of several candidate
+// answers is stored in the history once they have seen them
+type CandidateSelector interface {
+	SelectCandidate(ctx context.Context, selectionID string, index int) (*Candidate, error)
+}
+
+// DependencyChecker is implemented by chat services that can check the dependencies they own, for
+// readiness probes
+type DependencyChecker interface {
+	PingStore(ctx context.Context) error
+	PingLLM(ctx context.Context) error
 }
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"llm-chat-service/internal/llm"
+	"llm-chat-service/internal/logging"
+
+	"go.uber.org/zap"
+)
+
+// Citation identifies a knowledge base excerpt given to the model as context. Index matches the
+// [n] marker the model was asked to cite it with.
+type Citation struct {
+	Index      int     `json:"index"`
+	DocumentID string  `json:"document_id"`
+	Source     string  `json:"source"`
+	ChunkIndex int     `json:"chunk_index"`
+	Score      float64 `json:"score"`
+	Text       string  `json:"text"`
+}
+
+// ------------------------------------------------------------------------------------------------------
+// retrieveKnowledge looks up the request's knowledge base for query and, when anything relevant is
+// found, inserts the excerpts as a system message right before the question. Excerpts are not persisted.
+// Excerpts that look like prompt injections are reported, or left out when the detector rejects them.
+func (s *chatService) retrieveKnowledge(ctx context.Context, req *ChatRequest, query string, messages []llm.Message) ([]llm.Message, []Citation, []Warning, error) {
+	if req.KnowledgeBase == "" {
+		return messages, nil, nil, nil
+	}
+
+	results, err := s.knowledge.Retrieve(ctx, req.KnowledgeBase, query)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var citations []Citation
+	var warnings []Warning
+	for _, result := range results {
+		// Chunks sharing nothing with the query are noise, not context
+		if result.Score <= 0 {
+			continue
+		}
+		if warning := s.detectInjection(ctx, injectionSourceRetrieved, result.Chunk.Text); warning != nil {
+			if s.injection.action == InjectionActionReject {
+				continue
+			}
+			if s.injection.action == InjectionActionWarn {
+				warning.DocumentID = result.Chunk.DocumentID
+				warnings = append(warnings, *warning)
+			}
+		}
+		citations = append(citations, Citation{
+			Index:      len(citations) + 1,
+			DocumentID: result.Chunk.DocumentID,
+			Source:     result.Chunk.Source,
+			ChunkIndex: result.Chunk.Index,
+			Score:      result.Score,
+			Text:       result.Chunk.Text,
+		})
+	}
+
+	logging.FromContext(ctx).Debug("Knowledge base retrieval",
+		zap.String("knowledge_base", req.KnowledgeBase),
+		zap.Int("citations", len(citations)),
+	)
+	if len(citations) == 0 {
+		return messages, nil, warnings, nil
+	}
+
+	augmented := make([]llm.Message, 0, len(messages)+1)
+	augmented = append(augmented, messages[:len(messages)-1]...)
+	augmented = append(augmented,
+		llm.Message{Role: "system", Content: knowledgeContext(citations)},
+		messages[len(messages)-1],
+	)
+	return augmented, citations, warnings, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+func knowledgeContext(citations []Citation) string {
+	var b strings.Builder
+	b.WriteString("Answer the next question using the numbered excerpts below. ")
+	b.WriteString("Cite the excerpts you rely on with their number in square brackets, for example [1]. ")
+	b.WriteString("If the excerpts do not contain the answer, say so instead of guessing.\n")
+
+	for _, citation := range citations {
+		fmt.Fprintf(&b, "\n[%d] %s\n%s\n", citation.Index, citation.Source, citation.Text)
+	}
+	return b.String()
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"llm-chat-service/internal/llm"
+	"llm-chat-service/internal/rag"
+	"llm-chat-service/internal/storage"
+	"strings"
+	"testing"
+)
+
+func TestChatService_ProcessChat_KnowledgeBase(t *testing.T) {
+	chunker, err := rag.NewChunker(50, 0)
+	if err != nil {
+		t.Fatalf("NewChunker() error = %v", err)
+	}
+	knowledge := rag.NewService(chunker, rag.NewHashEmbedder(256), rag.NewMemoryIndex(), 3)
+	_, err = knowledge.Ingest(context.Background(), "handbook", rag.Document{
+		Source: "leave.md",
+		Format: rag.FormatMarkdown,
+		Text:   "Employees get twenty five days of paid leave per year.",
+	})
+	if err != nil {
+		t.Fatalf("Ingest() error = %v", err)
+	}
+
+	mockClient := &mockGroqClient{
+		chatFunc: func(messages []llm.Message, opts llm.ChatOptions) (*llm.Result, error) {
+			if len(messages) != 2 {
+				t.Fatalf("Expected context and question messages, got %+v", messages)
+			}
+			if messages[0].Role != "system" || !strings.Contains(messages[0].Content, "[1] leave.md") {
+				t.Errorf("Expected numbered excerpts before the question, got %+v", messages[0])
+			}
+			return &llm.Result{Content: "Twenty five days [1]."}, nil
+		},
+	}
+
+	memoryStore := storage.NewMemoryStore(20)
+	service := NewChatService(memoryStore, nil, mockClient, 1024, WithKnowledge(knowledge))
+
+	response, err := service.ProcessChat(context.Background(), &ChatRequest{
+		Messages:      []storage.Message{{Role: "user", Content: "How many days of paid leave do employees get?"}},
+		KnowledgeBase: "handbook",
+	})
+	if err != nil {
+		t.Fatalf("ProcessChat() error = %v", err)
+	}
+	if len(response.Citations) != 1 || response.Citations[0].Source != "leave.md" || response.Citations[0].Index != 1 {
+		t.Errorf("Expected one citation of leave.md, got %+v", response.Citations)
+	}
+
+	// Retrieved context is not part of the conversation history
+	for _, msg := range memoryStore.GetMessages() {
+		if msg.Role == "system" {
+			t.Errorf("Expected no system messages in history, got %+v", msg)
+		}
+	}
+}
+
+func TestChatService_ProcessChat_KnowledgeBaseDisabled(t *testing.T) {
+	service := NewChatService(storage.NewMemoryStore(20), nil, &mockGroqClient{}, 1024)
+
+	_, err := service.ProcessChat(context.Background(), &ChatRequest{
+		Messages:      []storage.Message{{Role: "user", Content: "Hello"}},
+		KnowledgeBase: "handbook",
+	})
+	if err == nil {
+		t.Error("Expected an error when knowledge bases are disabled")
+	}
+}
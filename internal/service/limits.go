@@ -0,0 +1,61 @@
+package service
+
+import (
+	"fmt"
+
+	"llm-chat-service/internal/llm"
+	"llm-chat-service/internal/storage"
+)
+
+// Limits are the generation limits of the chat service. They can be replaced while the service runs;
+// each turn works with the limits in place when it reads them.
+type Limits struct {
+	MaxTokens               int
+	MaxToolIterations       int
+	StructuredOutputRetries int
+	MaxContinuations        int // Zero disables auto-continue
+	MaxContinuationTokens   int
+	MaxCandidates           int
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Limits returns the limits currently applied to new turns
+func (s *chatService) Limits() Limits {
+	return *s.limits.Load()
+}
+
+// ------------------------------------------------------------------------------------------------------
+// SetLimits replaces the limits for subsequent turns
+func (s *chatService) SetLimits(limits Limits) {
+	s.limits.Store(&limits)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// SetModel switches the model of subsequent LLM requests, if the client supports it
+func (s *chatService) SetModel(model string) error {
+	switcher, ok := s.llmClient.(llm.ModelSwitcher)
+	if !ok {
+		return fmt.Errorf("the LLM client does not support changing the model")
+	}
+	switcher.SetModel(model)
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// SetMaxExchanges changes how many exchanges the history keeps, if the message store supports it
+func (s *chatService) SetMaxExchanges(maxExchanges int) error {
+	limiter, ok := s.messageStore.(storage.ExchangeLimiter)
+	if !ok {
+		return fmt.Errorf("the message store does not support changing the history size")
+	}
+	limiter.SetMaxExchanges(maxExchanges)
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// updateLimits applies update to a copy of the current limits and stores the result
+func (s *chatService) updateLimits(update func(*Limits)) {
+	limits := *s.limits.Load()
+	update(&limits)
+	s.limits.Store(&limits)
+}
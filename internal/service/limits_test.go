@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"llm-chat-service/internal/llm"
+	"llm-chat-service/internal/storage"
+)
+
+func TestChatService_SetLimits(t *testing.T) {
+	var gotMaxTokens int
+	client := &mockGroqClient{
+		chatFunc: func(messages []llm.Message, opts llm.ChatOptions) (*llm.Result, error) {
+			gotMaxTokens = opts.MaxTokens
+			return &llm.Result{Content: "ok"}, nil
+		},
+	}
+	service := NewChatService(storage.NewMemoryStore(20), nil, client, 1024, WithMaxCandidates(2))
+	reconfigurable := service.(Reconfigurable)
+
+	limits := reconfigurable.Limits()
+	if limits.MaxTokens != 1024 || limits.MaxCandidates != 2 || limits.MaxToolIterations != defaultMaxToolIterations {
+		t.Fatalf("Limits() = %+v, want the constructor and option values", limits)
+	}
+
+	limits.MaxTokens = 256
+	limits.MaxCandidates = 1
+	reconfigurable.SetLimits(limits)
+
+	req := &ChatRequest{Messages: []storage.Message{{Role: "user", Content: "hi"}}}
+	if _, err := service.ProcessChat(context.Background(), req); err != nil {
+		t.Fatalf("ProcessChat() error = %v", err)
+	}
+	if gotMaxTokens != 256 {
+		t.Errorf("MaxTokens = %d, want 256", gotMaxTokens)
+	}
+
+	req = &ChatRequest{Messages: []storage.Message{{Role: "user", Content: "hi"}}, N: 2}
+	if _, err := service.ProcessChat(context.Background(), req); err == nil {
+		t.Error("ProcessChat() accepted n above the new limit")
+	}
+}
+
+func TestChatService_SetModel(t *testing.T) {
+	groq := llm.NewGroqClient("key", "http://localhost", "first")
+	if err := NewChatService(storage.NewMemoryStore(20), nil, groq, 1024).(Reconfigurable).SetModel("second"); err != nil {
+		t.Fatalf("SetModel() error = %v", err)
+	}
+	if groq.Model() != "second" {
+		t.Errorf("Model() = %q, want second", groq.Model())
+	}
+
+	if err := NewChatService(storage.NewMemoryStore(20), nil, &mockGroqClient{}, 1024).(Reconfigurable).SetModel("second"); err == nil {
+		t.Error("SetModel() succeeded on a client that cannot switch models")
+	}
+}
+
+func TestChatService_SetMaxExchanges(t *testing.T) {
+	memoryStore := storage.NewMemoryStore(20)
+	for i := 0; i < 3; i++ {
+		memoryStore.AddMessage(storage.Message{Role: "user", Content: "Question"})
+		memoryStore.AddMessage(storage.Message{Role: "assistant", Content: "Answer"})
+	}
+
+	if err := NewChatService(memoryStore, nil, &mockGroqClient{}, 1024).(Reconfigurable).SetMaxExchanges(1); err != nil {
+		t.Fatalf("SetMaxExchanges() error = %v", err)
+	}
+	if history := memoryStore.GetMessages(); len(history) != 2 {
+		t.Errorf("Expected the history trimmed to 1 exchange, got %d messages", len(history))
+	}
+}
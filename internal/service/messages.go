@@ -0,0 +1,58 @@
+package service
+
+import (
+	"slices"
+
+	"llm-chat-service/internal/llm"
+	"llm-chat-service/internal/storage"
+)
+
+// ------------------------------------------------------------------------------------------------------
+// toLLMMessages converts stored history into the provider message format
+func toLLMMessages(messages []storage.Message) []llm.Message {
+	result := make([]llm.Message, len(messages))
+	for i, msg := range messages {
+		result[i] = llm.Message{
+			Role:       msg.Role,
+			Content:    msg.Content,
+			Name:       msg.Name,
+			ToolCallID: msg.ToolCallID,
+		}
+		if len(msg.Parts) > 0 {
+			result[i].Parts = slices.Clone(msg.Parts)
+		}
+		for _, call := range msg.ToolCalls {
+			result[i].ToolCalls = append(result[i].ToolCalls, llm.ToolCall{
+				ID:   call.ID,
+				Type: llm.ToolTypeFunction,
+				Function: llm.FunctionCall{
+					Name:      call.Name,
+					Arguments: call.Arguments,
+				},
+			})
+		}
+	}
+	return result
+}
+
+// ------------------------------------------------------------------------------------------------------
+// fromLLMMessage converts a provider message into its stored form
+func fromLLMMessage(msg llm.Message) storage.Message {
+	result := storage.Message{
+		Role:       msg.Role,
+		Content:    msg.Content,
+		Name:       msg.Name,
+		ToolCallID: msg.ToolCallID,
+	}
+	if len(msg.Parts) > 0 {
+		result.Parts = slices.Clone(msg.Parts)
+	}
+	for _, call := range msg.ToolCalls {
+		result.ToolCalls = append(result.ToolCalls, storage.ToolCall{
+			ID:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	return result
+}
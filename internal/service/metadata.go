@@ -0,0 +1,36 @@
+package service
+
+import (
+	"time"
+
+	"llm-chat-service/internal/llm"
+)
+
+// ------------------------------------------------------------------------------------------------------
+// newResponseMetadata describes the final upstream result of a turn
+func newResponseMetadata(result *llm.Result, latency time.Duration) ResponseMetadata {
+	return ResponseMetadata{
+		FinishReason:      result.FinishReason,
+		Model:             result.Model,
+		Usage:             result.Usage,
+		UpstreamRequestID: result.RequestID,
+		LatencyMs:         latency.Milliseconds(),
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// addUsage sums token accounting over the upstream calls of a turn. The total stays nil until a
+// provider reports usage.
+func addUsage(total, usage *llm.Usage) *llm.Usage {
+	if usage == nil {
+		return total
+	}
+	if total == nil {
+		total = &llm.Usage{}
+	}
+	return &llm.Usage{
+		PromptTokens:     total.PromptTokens + usage.PromptTokens,
+		CompletionTokens: total.CompletionTokens + usage.CompletionTokens,
+		TotalTokens:      total.TotalTokens + usage.TotalTokens,
+	}
+}
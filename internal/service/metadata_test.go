@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"llm-chat-service/internal/llm"
+	"llm-chat-service/internal/storage"
+	"testing"
+)
+
+func TestChatService_ProcessChat_Metadata(t *testing.T) {
+	registry := NewToolRegistry()
+	err := registry.Register("ping", "Replies pong", json.RawMessage(`{"type":"object"}`),
+		func(ctx context.Context, arguments json.RawMessage) (string, error) { return "pong", nil },
+	)
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	calls := 0
+	mockClient := &mockGroqClient{
+		chatFunc: func(messages []llm.Message, opts llm.ChatOptions) (*llm.Result, error) {
+			calls++
+			if calls == 1 {
+				return &llm.Result{
+					ToolCalls:    []llm.ToolCall{{ID: "call_1", Type: llm.ToolTypeFunction, Function: llm.FunctionCall{Name: "ping", Arguments: `{}`}}},
+					FinishReason: llm.FinishReasonToolCalls,
+					Model:        "test-model",
+					Usage:        &llm.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+					RequestID:    "req_1",
+				}, nil
+			}
+			return &llm.Result{
+				Content:      "pong received, and then the answer was cut",
+				FinishReason: llm.FinishReasonLength,
+				Model:        "test-model",
+				Usage:        &llm.Usage{PromptTokens: 20, CompletionTokens: 8, TotalTokens: 28},
+				RequestID:    "req_2",
+			}, nil
+		},
+	}
+
+	service := NewChatService(storage.NewMemoryStore(20), nil, mockClient, 1024, WithTools(registry))
+	response, err := service.ProcessChat(context.Background(), &ChatRequest{
+		Messages: []storage.Message{{Role: "user", Content: "ping"}},
+	})
+	if err != nil {
+		t.Fatalf("ProcessChat() error = %v", err)
+	}
+
+	// Metadata comes from the final call; usage covers the whole turn
+	if response.FinishReason != llm.FinishReasonLength || response.Model != "test-model" || response.UpstreamRequestID != "req_2" {
+		t.Errorf("Metadata = %+v", response.ResponseMetadata)
+	}
+	if want := (llm.Usage{PromptTokens: 30, CompletionTokens: 13, TotalTokens: 43}); response.Usage == nil || *response.Usage != want {
+		t.Errorf("Usage = %+v, want %+v", response.Usage, want)
+	}
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var fields map[string]any
+	_ = json.Unmarshal(data, &fields)
+	for _, field := range []string{"response", "finish_reason", "model", "usage", "upstream_request_id", "latency_ms"} {
+		if _, ok := fields[field]; !ok {
+			t.Errorf("Expected %q in the JSON response, got %s", field, data)
+		}
+	}
+}
+
+func TestChatService_ProcessChatStream_MetadataWithoutUsage(t *testing.T) {
+	service := NewChatService(storage.NewMemoryStore(20), nil, &mockGroqClient{}, 1024)
+	response, err := service.ProcessChatStream(context.Background(), &ChatRequest{
+		Messages: []storage.Message{{Role: "user", Content: "hi"}},
+		Stream:   true,
+	}, func(string) error { return nil })
+	if err != nil {
+		t.Fatalf("ProcessChatStream() error = %v", err)
+	}
+	if response.Usage != nil {
+		t.Errorf("Expected no usage when the provider reports none, got %+v", response.Usage)
+	}
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+
+	"llm-chat-service/internal/contentpart"
+	"llm-chat-service/internal/moderation"
+	"llm-chat-service/internal/storage"
+)
+
+// ------------------------------------------------------------------------------------------------------
+// moderateInput checks the new user message before it is stored or sent upstream. Multimodal
+// messages are checked part by part so redaction keeps the images in place.
+func (s *chatService) moderateInput(ctx context.Context, msg storage.Message) (storage.Message, error) {
+	if s.moderator == nil {
+		return msg, nil
+	}
+
+	if len(msg.Parts) == 0 {
+		content, err := s.moderator.Check(ctx, moderation.StageInput, msg.Content)
+		if err != nil {
+			return storage.Message{}, err
+		}
+		msg.Content = content
+		return msg, nil
+	}
+
+	// Copy so the caller's request is left untouched
+	parts := make([]contentpart.Part, len(msg.Parts))
+	copy(parts, msg.Parts)
+	for i, part := range parts {
+		if part.Type != contentpart.TypeText {
+			continue
+		}
+		text, err := s.moderator.Check(ctx, moderation.StageInput, part.Text)
+		if err != nil {
+			return storage.Message{}, err
+		}
+		parts[i].Text = text
+	}
+	msg.Parts = parts
+	msg.Content = contentpart.Text(parts)
+	return msg, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// moderateOutput checks a complete answer
+func (s *chatService) moderateOutput(ctx context.Context, response string) (string, error) {
+	if s.moderator == nil {
+		return response, nil
+	}
+	return s.moderator.Check(ctx, moderation.StageOutput, response)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// moderateStream wraps onToken so streamed output is moderated window by window. The returned
+// endMessage function releases a message that ended in tool calls, so it is left out of the answer.
+// The returned finish function releases the last window once generation ends and yields the answer
+// as the client saw it.
+func (s *chatService) moderateStream(ctx context.Context, onToken func(string) error) (func(string) error, func() error, func(string, error) (string, error)) {
+	if s.moderator == nil {
+		return onToken, nil, func(response string, err error) (string, error) { return response, err }
+	}
+
+	stream := s.moderator.NewStream(ctx, onToken)
+	return stream.Write, stream.EndMessage, func(response string, err error) (string, error) {
+		if err != nil {
+			return response, err
+		}
+		if err := stream.Flush(); err != nil {
+			return "", err
+		}
+		return stream.Text(), nil
+	}
+}
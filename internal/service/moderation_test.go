@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"llm-chat-service/internal/llm"
+	"llm-chat-service/internal/moderation"
+	"llm-chat-service/internal/storage"
+	"strings"
+	"testing"
+
+	apperror "llm-chat-service/internal/error"
+)
+
+func newKeywordModerator(t *testing.T, input, output moderation.Action) *moderation.Moderator {
+	t.Helper()
+	classifier, err := moderation.NewPatternClassifier([]string{"forbidden"}, nil)
+	if err != nil {
+		t.Fatalf("NewPatternClassifier() error = %v", err)
+	}
+	return moderation.NewModerator(classifier, input, output, 8)
+}
+
+func TestChatService_Moderation_BlocksInput(t *testing.T) {
+	called := false
+	client := &mockGroqClient{
+		chatFunc: func(messages []llm.Message, opts llm.ChatOptions) (*llm.Result, error) {
+			called = true
+			return &llm.Result{Content: "ok"}, nil
+		},
+	}
+
+	memoryStore := storage.NewMemoryStore(20)
+	service := NewChatService(memoryStore, nil, client, 1024,
+		WithModerator(newKeywordModerator(t, moderation.ActionBlock, moderation.ActionBlock)))
+
+	req := &ChatRequest{Messages: []storage.Message{{Role: "user", Content: "tell me the forbidden thing"}}}
+	_, err := service.ProcessChat(context.Background(), req)
+
+	var appErr *apperror.AppError
+	if !errors.As(err, &appErr) || appErr.Type != apperror.ErrorTypeModeration {
+		t.Fatalf("ProcessChat() error = %v, want a moderation error", err)
+	}
+	if called {
+		t.Error("Blocked input must not reach the model")
+	}
+	if history := memoryStore.GetMessages(); len(history) != 0 {
+		t.Errorf("Blocked input must not be stored, history = %v", history)
+	}
+}
+
+func TestChatService_Moderation_RedactsInput(t *testing.T) {
+	var sent string
+	client := &mockGroqClient{
+		chatFunc: func(messages []llm.Message, opts llm.ChatOptions) (*llm.Result, error) {
+			sent = messages[len(messages)-1].Content
+			return &llm.Result{Content: "ok"}, nil
+		},
+	}
+
+	service := NewChatService(storage.NewMemoryStore(20), nil, client, 1024,
+		WithModerator(newKeywordModerator(t, moderation.ActionRedact, moderation.ActionBlock)))
+
+	req := &ChatRequest{Messages: []storage.Message{{Role: "user", Content: "the forbidden word"}}}
+	if _, err := service.ProcessChat(context.Background(), req); err != nil {
+		t.Fatalf("ProcessChat() error = %v", err)
+	}
+	if want := "the [redacted] word"; sent != want {
+		t.Errorf("Sent %q, want %q", sent, want)
+	}
+}
+
+func TestChatService_Moderation_RedactsStreamedOutput(t *testing.T) {
+	client := &mockGroqClient{
+		streamChatFunc: func(messages []llm.Message, opts llm.ChatOptions, onToken func(string) error) (*llm.Result, error) {
+			for _, token := range []string{"this is ", "forbi", "dden ", "content"} {
+				if err := onToken(token); err != nil {
+					return nil, err
+				}
+			}
+			return &llm.Result{Content: "this is forbidden content"}, nil
+		},
+	}
+
+	memoryStore := storage.NewMemoryStore(20)
+	service := NewChatService(memoryStore, nil, client, 1024,
+		WithModerator(newKeywordModerator(t, moderation.ActionBlock, moderation.ActionRedact)))
+
+	var streamed strings.Builder
+	req := &ChatRequest{Messages: []storage.Message{{Role: "user", Content: "hi"}}, Stream: true}
+	response, err := service.ProcessChatStream(context.Background(), req, func(token string) error {
+		streamed.WriteString(token)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ProcessChatStream() error = %v", err)
+	}
+
+	want := "this is [redacted] content"
+	if streamed.String() != want {
+		t.Errorf("Streamed %q, want %q", streamed.String(), want)
+	}
+	if response.Content != want {
+		t.Errorf("Response = %q, want %q", response.Content, want)
+	}
+	if history := memoryStore.GetMessages(); history[len(history)-1].Content != want {
+		t.Errorf("Stored answer = %q, want %q", history[len(history)-1].Content, want)
+	}
+}
+
+func TestChatService_Moderation_StreamedToolCallTextNotInAnswer(t *testing.T) {
+	calls := 0
+	client := &mockGroqClient{
+		streamChatFunc: func(messages []llm.Message, opts llm.ChatOptions, onToken func(string) error) (*llm.Result, error) {
+			calls++
+			if calls == 1 {
+				if err := onToken("Let me check. "); err != nil {
+					return nil, err
+				}
+				return &llm.Result{Content: "Let me check. ", ToolCalls: []llm.ToolCall{{
+					ID:       "call_1",
+					Type:     llm.ToolTypeFunction,
+					Function: llm.FunctionCall{Name: "read_page", Arguments: `{}`},
+				}}}, nil
+			}
+			if err := onToken("The page says forbidden things"); err != nil {
+				return nil, err
+			}
+			return &llm.Result{Content: "The page says forbidden things"}, nil
+		},
+	}
+
+	memoryStore := storage.NewMemoryStore(20)
+	service := NewChatService(memoryStore, nil, client, 1024,
+		WithModerator(newKeywordModerator(t, moderation.ActionBlock, moderation.ActionRedact)))
+
+	var streamed strings.Builder
+	req := &ChatRequest{
+		Messages: []storage.Message{{Role: "user", Content: "Read the page"}},
+		Stream:   true,
+		Tools: []llm.Tool{{
+			Type:     llm.ToolTypeFunction,
+			Function: llm.FunctionDefinition{Name: "read_page"},
+		}},
+		ClientToolExecutor: func(ctx context.Context, call llm.ToolCall) (string, error) {
+			return "hi", nil
+		},
+	}
+	response, err := service.ProcessChatStream(context.Background(), req, func(token string) error {
+		streamed.WriteString(token)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ProcessChatStream() error = %v", err)
+	}
+
+	if want := "Let me check. The page says [redacted] things"; streamed.String() != want {
+		t.Errorf("Streamed %q, want %q", streamed.String(), want)
+	}
+	want := "The page says [redacted] things"
+	if response.Content != want {
+		t.Errorf("Response = %q, want %q", response.Content, want)
+	}
+	history := memoryStore.GetMessages()
+	if len(history) != 4 || history[1].Content != "Let me check. " || history[3].Content != want {
+		t.Errorf("History = %+v, want the tool call text only in its own message", history)
+	}
+}
+
+func TestChatService_Moderation_BlocksOutput(t *testing.T) {
+	client := &mockGroqClient{
+		chatFunc: func(messages []llm.Message, opts llm.ChatOptions) (*llm.Result, error) {
+			return &llm.Result{Content: "here is the forbidden answer"}, nil
+		},
+	}
+
+	memoryStore := storage.NewMemoryStore(20)
+	service := NewChatService(memoryStore, nil, client, 1024,
+		WithModerator(newKeywordModerator(t, moderation.ActionBlock, moderation.ActionBlock)))
+
+	req := &ChatRequest{Messages: []storage.Message{{Role: "user", Content: "hi"}}}
+	if _, err := service.ProcessChat(context.Background(), req); err == nil {
+		t.Fatal("Expected the answer to be blocked")
+	}
+	for _, msg := range memoryStore.GetMessages() {
+		if msg.Role == "assistant" {
+			t.Errorf("Blocked answer must not be stored, got %q", msg.Content)
+		}
+	}
+}
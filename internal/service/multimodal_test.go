@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"llm-chat-service/internal/contentpart"
+	"llm-chat-service/internal/llm"
+	"llm-chat-service/internal/storage"
+	"strings"
+	"testing"
+)
+
+// visionClient is a mock client for a model that accepts images
+type visionClient struct {
+	mockGroqClient
+}
+
+func (c *visionClient) SupportsImages() bool { return true }
+
+func imagePart(url string) contentpart.Part {
+	return contentpart.Part{Type: contentpart.TypeImageURL, ImageURL: &contentpart.ImageURL{URL: url}}
+}
+
+func TestChatRequest_Validate_ContentParts(t *testing.T) {
+	png := "data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"))
+	notPNG := "data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte("just text"))
+	oversized := "data:image/png;base64," + strings.Repeat("A", (maxImageBytes/3+1)*4)
+
+	tests := []struct {
+		name    string
+		message storage.Message
+		wantErr bool
+	}{
+		{
+			name:    "text and image url",
+			message: storage.Message{Role: "user", Content: "What is this?", Parts: []contentpart.Part{{Type: "text", Text: "What is this?"}, imagePart("https://example.com/a.png")}},
+		},
+		{
+			name:    "image only",
+			message: storage.Message{Role: "user", Parts: []contentpart.Part{imagePart(png)}},
+		},
+		{
+			name:    "unsupported mime type",
+			message: storage.Message{Role: "user", Parts: []contentpart.Part{imagePart("data:image/tiff;base64,AAAA")}},
+			wantErr: true,
+		},
+		{
+			name:    "mime type does not match data",
+			message: storage.Message{Role: "user", Parts: []contentpart.Part{imagePart(notPNG)}},
+			wantErr: true,
+		},
+		{
+			name:    "oversized image",
+			message: storage.Message{Role: "user", Parts: []contentpart.Part{imagePart(oversized)}},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported url scheme",
+			message: storage.Message{Role: "user", Parts: []contentpart.Part{imagePart("file:///etc/passwd")}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown part type",
+			message: storage.Message{Role: "user", Content: "hi", Parts: []contentpart.Part{{Type: "audio"}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := ChatRequest{Messages: []storage.Message{tt.message}}
+			if err := req.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChatService_ProcessChat_Images(t *testing.T) {
+	var sent []byte
+	client := &visionClient{mockGroqClient{
+		chatFunc: func(messages []llm.Message, opts llm.ChatOptions) (*llm.Result, error) {
+			sent, _ = json.Marshal(messages[len(messages)-1])
+			return &llm.Result{Content: "A cat"}, nil
+		},
+	}}
+
+	var req ChatRequest
+	body := `{"messages":[{"role":"user","content":[{"type":"text","text":"What is this?"},{"type":"image_url","image_url":{"url":"https://example.com/cat.png"}}]}]}`
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	memoryStore := storage.NewMemoryStore(20)
+	service := NewChatService(memoryStore, nil, client, 1024)
+	if _, err := service.ProcessChat(context.Background(), &req); err != nil {
+		t.Fatalf("ProcessChat() error = %v", err)
+	}
+
+	want := `{"role":"user","content":[{"type":"text","text":"What is this?"},{"type":"image_url","image_url":{"url":"https://example.com/cat.png"}}]}`
+	if string(sent) != want {
+		t.Errorf("Provider message = %s, want %s", sent, want)
+	}
+	if history := memoryStore.GetMessages(); !history[0].HasImages() {
+		t.Error("Expected the image to be kept in history")
+	}
+
+	// Models without vision support reject images up front
+	textOnly := NewChatService(storage.NewMemoryStore(20), nil, &mockGroqClient{}, 1024)
+	if _, err := textOnly.ProcessChat(context.Background(), &req); err == nil {
+		t.Error("Expected an error for a model without image support")
+	}
+}
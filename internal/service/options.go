@@ -0,0 +1,105 @@
+package service
+
+import (
+	"llm-chat-service/internal/audit"
+	"llm-chat-service/internal/moderation"
+	"llm-chat-service/internal/rag"
+)
+
+// defaultMaxToolIterations caps model/tool round trips per turn when not configured
+const defaultMaxToolIterations = 5
+
+// defaultStructuredOutputRetries is how many repair prompts are sent for an invalid structured answer
+const defaultStructuredOutputRetries = 2
+
+// Option configures optional chatService collaborators
+type Option func(*chatService)
+
+// ------------------------------------------------------------------------------------------------------
+// WithAuditor records every chat turn to the given auditor. A nil auditor disables auditing.
+func WithAuditor(auditor *audit.Auditor) Option {
+	return func(s *chatService) {
+		s.auditor = auditor
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// WithTools exposes the registry's tools to the model and executes the calls it makes
+func WithTools(registry *ToolRegistry) Option {
+	return func(s *chatService) {
+		s.tools = registry
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// WithMaxToolIterations caps how many times per turn the model may request tool calls
+func WithMaxToolIterations(n int) Option {
+	return func(s *chatService) {
+		if n > 0 {
+			s.updateLimits(func(l *Limits) { l.MaxToolIterations = n })
+		}
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// WithStructuredOutputRetries sets how many times an answer that violates the requested response
+// format is sent back to the model for repair. Zero disables repair.
+func WithStructuredOutputRetries(n int) Option {
+	return func(s *chatService) {
+		if n >= 0 {
+			s.updateLimits(func(l *Limits) { l.StructuredOutputRetries = n })
+		}
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// WithKnowledge enables the knowledge_base request option. A nil service disables it.
+func WithKnowledge(knowledge *rag.Service) Option {
+	return func(s *chatService) {
+		s.knowledge = knowledge
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// WithModerator moderates user messages before they reach the model and the model's answers before
+// they reach the client. A nil moderator disables moderation.
+func WithModerator(moderator *moderation.Moderator) Option {
+	return func(s *chatService) {
+		s.moderator = moderator
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// WithInjectionDetector screens user messages and retrieved excerpts for prompt injections. A nil
+// detector disables screening.
+func WithInjectionDetector(detector *InjectionDetector) Option {
+	return func(s *chatService) {
+		s.injection = detector
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// WithAutoContinue makes answers cut off by max_tokens continue in follow-up requests, at most
+// maxContinuations times and up to maxTokens completion tokens in total. Zero continuations disables it.
+func WithAutoContinue(maxContinuations, maxTokens int) Option {
+	return func(s *chatService) {
+		s.updateLimits(func(l *Limits) {
+			if maxContinuations >= 0 {
+				l.MaxContinuations = maxContinuations
+			}
+			if maxTokens > 0 {
+				l.MaxContinuationTokens = maxTokens
+			}
+		})
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// WithMaxCandidates caps how many candidate answers (n) a request may ask for
+func WithMaxCandidates(n int) Option {
+	return func(s *chatService) {
+		if n > 0 {
+			s.updateLimits(func(l *Limits) { l.MaxCandidates = n })
+		}
+	}
+}
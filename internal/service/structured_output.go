@@ -0,0 +1,183 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	apperror "llm-chat-service/internal/error"
+	"llm-chat-service/internal/llm"
+	"llm-chat-service/internal/logging"
+	"llm-chat-service/internal/metrics"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"go.uber.org/zap"
+)
+
+// responseSchemaURL is the in-memory location request schemas are compiled under
+const responseSchemaURL = "mem://response_format/schema.json"
+
+// ------------------------------------------------------------------------------------------------------
+// compileResponseSchema compiles the JSON Schema of a json_schema response format. Remote $refs are
+// refused so a request cannot make the server fetch arbitrary URLs.
+func compileResponseSchema(format *llm.ResponseFormat) (*jsonschema.Schema, error) {
+	if format == nil || format.Type != llm.ResponseFormatJSONSchema {
+		return nil, nil
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.LoadURL = func(url string) (io.ReadCloser, error) {
+		return nil, fmt.Errorf("loading external schema %q is not allowed", url)
+	}
+	if err := compiler.AddResource(responseSchemaURL, bytes.NewReader(format.JSONSchema.Schema)); err != nil {
+		return nil, apperror.NewValidationError("response_format.json_schema.schema is not valid JSON", err)
+	}
+	schema, err := compiler.Compile(responseSchemaURL)
+	if err != nil {
+		return nil, apperror.NewValidationError("response_format.json_schema.schema is not a valid JSON Schema", err)
+	}
+	return schema, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// generateStructured produces an answer that conforms to the request's response format. Answers that
+// fail validation are sent back to the model with a repair prompt up to structuredOutputRetries times.
+// The upstream call is never streamed; the validated answer is emitted to onToken as a single token.
+func (s *chatService) generateStructured(ctx context.Context, req *ChatRequest, messages []llm.Message, onToken func(string) error) (*llm.Result, []llm.Message, error) {
+	logger := logging.FromContext(ctx)
+
+	format, instruction := s.upstreamResponseFormat(req.ResponseFormat)
+	messages = append([]llm.Message{{Role: "system", Content: instruction}}, messages...)
+
+	retries := s.limits.Load().StructuredOutputRetries
+	var violation error
+	var usage *llm.Usage
+	for attempt := 0; attempt <= retries; attempt++ {
+		// Only the tool-call turns of the attempt that produced the valid answer are kept
+		result, toolTurns, err := s.runToolLoop(ctx, req, messages, format, nil, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		usage = addUsage(usage, result.Usage)
+
+		var answer string
+		answer, violation = validateStructuredOutput(result.Content, req.responseSchema)
+		if violation == nil {
+			outcome := "valid"
+			if attempt > 0 {
+				outcome = "repaired"
+			}
+			metrics.StructuredOutputTotal.WithLabelValues(outcome).Inc()
+
+			if onToken != nil {
+				if err := onToken(answer); err != nil {
+					return nil, nil, err
+				}
+			}
+			result.Content = answer
+			result.Usage = usage
+			return result, toolTurns, nil
+		}
+
+		logger.Warn("Model response did not match response_format",
+			zap.Int("attempt", attempt+1),
+			zap.Error(violation),
+		)
+
+		// Repair turns are only shown to the model, never persisted to history
+		messages = append(messages,
+			llm.Message{Role: "assistant", Content: result.Content},
+			llm.Message{Role: "user", Content: repairPrompt(violation)},
+		)
+	}
+
+	metrics.StructuredOutputTotal.WithLabelValues("failed").Inc()
+	return nil, nil, apperror.NewOutputValidationError(
+		fmt.Sprintf("model response did not match response_format after %d attempts", retries+1),
+		violation,
+	)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// upstreamResponseFormat picks the format to forward to the provider and the system instruction that
+// describes it. Formats the provider cannot enforce are downgraded and described in the instruction only.
+func (s *chatService) upstreamResponseFormat(format *llm.ResponseFormat) (*llm.ResponseFormat, string) {
+	instruction := "Respond only with a valid JSON object and no other text."
+	if format.Type == llm.ResponseFormatJSONSchema {
+		instruction = fmt.Sprintf(
+			"Respond only with a JSON object that conforms to the following JSON Schema and no other text:\n%s",
+			format.JSONSchema.Schema,
+		)
+	}
+
+	switch {
+	case s.supportsResponseFormat(format.Type):
+		return format, instruction
+	case s.supportsResponseFormat(llm.ResponseFormatJSONObject):
+		return &llm.ResponseFormat{Type: llm.ResponseFormatJSONObject}, instruction
+	default:
+		return nil, instruction
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (s *chatService) supportsResponseFormat(formatType string) bool {
+	supporter, ok := s.llmClient.(llm.ResponseFormatSupporter)
+	return ok && supporter.SupportsResponseFormat(formatType)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// validateStructuredOutput checks that content is a JSON object matching schema (when set) and returns
+// it with surrounding whitespace and Markdown code fences removed
+func validateStructuredOutput(content string, schema *jsonschema.Schema) (string, error) {
+	answer := stripCodeFence(content)
+
+	decoder := json.NewDecoder(strings.NewReader(answer))
+	decoder.UseNumber()
+
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return "", fmt.Errorf("response is not valid JSON: %w", err)
+	}
+	if decoder.More() {
+		return "", errors.New("response contains data after the JSON value")
+	}
+	if _, ok := value.(map[string]any); !ok {
+		return "", errors.New("response is not a JSON object")
+	}
+
+	if schema != nil {
+		if err := schema.Validate(value); err != nil {
+			return "", err
+		}
+	}
+	return answer, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// stripCodeFence removes a Markdown code fence wrapped around the whole content
+func stripCodeFence(content string) string {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "```") || !strings.HasSuffix(content, "```") || len(content) < 6 {
+		return content
+	}
+
+	content = strings.TrimSuffix(strings.TrimPrefix(content, "```"), "```")
+	// Drop the language tag on the opening line, e.g. ```json
+	if newline := strings.IndexByte(content, '\n'); newline >= 0 && !strings.ContainsAny(content[:newline], "{[") {
+		content = content[newline+1:]
+	}
+	return strings.TrimSpace(content)
+}
+
+// ------------------------------------------------------------------------------------------------------
+func repairPrompt(violation error) string {
+	return fmt.Sprintf(
+		"Your previous response was rejected: %v. Reply again with only the corrected JSON object and no other text.",
+		violation,
+	)
+}
@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	apperror "llm-chat-service/internal/error"
+	"llm-chat-service/internal/llm"
+	"llm-chat-service/internal/storage"
+	"strings"
+	"testing"
+)
+
+func newLocationFormat() *llm.ResponseFormat {
+	return &llm.ResponseFormat{
+		Type: llm.ResponseFormatJSONSchema,
+		JSONSchema: &llm.JSONSchema{
+			Name: "location",
+			Schema: json.RawMessage(`{
+				"type": "object",
+				"properties": {"city": {"type": "string"}},
+				"required": ["city"]
+			}`),
+		},
+	}
+}
+
+func TestChatService_ProcessChat_StructuredOutputRepair(t *testing.T) {
+	calls := 0
+	mockClient := &mockGroqClient{
+		chatFunc: func(messages []llm.Message, opts llm.ChatOptions) (*llm.Result, error) {
+			calls++
+			if messages[0].Role != "system" || !strings.Contains(messages[0].Content, `"required"`) {
+				t.Errorf("Expected a system instruction describing the schema, got %+v", messages[0])
+			}
+			if calls == 1 {
+				return &llm.Result{Content: `{"town": "Lyon"}`}, nil
+			}
+			last := messages[len(messages)-1]
+			if last.Role != "user" || !strings.Contains(last.Content, "rejected") {
+				t.Errorf("Expected a repair prompt, got %+v", last)
+			}
+			return &llm.Result{Content: "```json\n{\"city\": \"Lyon\"}\n```"}, nil
+		},
+	}
+
+	memoryStore := storage.NewMemoryStore(20)
+	service := NewChatService(memoryStore, nil, mockClient, 1024)
+
+	response, err := service.ProcessChat(context.Background(), &ChatRequest{
+		Messages:       []storage.Message{{Role: "user", Content: "I live in Lyon"}},
+		ResponseFormat: newLocationFormat(),
+	})
+	if err != nil {
+		t.Fatalf("ProcessChat() error = %v", err)
+	}
+	if response.Content != `{"city": "Lyon"}` {
+		t.Errorf("ProcessChat() response = %q, want the unfenced JSON object", response.Content)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 LLM calls, got %d", calls)
+	}
+
+	// Repair turns must not leak into history
+	if history := memoryStore.GetMessages(); len(history) != 2 {
+		t.Errorf("Expected 2 history messages, got %d", len(history))
+	}
+}
+
+func TestChatService_ProcessChat_StructuredOutputRetryPersistsOneToolGroup(t *testing.T) {
+	registry := NewToolRegistry()
+	err := registry.Register("locate", "Returns the user's city", nil,
+		func(ctx context.Context, arguments json.RawMessage) (string, error) {
+			return "Lyon", nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	calls := 0
+	mockClient := &mockGroqClient{
+		chatFunc: func(messages []llm.Message, opts llm.ChatOptions) (*llm.Result, error) {
+			calls++
+			switch calls {
+			case 1, 3:
+				return &llm.Result{ToolCalls: []llm.ToolCall{{
+					ID:       "call_1",
+					Type:     llm.ToolTypeFunction,
+					Function: llm.FunctionCall{Name: "locate", Arguments: "{}"},
+				}}}, nil
+			case 2:
+				return &llm.Result{Content: "Lyon"}, nil
+			default:
+				return &llm.Result{Content: `{"city": "Lyon"}`}, nil
+			}
+		},
+	}
+
+	memoryStore := storage.NewMemoryStore(20)
+	service := NewChatService(memoryStore, nil, mockClient, 1024, WithTools(registry))
+
+	_, err = service.ProcessChat(context.Background(), &ChatRequest{
+		Messages:       []storage.Message{{Role: "user", Content: "Where do I live?"}},
+		ResponseFormat: newLocationFormat(),
+	})
+	if err != nil {
+		t.Fatalf("ProcessChat() error = %v", err)
+	}
+	if calls != 4 {
+		t.Errorf("Expected 4 LLM calls, got %d", calls)
+	}
+
+	// Only the tool group of the attempt that produced the valid answer is kept
+	var roles []string
+	for _, msg := range memoryStore.GetMessages() {
+		roles = append(roles, msg.Role)
+	}
+	if want := "user assistant tool assistant"; strings.Join(roles, " ") != want {
+		t.Errorf("Expected history roles %q, got %q", want, strings.Join(roles, " "))
+	}
+}
+
+func TestChatService_ProcessChat_StructuredOutputExhausted(t *testing.T) {
+	calls := 0
+	mockClient := &mockGroqClient{
+		chatFunc: func(messages []llm.Message, opts llm.ChatOptions) (*llm.Result, error) {
+			calls++
+			return &llm.Result{Content: "Lyon"}, nil
+		},
+	}
+
+	service := NewChatService(storage.NewMemoryStore(20), nil, mockClient, 1024, WithStructuredOutputRetries(1))
+
+	_, err := service.ProcessChat(context.Background(), &ChatRequest{
+		Messages:       []storage.Message{{Role: "user", Content: "I live in Lyon"}},
+		ResponseFormat: &llm.ResponseFormat{Type: llm.ResponseFormatJSONObject},
+	})
+
+	var appErr *apperror.AppError
+	if !errors.As(err, &appErr) || appErr.Type != apperror.ErrorTypeOutputValidation {
+		t.Fatalf("Expected output validation error, got %v", err)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 LLM calls, got %d", calls)
+	}
+}
+
+func TestChatService_ProcessChatStream_StructuredOutput(t *testing.T) {
+	mockClient := &mockGroqClient{
+		chatFunc: func(messages []llm.Message, opts llm.ChatOptions) (*llm.Result, error) {
+			return &llm.Result{Content: `{"city": "Lyon"}`}, nil
+		},
+		streamChatFunc: func(messages []llm.Message, opts llm.ChatOptions, onToken func(string) error) (*llm.Result, error) {
+			t.Error("Structured output must not use the streaming API")
+			return nil, errors.New("unexpected stream")
+		},
+	}
+
+	service := NewChatService(storage.NewMemoryStore(20), nil, mockClient, 1024)
+
+	var tokens []string
+	_, err := service.ProcessChatStream(context.Background(), &ChatRequest{
+		Messages:       []storage.Message{{Role: "user", Content: "I live in Lyon"}},
+		Stream:         true,
+		ResponseFormat: newLocationFormat(),
+	}, func(token string) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ProcessChatStream() error = %v", err)
+	}
+	if len(tokens) != 1 || tokens[0] != `{"city": "Lyon"}` {
+		t.Errorf("Expected the validated answer as a single token, got %q", tokens)
+	}
+}
+
+func TestChatService_Validate_ResponseFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  *llm.ResponseFormat
+		wantErr bool
+	}{
+		{name: "json object", format: &llm.ResponseFormat{Type: llm.ResponseFormatJSONObject}},
+		{name: "json schema", format: newLocationFormat()},
+		{name: "unknown type", format: &llm.ResponseFormat{Type: "xml"}, wantErr: true},
+		{name: "missing schema", format: &llm.ResponseFormat{Type: llm.ResponseFormatJSONSchema}, wantErr: true},
+		{
+			name: "invalid schema",
+			format: &llm.ResponseFormat{
+				Type:       llm.ResponseFormatJSONSchema,
+				JSONSchema: &llm.JSONSchema{Name: "bad", Schema: json.RawMessage(`{"type": 42}`)},
+			},
+			wantErr: true,
+		},
+		{
+			name: "remote reference",
+			format: &llm.ResponseFormat{
+				Type:       llm.ResponseFormatJSONSchema,
+				JSONSchema: &llm.JSONSchema{Name: "remote", Schema: json.RawMessage(`{"$ref": "http://127.0.0.1/schema.json"}`)},
+			},
+			wantErr: true,
+		},
+	}
+
+	service := NewChatService(storage.NewMemoryStore(20), nil, &mockGroqClient{}, 1024).(*chatService)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := service.validate(&ChatRequest{
+				Messages:       []storage.Message{{Role: "user", Content: "Hello"}},
+				ResponseFormat: tt.format,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"llm-chat-service/internal/llm"
+	"llm-chat-service/internal/storage"
+	"llm-chat-service/internal/tracing"
+
+	"github.com/tiktoken-go/tokenizer/codec"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Encoding identifies how a model splits text into tokens
+type Encoding string
+
+const (
+	EncodingCl100k Encoding = "cl100k_base" // GPT-4, GPT-3.5 and OpenAI embeddings
+	EncodingO200k  Encoding = "o200k_base"  // GPT-4o, GPT-4.1, o-series and gpt-oss
+	EncodingLlama  Encoding = "llama"       // Llama 3 and the other open-weight models, the default
+)
+
+// modelEncodings maps model families, matched on the model name without its "provider/" prefix, to
+// their encoding. Models matching no family use the Llama approximation, as most models served by
+// Groq are open-weight.
+var modelEncodings = map[string]Encoding{
+	"gpt-4o":                 EncodingO200k,
+	"chatgpt-4o":             EncodingO200k,
+	"gpt-4.1":                EncodingO200k,
+	"gpt-4.5":                EncodingO200k,
+	"gpt-5":                  EncodingO200k,
+	"gpt-oss":                EncodingO200k,
+	"o1":                     EncodingO200k,
+	"o3":                     EncodingO200k,
+	"o4":                     EncodingO200k,
+	"gpt-4":                  EncodingCl100k,
+	"gpt-3.5":                EncodingCl100k,
+	"text-embedding-3":       EncodingCl100k,
+	"text-embedding-ada-002": EncodingCl100k,
+}
+
+// messageFormat is the chat template overhead a provider adds around messages, in tokens
+type messageFormat struct {
+	perConversation int  // Tokens opening the prompt
+	perMessage      int  // Special tokens around each message; the role and content are counted as text
+	perName         int  // Tokens added when a message carries a name
+	names           bool // Whether the template renders message names at all
+	perReply        int  // Tokens priming the assistant's reply
+}
+
+var (
+	// openAIFormat: <|start|>{role}<|message|>{content}<|end|> per message, <|start|>assistant<|message|>
+	// priming the reply
+	openAIFormat = messageFormat{perMessage: 3, perName: 1, names: true, perReply: 3}
+
+	// llamaFormat: <|begin_of_text|> once, then <|start_header_id|>{role}<|end_header_id|>\n\n{content}<|eot_id|>
+	// per message and <|start_header_id|>assistant<|end_header_id|>\n\n priming the reply
+	llamaFormat = messageFormat{perConversation: 1, perMessage: 4, perReply: 4}
+)
+
+// encodingSpecs describes how each encoding is counted. The tokenizer library has no Llama 3
+// vocabulary, so Llama is counted with cl100k and flagged inexact. Its vocabulary is larger than
+// cl100k, so the approximation errs on the high side, which is the safe one for checking a prompt fits.
+var encodingSpecs = map[Encoding]struct {
+	format     messageFormat
+	vocabulary Encoding
+	exact      bool
+}{
+	EncodingCl100k: {format: openAIFormat, vocabulary: EncodingCl100k, exact: true},
+	EncodingO200k:  {format: openAIFormat, vocabulary: EncodingO200k, exact: true},
+	EncodingLlama:  {format: llamaFormat, vocabulary: EncodingCl100k, exact: false},
+}
+
+// TokenCount is the size of a prompt for a model
+type TokenCount struct {
+	Model    string   `json:"model"`
+	Encoding Encoding `json:"encoding"`
+
+	// Exact is false when the count is an approximation: the encoding's vocabulary is unavailable or
+	// the messages contain images, which are not counted
+	Exact bool `json:"exact"`
+
+	// PromptTokens is what the model reads, chat template and reply priming included
+	PromptTokens int `json:"prompt_tokens"`
+
+	// Messages holds the tokens of each message, its template overhead included
+	Messages []int `json:"messages"`
+}
+
+// Tokenizer counts prompt tokens the way the model's provider does. It is safe for concurrent use and
+// meant to be created once, rather than building a codec for every count.
+type Tokenizer struct {
+	codecs map[Encoding]*codec.Codec // Keyed by vocabulary
+}
+
+// ------------------------------------------------------------------------------------------------------
+func NewTokenizer() *Tokenizer {
+	return &Tokenizer{codecs: map[Encoding]*codec.Codec{
+		EncodingCl100k: codec.NewCl100kBase(),
+		EncodingO200k:  codec.NewO200kBase(),
+	}}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// EncodingForModel returns the encoding of a model, e.g. "openai/gpt-oss-20b" or "llama-3.1-8b-instant"
+func EncodingForModel(model string) Encoding {
+	name := strings.ToLower(model)
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	// Families match whole dash-separated segments, so "gpt-4o-mini" is not taken for "gpt-4"
+	for prefix := name; prefix != ""; {
+		if encoding, ok := modelEncodings[prefix]; ok {
+			return encoding
+		}
+		i := strings.LastIndex(prefix, "-")
+		if i < 0 {
+			break
+		}
+		prefix = prefix[:i]
+	}
+	return EncodingLlama
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Count returns the prompt size of messages for model
+func (t *Tokenizer) Count(ctx context.Context, model string, messages []storage.Message) (*TokenCount, error) {
+	_, span := tracing.StartSpan(ctx, "Tokenizer.Count",
+		trace.WithAttributes(attribute.Int("messages.count", len(messages))),
+	)
+	defer span.End()
+
+	encoding := EncodingForModel(model)
+	spec := encodingSpecs[encoding]
+	format := spec.format
+	vocabulary := t.codecs[spec.vocabulary]
+
+	result := &TokenCount{
+		Model:        model,
+		Encoding:     encoding,
+		Exact:        spec.exact,
+		PromptTokens: format.perConversation + format.perReply,
+		Messages:     make([]int, len(messages)),
+	}
+
+	for i, msg := range messages {
+		tokens := format.perMessage
+		// Content holds the text of multimodal messages too
+		texts := []string{msg.Role, msg.Content}
+		if msg.HasImages() {
+			result.Exact = false
+		}
+		if msg.Name != "" && format.names {
+			tokens += format.perName
+			texts = append(texts, msg.Name)
+		}
+		for _, call := range msg.ToolCalls {
+			texts = append(texts, call.Name, call.Arguments)
+		}
+
+		for _, text := range texts {
+			n, err := encode(vocabulary, text)
+			if err != nil {
+				tracing.RecordError(span, err)
+				return nil, err
+			}
+			tokens += n
+		}
+		result.Messages[i] = tokens
+		result.PromptTokens += tokens
+	}
+
+	span.SetAttributes(
+		attribute.String("tokens.encoding", string(encoding)),
+		attribute.Int("tokens.count", result.PromptTokens),
+	)
+	return result, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+func encode(vocabulary *codec.Codec, text string) (int, error) {
+	if text == "" {
+		return 0, nil
+	}
+	n, err := vocabulary.Count(text)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode content: %w", err)
+	}
+	return n, nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// CountTokens counts the prompt tokens of messages for model, or for the current model when empty
+func (s *chatService) CountTokens(ctx context.Context, model string, messages []storage.Message) (*TokenCount, error) {
+	if model == "" {
+		model = s.model()
+	}
+	return s.tokenizer.Count(ctx, model, messages)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// model returns the model the LLM client currently uses, or "" if the client does not tell
+func (s *chatService) model() string {
+	if switcher, ok := s.llmClient.(llm.ModelSwitcher); ok {
+		return switcher.Model()
+	}
+	return ""
+}
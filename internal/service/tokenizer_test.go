@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"llm-chat-service/internal/contentpart"
+	"llm-chat-service/internal/storage"
+)
+
+func TestEncodingForModel(t *testing.T) {
+	tests := []struct {
+		model string
+		want  Encoding
+	}{
+		{"gpt-4", EncodingCl100k},
+		{"gpt-4-turbo", EncodingCl100k},
+		{"gpt-3.5-turbo-0125", EncodingCl100k},
+		{"gpt-4o-mini", EncodingO200k},
+		{"GPT-4.1", EncodingO200k},
+		{"o3-mini", EncodingO200k},
+		{"openai/gpt-oss-20b", EncodingO200k},
+		{"llama-3.1-8b-instant", EncodingLlama},
+		{"meta-llama/llama-guard-4-12b", EncodingLlama},
+		{"o1lama", EncodingLlama},
+		{"", EncodingLlama},
+	}
+
+	for _, tt := range tests {
+		if got := EncodingForModel(tt.model); got != tt.want {
+			t.Errorf("EncodingForModel(%q) = %q, want %q", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestTokenizer_Count(t *testing.T) {
+	hello := []storage.Message{{Role: "user", Content: "Hello world"}}
+
+	tests := []struct {
+		name         string
+		model        string
+		messages     []storage.Message
+		wantTokens   int
+		wantMessages []int
+		wantExact    bool
+	}{
+		{
+			// 3 per message + "user" + "Hello world", then 3 priming the reply
+			name:         "openai format",
+			model:        "gpt-4",
+			messages:     hello,
+			wantTokens:   9,
+			wantMessages: []int{6},
+			wantExact:    true,
+		},
+		{
+			// The o200k vocabulary takes 5 tokens for the text, where cl100k takes 13
+			name:         "o200k vocabulary",
+			model:        "gpt-4o",
+			messages:     []storage.Message{{Role: "user", Content: "नमस्ते दुनिया"}},
+			wantTokens:   12,
+			wantMessages: []int{9},
+			wantExact:    true,
+		},
+		{
+			// <|begin_of_text|>, 4 per message + "user" + "Hello world", then 4 priming the reply
+			name:         "llama format",
+			model:        "llama-3.1-8b-instant",
+			messages:     hello,
+			wantTokens:   12,
+			wantMessages: []int{7},
+		},
+		{
+			name:         "openai names",
+			model:        "gpt-4",
+			messages:     []storage.Message{{Role: "user", Name: "bob", Content: "Hello world"}},
+			wantTokens:   11,
+			wantMessages: []int{8},
+			wantExact:    true,
+		},
+		{
+			name:         "llama drops names",
+			model:        "llama-3.1-8b-instant",
+			messages:     []storage.Message{{Role: "user", Name: "bob", Content: "Hello world"}},
+			wantTokens:   12,
+			wantMessages: []int{7},
+		},
+		{
+			name:  "images are not counted",
+			model: "gpt-4",
+			messages: []storage.Message{{Role: "user", Content: "Hello world", Parts: []contentpart.Part{
+				{Type: contentpart.TypeText, Text: "Hello world"},
+				{Type: contentpart.TypeImageURL, ImageURL: &contentpart.ImageURL{URL: "https://example.com/cat.png"}},
+			}}},
+			wantTokens:   9,
+			wantMessages: []int{6},
+		},
+	}
+
+	tokenizer := NewTokenizer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, err := tokenizer.Count(context.Background(), tt.model, tt.messages)
+			if err != nil {
+				t.Fatalf("Count() error = %v", err)
+			}
+			if count.PromptTokens != tt.wantTokens || !reflect.DeepEqual(count.Messages, tt.wantMessages) {
+				t.Errorf("Count() = %d tokens %v, want %d tokens %v", count.PromptTokens, count.Messages, tt.wantTokens, tt.wantMessages)
+			}
+			if count.Exact != tt.wantExact {
+				t.Errorf("Exact = %v, want %v", count.Exact, tt.wantExact)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"llm-chat-service/internal/llm"
+	"llm-chat-service/internal/metrics"
+	"llm-chat-service/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// toolTimeout bounds a single tool execution
+const toolTimeout = 30 * time.Second
+
+// ErrClientToolTimeout is returned by a ClientToolExecutor whose client did not answer in time. The
+// transport cannot take further tool results after that, so the turn ends instead of the failure being
+// reported to the model.
+var ErrClientToolTimeout = errors.New("client did not return a tool result in time")
+
+// ErrClientToolUnavailable is returned by a ClientToolExecutor that can no longer reach its client:
+// the connection failed or closed, or the turn was cancelled. The turn ends rather than calling the
+// model again on behalf of a client that is gone.
+var ErrClientToolUnavailable = errors.New("client is no longer available to run tools")
+
+// ToolFunc executes a tool with the JSON arguments produced by the model and returns the result text
+type ToolFunc func(ctx context.Context, arguments json.RawMessage) (string, error)
+
+type registeredTool struct {
+	definition llm.Tool
+	fn         ToolFunc
+}
+
+// ToolRegistry holds the server-side tools the model may call
+type ToolRegistry struct {
+	mu    sync.RWMutex
+	tools map[string]registeredTool
+}
+
+// ------------------------------------------------------------------------------------------------------
+// NewToolRegistry creates an empty registry
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{
+		tools: make(map[string]registeredTool),
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Register adds a tool. parameters must be a JSON Schema object describing the arguments.
+func (r *ToolRegistry) Register(name, description string, parameters json.RawMessage, fn ToolFunc) error {
+	if name == "" {
+		return fmt.Errorf("tool name cannot be empty")
+	}
+	if fn == nil {
+		return fmt.Errorf("tool %q has no implementation", name)
+	}
+	if len(parameters) > 0 && !json.Valid(parameters) {
+		return fmt.Errorf("tool %q has invalid parameters schema", name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.tools[name]; exists {
+		return fmt.Errorf("tool %q is already registered", name)
+	}
+
+	r.tools[name] = registeredTool{
+		definition: llm.Tool{
+			Type: llm.ToolTypeFunction,
+			Function: llm.FunctionDefinition{
+				Name:        name,
+				Description: description,
+				Parameters:  parameters,
+			},
+		},
+		fn: fn,
+	}
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Definitions returns the tool definitions sorted by name, for a stable upstream request
+func (r *ToolRegistry) Definitions() []llm.Tool {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	definitions := make([]llm.Tool, 0, len(r.tools))
+	for _, tool := range r.tools {
+		definitions = append(definitions, tool.definition)
+	}
+	sort.Slice(definitions, func(i, j int) bool {
+		return definitions[i].Function.Name < definitions[j].Function.Name
+	})
+	return definitions
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Has reports whether a tool is registered under name
+func (r *ToolRegistry) Has(name string) bool {
+	if r == nil {
+		return false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.tools[name]
+	return ok
+}
+
+// ------------------------------------------------------------------------------------------------------
+// Execute runs the tool named in call
+func (r *ToolRegistry) Execute(ctx context.Context, call llm.ToolCall) (_ string, err error) {
+	ctx, span := tracing.StartSpan(ctx, "ToolRegistry.Execute", trace.WithAttributes(
+		attribute.String("tool.name", call.Function.Name),
+	))
+	defer func() {
+		outcome := "success"
+		if err != nil {
+			outcome = "error"
+		}
+		metrics.ToolCallsTotal.WithLabelValues(call.Function.Name, outcome).Inc()
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	if r == nil {
+		return "", fmt.Errorf("unknown tool %q", call.Function.Name)
+	}
+
+	r.mu.RLock()
+	tool, ok := r.tools[call.Function.Name]
+	r.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("unknown tool %q", call.Function.Name)
+	}
+
+	arguments := json.RawMessage(call.Function.Arguments)
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+	if !json.Valid(arguments) {
+		return "", fmt.Errorf("invalid JSON arguments for tool %q", call.Function.Name)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, toolTimeout)
+	defer cancel()
+
+	return tool.fn(ctx, arguments)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// toolErrorContent formats a tool failure so the model can see what went wrong and recover
+func toolErrorContent(err error) string {
+	data, _ := json.Marshal(map[string]string{"error": err.Error()})
+	return string(data)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// executeClientTool hands a call to the transport-provided executor
+func executeClientTool(ctx context.Context, executor ClientToolExecutor, call llm.ToolCall) (_ string, err error) {
+	ctx, span := tracing.StartSpan(ctx, "ClientTool.Execute", trace.WithAttributes(
+		attribute.String("tool.name", call.Function.Name),
+	))
+	defer func() {
+		outcome := "success"
+		if err != nil {
+			outcome = "error"
+		}
+		metrics.ToolCallsTotal.WithLabelValues(call.Function.Name, outcome).Inc()
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	return executor(ctx, call)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// hasClientTool reports whether the client declared a tool named name and can execute it
+func (r *ChatRequest) hasClientTool(name string) bool {
+	if r.ClientToolExecutor == nil {
+		return false
+	}
+	for _, tool := range r.Tools {
+		if tool.Function.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Built-in tool names
+const (
+	ToolCurrentTime = "current_time"
+)
+
+// ------------------------------------------------------------------------------------------------------
+// RegisterBuiltinTools registers the named built-in tools
+func RegisterBuiltinTools(registry *ToolRegistry, names []string) error {
+	for _, name := range names {
+		var err error
+		switch name {
+		case ToolCurrentTime:
+			err = registry.Register(ToolCurrentTime,
+				"Returns the current date and time, optionally in a given IANA time zone",
+				json.RawMessage(`{"type":"object","properties":{"timezone":{"type":"string","description":"IANA time zone, e.g. Europe/Berlin. Defaults to UTC."}}}`),
+				currentTimeTool,
+			)
+		default:
+			err = fmt.Errorf("unknown built-in tool %q", name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+func currentTimeTool(ctx context.Context, arguments json.RawMessage) (string, error) {
+	var args struct {
+		Timezone string `json:"timezone"`
+	}
+	if err := json.Unmarshal(arguments, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	location := time.UTC
+	if args.Timezone != "" {
+		loc, err := time.LoadLocation(args.Timezone)
+		if err != nil {
+			return "", fmt.Errorf("unknown time zone %q", args.Timezone)
+		}
+		location = loc
+	}
+
+	return time.Now().In(location).Format(time.RFC3339), nil
+}
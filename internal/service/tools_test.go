@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"llm-chat-service/internal/llm"
+	"llm-chat-service/internal/storage"
+	"testing"
+)
+
+func TestChatService_ProcessChat_ToolLoop(t *testing.T) {
+	registry := NewToolRegistry()
+	err := registry.Register("add", "Adds two numbers",
+		json.RawMessage(`{"type":"object","properties":{"a":{"type":"number"},"b":{"type":"number"}}}`),
+		func(ctx context.Context, arguments json.RawMessage) (string, error) {
+			var args struct{ A, B float64 }
+			if err := json.Unmarshal(arguments, &args); err != nil {
+				return "", err
+			}
+			data, _ := json.Marshal(args.A + args.B)
+			return string(data), nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	calls := 0
+	mockClient := &mockGroqClient{
+		chatFunc: func(messages []llm.Message, opts llm.ChatOptions) (*llm.Result, error) {
+			calls++
+			if len(opts.Tools) != 1 || opts.Tools[0].Function.Name != "add" {
+				t.Errorf("Expected the add tool to be offered, got %+v", opts.Tools)
+			}
+			if calls == 1 {
+				return &llm.Result{ToolCalls: []llm.ToolCall{{
+					ID:       "call_1",
+					Type:     llm.ToolTypeFunction,
+					Function: llm.FunctionCall{Name: "add", Arguments: `{"a":2,"b":3}`},
+				}}}, nil
+			}
+			last := messages[len(messages)-1]
+			if last.Role != "tool" || last.ToolCallID != "call_1" || last.Content != "5" {
+				t.Errorf("Expected tool result message, got %+v", last)
+			}
+			return &llm.Result{Content: "2 + 3 = 5"}, nil
+		},
+	}
+
+	memoryStore := storage.NewMemoryStore(20)
+	service := NewChatService(memoryStore, nil, mockClient, 1024, WithTools(registry))
+
+	response, err := service.ProcessChat(context.Background(), &ChatRequest{
+		Messages: []storage.Message{{Role: "user", Content: "What is 2 + 3?"}},
+	})
+	if err != nil {
+		t.Fatalf("ProcessChat() error = %v", err)
+	}
+	if response.Content != "2 + 3 = 5" {
+		t.Errorf("ProcessChat() response = %q, want '2 + 3 = 5'", response.Content)
+	}
+
+	roles := []string{}
+	for _, msg := range memoryStore.GetMessages() {
+		roles = append(roles, msg.Role)
+	}
+	want := []string{"user", "assistant", "tool", "assistant"}
+	if len(roles) != len(want) {
+		t.Fatalf("Expected history roles %v, got %v", want, roles)
+	}
+	for i := range want {
+		if roles[i] != want[i] {
+			t.Errorf("Expected history roles %v, got %v", want, roles)
+			break
+		}
+	}
+}
+
+func TestChatService_ProcessChat_ToolIterationLimit(t *testing.T) {
+	mockClient := &mockGroqClient{
+		chatFunc: func(messages []llm.Message, opts llm.ChatOptions) (*llm.Result, error) {
+			return &llm.Result{ToolCalls: []llm.ToolCall{{
+				ID:       "call",
+				Function: llm.FunctionCall{Name: "missing"},
+			}}}, nil
+		},
+	}
+
+	memoryStore := storage.NewMemoryStore(20)
+	service := NewChatService(memoryStore, nil, mockClient, 1024, WithMaxToolIterations(2))
+
+	_, err := service.ProcessChat(context.Background(), &ChatRequest{
+		Messages: []storage.Message{{Role: "user", Content: "Loop forever"}},
+	})
+	if err == nil {
+		t.Error("Expected error when the tool iteration limit is exceeded")
+	}
+
+	// The tool calls of a failed turn are not kept in history
+	if history := memoryStore.GetMessages(); len(history) != 1 {
+		t.Errorf("Expected only the user message in history, got %+v", history)
+	}
+}
+
+func TestChatService_ProcessChatStream_ClientTool(t *testing.T) {
+	calls := 0
+	mockClient := &mockGroqClient{
+		streamChatFunc: func(messages []llm.Message, opts llm.ChatOptions, onToken func(string) error) (*llm.Result, error) {
+			calls++
+			if calls == 1 {
+				return &llm.Result{ToolCalls: []llm.ToolCall{{
+					ID:       "call_1",
+					Type:     llm.ToolTypeFunction,
+					Function: llm.FunctionCall{Name: "read_page", Arguments: `{}`},
+				}}}, nil
+			}
+			_ = onToken("The page says hi")
+			return &llm.Result{Content: "The page says hi"}, nil
+		},
+	}
+
+	service := NewChatService(storage.NewMemoryStore(20), nil, mockClient, 1024)
+
+	var executed []string
+	req := &ChatRequest{
+		Messages: []storage.Message{{Role: "user", Content: "Read the page"}},
+		Stream:   true,
+		Tools: []llm.Tool{{
+			Type:     llm.ToolTypeFunction,
+			Function: llm.FunctionDefinition{Name: "read_page"},
+		}},
+		ClientToolExecutor: func(ctx context.Context, call llm.ToolCall) (string, error) {
+			executed = append(executed, call.Function.Name)
+			return "hi", nil
+		},
+	}
+
+	response, err := service.ProcessChatStream(context.Background(), req, func(string) error { return nil })
+	if err != nil {
+		t.Fatalf("ProcessChatStream() error = %v", err)
+	}
+	if response.Content != "The page says hi" {
+		t.Errorf("ProcessChatStream() response = %q", response.Content)
+	}
+	if len(executed) != 1 || executed[0] != "read_page" {
+		t.Errorf("Expected read_page to be executed on the client, got %v", executed)
+	}
+}
+
+func TestChatRequest_Validate_ClientToolsRequireExecutor(t *testing.T) {
+	req := &ChatRequest{
+		Messages: []storage.Message{{Role: "user", Content: "Hello"}},
+		Tools: []llm.Tool{{
+			Type:     llm.ToolTypeFunction,
+			Function: llm.FunctionDefinition{Name: "read_page"},
+		}},
+	}
+	if err := req.Validate(); err == nil {
+		t.Error("Expected validation error for client tools without an executor")
+	}
+}
@@ -1,11 +1,33 @@
 package service
 
 import (
+	"encoding/base64"
 	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
 
+	"llm-chat-service/internal/contentpart"
 	apperror "llm-chat-service/internal/error"
+	"llm-chat-service/internal/llm"
+	"llm-chat-service/internal/rag"
+	"llm-chat-service/internal/storage"
 )
 
+// Limits on images in multimodal messages
+const (
+	maxImagesPerRequest = 5
+	maxImageBytes       = 4 << 20 // Decoded size of a base64 data URI image
+)
+
+// allowedImageTypes are the MIME types accepted in base64 data URI images
+var allowedImageTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
 // ------------------------------------------------------------------------------------------------------
 func (r *ChatRequest) Validate() error {
 	if len(r.Messages) == 0 {
@@ -13,6 +35,7 @@ func (r *ChatRequest) Validate() error {
 	}
 
 	// Validate each message
+	images := 0
 	for i, msg := range r.Messages {
 		if msg.Role != "user" && msg.Role != "assistant" {
 			return apperror.NewValidationError(
@@ -20,7 +43,12 @@ func (r *ChatRequest) Validate() error {
 				nil,
 			)
 		}
-		if msg.Content == "" {
+		if len(msg.Parts) > 0 {
+			if err := validateContentParts(i, msg); err != nil {
+				return err
+			}
+			images += countImages(msg.Parts)
+		} else if msg.Content == "" {
 			return apperror.NewValidationError(
 				fmt.Sprintf("empty content at index %d", i),
 				nil,
@@ -28,6 +56,13 @@ func (r *ChatRequest) Validate() error {
 		}
 	}
 
+	if images > maxImagesPerRequest {
+		return apperror.NewValidationError(
+			fmt.Sprintf("too many images: %d, at most %d are allowed per request", images, maxImagesPerRequest),
+			nil,
+		)
+	}
+
 	// Last message must be from user
 	lastMsg := r.Messages[len(r.Messages)-1]
 	if lastMsg.Role != "user" {
@@ -37,5 +72,174 @@ func (r *ChatRequest) Validate() error {
 		)
 	}
 
+	if err := r.validateTools(); err != nil {
+		return err
+	}
+
+	if err := r.validateCandidates(); err != nil {
+		return err
+	}
+
+	if r.KnowledgeBase != "" {
+		if err := rag.ValidateName("knowledge_base", r.KnowledgeBase); err != nil {
+			return apperror.NewValidationError(err.Error(), nil)
+		}
+	}
+
+	return r.validateResponseFormat()
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (r *ChatRequest) validateTools() error {
+	if len(r.Tools) == 0 {
+		return nil
+	}
+
+	if r.ClientToolExecutor == nil {
+		return apperror.NewValidationError("client-declared tools are only supported over WebSocket", nil)
+	}
+
+	seen := make(map[string]bool, len(r.Tools))
+	for i, tool := range r.Tools {
+		if tool.Type != llm.ToolTypeFunction {
+			return apperror.NewValidationError(
+				fmt.Sprintf("invalid tool type '%s' at index %d: must be 'function'", tool.Type, i),
+				nil,
+			)
+		}
+		if tool.Function.Name == "" {
+			return apperror.NewValidationError(fmt.Sprintf("empty tool name at index %d", i), nil)
+		}
+		if seen[tool.Function.Name] {
+			return apperror.NewValidationError(fmt.Sprintf("duplicate tool name '%s'", tool.Function.Name), nil)
+		}
+		seen[tool.Function.Name] = true
+	}
+
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (r *ChatRequest) validateCandidates() error {
+	if r.N < 0 {
+		return apperror.NewValidationError(fmt.Sprintf("n must be positive, got %d", r.N), nil)
+	}
+	if index := r.persistIndex(); index < 0 || index >= r.candidates() {
+		return apperror.NewValidationError(
+			fmt.Sprintf("persist_index must be between 0 and %d, got %d", r.candidates()-1, index),
+			nil,
+		)
+	}
+	if r.candidates() > 1 && len(r.Tools) > 0 {
+		return apperror.NewValidationError("tools cannot be combined with n > 1", nil)
+	}
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+func (r *ChatRequest) validateResponseFormat() error {
+	if r.ResponseFormat == nil {
+		return nil
+	}
+
+	switch r.ResponseFormat.Type {
+	case llm.ResponseFormatJSONObject:
+		return nil
+	case llm.ResponseFormatJSONSchema:
+		schema := r.ResponseFormat.JSONSchema
+		if schema == nil || len(schema.Schema) == 0 {
+			return apperror.NewValidationError("response_format.json_schema.schema is required for type 'json_schema'", nil)
+		}
+		if schema.Name == "" {
+			return apperror.NewValidationError("response_format.json_schema.name is required", nil)
+		}
+		return nil
+	default:
+		return apperror.NewValidationError(
+			fmt.Sprintf("invalid response_format type '%s': must be 'json_object' or 'json_schema'", r.ResponseFormat.Type),
+			nil,
+		)
+	}
+}
+
+// ------------------------------------------------------------------------------------------------------
+// validateContentParts checks the parts of the multimodal message at index i
+func validateContentParts(i int, msg storage.Message) error {
+	for j, part := range msg.Parts {
+		switch part.Type {
+		case contentpart.TypeText:
+			if part.Text == "" {
+				return apperror.NewValidationError(fmt.Sprintf("empty text part %d at index %d", j, i), nil)
+			}
+		case contentpart.TypeImageURL:
+			if msg.Role != "user" {
+				return apperror.NewValidationError(fmt.Sprintf("image part at index %d: only user messages may contain images", i), nil)
+			}
+			if part.ImageURL == nil || part.ImageURL.URL == "" {
+				return apperror.NewValidationError(fmt.Sprintf("image part %d at index %d has no url", j, i), nil)
+			}
+			if err := validateImageURL(part.ImageURL.URL); err != nil {
+				return apperror.NewValidationError(fmt.Sprintf("invalid image part %d at index %d: %v", j, i, err), nil)
+			}
+		default:
+			return apperror.NewValidationError(
+				fmt.Sprintf("invalid content part type '%s' at index %d: must be 'text' or 'image_url'", part.Type, i),
+				nil,
+			)
+		}
+	}
+
+	if msg.Content == "" && !msg.HasImages() {
+		return apperror.NewValidationError(fmt.Sprintf("empty content at index %d", i), nil)
+	}
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// validateImageURL accepts http(s) URLs and base64 data URIs of an allowed type and size. The
+// declared type of a data URI must match the decoded bytes.
+func validateImageURL(rawURL string) error {
+	if !strings.HasPrefix(rawURL, "data:") {
+		parsed, err := url.Parse(rawURL)
+		if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			return fmt.Errorf("url must be an http(s) URL or a base64 data URI")
+		}
+		return nil
+	}
+
+	header, payload, found := strings.Cut(strings.TrimPrefix(rawURL, "data:"), ",")
+	mimeType, encoding, _ := strings.Cut(header, ";")
+	if !found || encoding != "base64" {
+		return fmt.Errorf("data URI must be base64 encoded")
+	}
+	if !allowedImageTypes[mimeType] {
+		return fmt.Errorf("unsupported image type '%s'", mimeType)
+	}
+	// Reject oversized payloads before decoding; padding makes DecodedLen overshoot by up to 2 bytes
+	if base64.StdEncoding.DecodedLen(len(payload)) > maxImageBytes+2 {
+		return fmt.Errorf("image exceeds %d MB", maxImageBytes>>20)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return fmt.Errorf("invalid base64 image data")
+	}
+	if len(data) > maxImageBytes {
+		return fmt.Errorf("image exceeds %d MB", maxImageBytes>>20)
+	}
+	if detected := http.DetectContentType(data); detected != mimeType {
+		return fmt.Errorf("image data is '%s', not '%s'", detected, mimeType)
+	}
 	return nil
 }
+
+// ------------------------------------------------------------------------------------------------------
+func countImages(parts []contentpart.Part) int {
+	count := 0
+	for _, part := range parts {
+		if part.Type == contentpart.TypeImageURL {
+			count++
+		}
+	}
+	return count
+}
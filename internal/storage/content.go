@@ -0,0 +1,30 @@
+package storage
+
+import "llm-chat-service/internal/contentpart"
+
+// ------------------------------------------------------------------------------------------------------
+// MarshalJSON writes content as a string, or as an array of parts for multimodal messages
+func (m Message) MarshalJSON() ([]byte, error) {
+	type plain Message
+	return contentpart.MarshalContent(plain(m), m.Parts)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// UnmarshalJSON accepts content as a string, null, or an array of parts (see contentpart.Decode)
+func (m *Message) UnmarshalJSON(data []byte) error {
+	type plain Message
+	var decoded plain
+	content, parts, err := contentpart.UnmarshalContent(data, &decoded)
+	if err != nil {
+		return err
+	}
+	*m = Message(decoded)
+	m.Content, m.Parts = content, parts
+	return nil
+}
+
+// ------------------------------------------------------------------------------------------------------
+// HasImages reports whether the message contains image parts
+func (m Message) HasImages() bool {
+	return contentpart.HasImages(m.Parts)
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"encoding/json"
+	"testing"
+
+	"llm-chat-service/internal/contentpart"
+)
+
+func TestMessage_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		content   string
+		parts     int
+		hasImages bool
+		wantErr   bool
+	}{
+		{name: "plain string", input: `{"role":"user","content":"Hello"}`, content: "Hello"},
+		{name: "null content", input: `{"role":"assistant","content":null}`},
+		{
+			name:      "content parts",
+			input:     `{"role":"user","content":[{"type":"text","text":"What is this?"},{"type":"image_url","image_url":{"url":"https://example.com/cat.png"}}]}`,
+			content:   "What is this?",
+			parts:     2,
+			hasImages: true,
+		},
+		{name: "invalid content", input: `{"role":"user","content":42}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var msg Message
+			err := json.Unmarshal([]byte(tt.input), &msg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if msg.Role == "" {
+				t.Error("Expected role to be decoded")
+			}
+			if msg.Content != tt.content {
+				t.Errorf("Content = %q, want %q", msg.Content, tt.content)
+			}
+			if len(msg.Parts) != tt.parts {
+				t.Errorf("Expected %d parts, got %d", tt.parts, len(msg.Parts))
+			}
+			if msg.HasImages() != tt.hasImages {
+				t.Errorf("HasImages() = %v, want %v", msg.HasImages(), tt.hasImages)
+			}
+		})
+	}
+}
+
+func TestMessage_MarshalJSONRoundTrip(t *testing.T) {
+	plain, err := json.Marshal(Message{Role: "user", Content: "Hello"})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(plain) != `{"role":"user","content":"Hello"}` {
+		t.Errorf("Plain message encoded as %s", plain)
+	}
+
+	original := Message{
+		Role:    "user",
+		Content: "Describe",
+		Parts: []contentpart.Part{
+			{Type: contentpart.TypeText, Text: "Describe"},
+			{Type: contentpart.TypeImageURL, ImageURL: &contentpart.ImageURL{URL: "https://example.com/a.png", Detail: "low"}},
+		},
+	}
+	data, err := json.Marshal(original)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var decoded Message
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if decoded.Content != original.Content || len(decoded.Parts) != 2 || decoded.Parts[1].ImageURL.Detail != "low" {
+		t.Errorf("Round trip changed the message: %+v", decoded)
+	}
+}
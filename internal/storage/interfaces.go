@@ -1,6 +1,9 @@
 package storage
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // MessageStore defines the interface for storing conversation messages
 type MessageStore interface {
@@ -9,10 +12,20 @@ type MessageStore interface {
 	Clear()
 }
 
-// CacheStore defines the interface for caching operations
+// ExchangeLimiter is implemented by stores whose history limit can change while they serve requests
+type ExchangeLimiter interface {
+	SetMaxExchanges(maxExchanges int)
+}
+
+// CacheStore defines the interface for caching operations. Token counts depend on the encoding they
+// were computed with, which is part of the key.
 type CacheStore interface {
-	GetTokenCount(messages []Message) (int, bool, error)
-	SetTokenCount(messages []Message, count int, ttl time.Duration) error
-	CountTokens(messages []Message) (int, error)
+	GetTokenCount(ctx context.Context, encoding string, messages []Message) (int, bool, error)
+	SetTokenCount(ctx context.Context, encoding string, messages []Message, count int, ttl time.Duration) error
 	Close() error
 }
+
+// Pinger is implemented by stores that can report whether they are available
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
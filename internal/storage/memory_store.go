@@ -1,13 +1,28 @@
 package storage
 
 import (
+	"context"
 	"sync"
+
+	"llm-chat-service/internal/contentpart"
 )
 
-// Message represents a chat message
+// Message represents a chat message. Content holds the text; multimodal messages additionally carry
+// their parts, which replace Content in the JSON form (see content.go).
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string             `json:"role"`
+	Content    string             `json:"content"`
+	Parts      []contentpart.Part `json:"-"`
+	ToolCalls  []ToolCall         `json:"tool_calls,omitempty"`
+	ToolCallID string             `json:"tool_call_id,omitempty"`
+	Name       string             `json:"name,omitempty"`
+}
+
+// ToolCall records a function call requested by the assistant
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
 }
 
 type MemoryStore struct {
@@ -34,6 +49,16 @@ func (s *MemoryStore) AddMessage(msg Message) {
 	s.trimToMaxExchanges()
 }
 
+// ------------------------------------------------------------------------------------------------------
+// SetMaxExchanges changes how many exchanges are kept, trimming the history at once when it shrinks
+func (s *MemoryStore) SetMaxExchanges(maxExchanges int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.maxExchanges = maxExchanges
+	s.trimToMaxExchanges()
+}
+
 // ------------------------------------------------------------------------------------------------------
 func (s *MemoryStore) GetMessages() []Message {
 	s.mu.RLock()
@@ -105,3 +130,12 @@ func (s *MemoryStore) Clear() {
 	defer s.mu.Unlock()
 	s.messages = make([]Message, 0)
 }
+
+// ------------------------------------------------------------------------------------------------------
+// Ping reports the store as available once its lock can be taken, so a stuck writer shows up in
+// readiness checks
+func (s *MemoryStore) Ping(ctx context.Context) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return nil
+}
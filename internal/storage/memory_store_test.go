@@ -35,6 +35,27 @@ func TestMemoryStore_TrimToMaxExchanges(t *testing.T) {
 	}
 }
 
+func TestMemoryStore_SetMaxExchanges(t *testing.T) {
+	store := NewMemoryStore(3)
+	for i := 0; i < 3; i++ {
+		store.AddMessage(Message{Role: "user", Content: "Question"})
+		store.AddMessage(Message{Role: "assistant", Content: "Answer"})
+	}
+
+	// Shrinking trims the history at once
+	store.SetMaxExchanges(1)
+	if messages := store.GetMessages(); len(messages) != 2 {
+		t.Errorf("Expected 2 messages, got %d", len(messages))
+	}
+
+	store.SetMaxExchanges(2)
+	store.AddMessage(Message{Role: "user", Content: "Question"})
+	store.AddMessage(Message{Role: "assistant", Content: "Answer"})
+	if messages := store.GetMessages(); len(messages) != 4 {
+		t.Errorf("Expected 4 messages, got %d", len(messages))
+	}
+}
+
 func TestMemoryStore_Concurrency(t *testing.T) {
 	store := NewMemoryStore(20)
 
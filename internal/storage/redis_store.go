@@ -8,101 +8,96 @@ import (
 	"fmt"
 	"time"
 
+	"llm-chat-service/internal/redisconn"
+	"llm-chat-service/internal/tracing"
+
 	"github.com/redis/go-redis/v9"
-	"github.com/tiktoken-go/tokenizer"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // RedisStore manages Redis connection for token cache
 type RedisStore struct {
-	client *redis.Client
-	ctx    context.Context
+	client *redisconn.Client
 }
 
-// NewRedisStore creates a new Redis store
-func NewRedisStore(addr, password string) (*RedisStore, error) {
-	rdb := redis.NewClient(&redis.Options{
-		Addr:     addr,
-		Password: password,
-		DB:       0,
-	})
-
-	ctx := context.Background()
-
-	// Test connection
-	if err := rdb.Ping(ctx).Err(); err != nil {
-		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
-	}
-
+// NewRedisStore creates a new Redis store. Lookups fail fast while the client reconnects.
+func NewRedisStore(client *redisconn.Client) *RedisStore {
 	return &RedisStore{
-		client: rdb,
-		ctx:    ctx,
-	}, nil
+		client: client,
+	}
 }
 
 func (r *RedisStore) Close() error {
 	return r.client.Close()
 }
 
+// ------------------------------------------------------------------------------------------------------
+// Ping checks that Redis answers, even while the client is reconnecting
+func (r *RedisStore) Ping(ctx context.Context) error {
+	return r.client.Check(ctx)
+}
+
 // GetTokenCount retrieves cached token count for messages
-func (r *RedisStore) GetTokenCount(messages []Message) (int, bool, error) {
-	key := r.getCacheKey(messages)
+func (r *RedisStore) GetTokenCount(ctx context.Context, encoding string, messages []Message) (int, bool, error) {
+	ctx, span := startRedisSpan(ctx, "CacheStore.GetTokenCount", "GET")
+	defer span.End()
 
-	val, err := r.client.Get(r.ctx, key).Result()
+	key := r.getCacheKey(encoding, messages)
+
+	val, err := r.client.Get(ctx, key).Result()
 	if err == redis.Nil {
+		span.SetAttributes(attribute.Bool("cache.hit", false))
 		return 0, false, nil
 	}
 	if err != nil {
+		tracing.RecordError(span, err)
 		return 0, false, err
 	}
 
 	var count int
 	if err := json.Unmarshal([]byte(val), &count); err != nil {
+		tracing.RecordError(span, err)
 		return 0, false, err
 	}
 
+	span.SetAttributes(attribute.Bool("cache.hit", true))
 	return count, true, nil
 }
 
 // SetTokenCount caches token count for messages
-func (r *RedisStore) SetTokenCount(messages []Message, count int, ttl time.Duration) error {
-	key := r.getCacheKey(messages)
+func (r *RedisStore) SetTokenCount(ctx context.Context, encoding string, messages []Message, count int, ttl time.Duration) error {
+	ctx, span := startRedisSpan(ctx, "CacheStore.SetTokenCount", "SET")
+	defer span.End()
+
+	key := r.getCacheKey(encoding, messages)
 
 	data, err := json.Marshal(count)
 	if err != nil {
+		tracing.RecordError(span, err)
 		return err
 	}
 
-	return r.client.Set(r.ctx, key, data, ttl).Err()
+	err = r.client.Set(ctx, key, data, ttl).Err()
+	tracing.RecordError(span, err)
+	return err
 }
 
-// CountTokens counts tokens in messages using tiktoken
-func (r *RedisStore) CountTokens(messages []Message) (int, error) {
-	// Use cl100k_base encoding (used by GPT models)
-	enc, err := tokenizer.Get(tokenizer.Cl100kBase)
-	if err != nil {
-		return 0, fmt.Errorf("failed to get tokenizer: %w", err)
-	}
-
-	totalTokens := 0
-	for _, msg := range messages {
-		// Count tokens in content
-		tokens, _, err := enc.Encode(msg.Content)
-		if err != nil {
-			return 0, fmt.Errorf("failed to encode content: %w", err)
-		}
-		totalTokens += len(tokens)
-
-		// Add overhead for role and structure (approximate)
-		totalTokens += 4
-	}
-
-	return totalTokens, nil
+// startRedisSpan starts a client span describing a single Redis command
+func startRedisSpan(ctx context.Context, name, command string) (context.Context, trace.Span) {
+	return tracing.StartSpan(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "redis"),
+			attribute.String("db.operation", command),
+		),
+	)
 }
 
-// getCacheKey generates a cache key from messages
-func (r *RedisStore) getCacheKey(messages []Message) string {
+// getCacheKey generates a cache key from the encoding and messages
+func (r *RedisStore) getCacheKey(encoding string, messages []Message) string {
 	// Create a hash of the messages for the cache key
 	data, _ := json.Marshal(messages)
 	hash := sha256.Sum256(data)
-	return fmt.Sprintf("token_count:%s", hex.EncodeToString(hash[:]))
+	return fmt.Sprintf("token_count:%s:%s", encoding, hex.EncodeToString(hash[:]))
 }
@@ -1,23 +1,127 @@
-//go:build integration
-// +build integration
-
 package tests
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
+
+	"llm-chat-service/internal/api/handlers"
+	"llm-chat-service/internal/config"
+	"llm-chat-service/internal/health"
+	"llm-chat-service/internal/llm/fakellm"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gorilla/websocket"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+	"go.uber.org/zap"
 )
 
-const baseURL = "http://localhost:8000"
+// testServer is the service booted in-process against a fake LLM and an in-memory Redis
+type testServer struct {
+	*httptest.Server
+	llm    *fakellm.Server
+	redis  *miniredis.Miniredis
+	health *health.Health
+}
+
+// ------------------------------------------------------------------------------------------------------
+// startServer builds the service through the config constructors, as cmd/main.go does. env overrides
+// the default settings; the LLM answers the scripted responses.
+func startServer(t *testing.T, env map[string]string, responses ...fakellm.Response) *testServer {
+	t.Helper()
+
+	upstream := fakellm.NewServer(responses...)
+	t.Cleanup(upstream.Close)
+	redis := miniredis.RunT(t)
+
+	t.Setenv("GROQ_API_KEY", "test-key")
+	t.Setenv("GROQ_BASE_URL", upstream.CompletionsURL())
+	t.Setenv("REDIS_ADDR", redis.Addr())
+	t.Setenv("MODEL", "llama-test")
+	for key, value := range env {
+		t.Setenv(key, value)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("config.Load() error = %v", err)
+	}
+
+	logger := zap.NewNop()
+	chatService, cacheStore, err := cfg.NewChatService(logger)
+	if err != nil {
+		t.Fatalf("NewChatService() error = %v", err)
+	}
+	if cacheStore == nil {
+		t.Fatal("expected a Redis cache")
+	}
+	t.Cleanup(func() { cacheStore.Close() })
+
+	checks := cfg.NewHealth(chatService, cacheStore)
+	router := cfg.NewRouter(cfg.NewHandler(chatService, logger, handlers.WithHealth(checks)), logger)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return &testServer{Server: server, llm: upstream, redis: redis, health: checks}
+}
+
+// ------------------------------------------------------------------------------------------------------
+func chatBody(content string) io.Reader {
+	data, _ := json.Marshal(map[string]any{
+		"messages": []map[string]string{{"role": "user", "content": content}},
+	})
+	return bytes.NewReader(data)
+}
+
+// ------------------------------------------------------------------------------------------------------
+// sseEvent is a server-sent event; Event is empty for unnamed (token) events
+type sseEvent struct {
+	Event string
+	Data  string
+}
+
+// ------------------------------------------------------------------------------------------------------
+func readSSE(t *testing.T, body io.Reader) []sseEvent {
+	t.Helper()
+
+	var events []sseEvent
+	var current sseEvent
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if current.Data != "" || current.Event != "" {
+				events = append(events, current)
+			}
+			current = sseEvent{}
+		case strings.HasPrefix(line, "event: "):
+			current.Event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			current.Data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("failed to read SSE stream: %v", err)
+	}
+	return events
+}
 
 func TestHealthEndpoint(t *testing.T) {
-	resp, err := http.Get(baseURL + "/health")
+	server := startServer(t, nil)
+
+	resp, err := http.Get(server.URL + "/health")
 	if err != nil {
 		t.Fatalf("Failed to call health endpoint: %v", err)
 	}
@@ -27,34 +131,80 @@ func TestHealthEndpoint(t *testing.T) {
 		t.Errorf("Expected status 200, got %d", resp.StatusCode)
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		t.Fatalf("Failed to read response: %v", err)
+	// The handler JSON-encodes the status, so the body is "OK" with quotes
+	var status string
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
 	}
-
-	if string(body) != "OK" {
-		t.Errorf("Expected 'OK', got '%s'", string(body))
+	if status != "OK" {
+		t.Errorf("Expected 'OK', got '%s'", status)
 	}
 }
 
-func TestChatEndpoint_NonStreaming(t *testing.T) {
-	if os.Getenv("GROQ_API_KEY") == "" {
-		t.Skip("Skipping integration test: GROQ_API_KEY not set")
+func TestReadinessEndpoints(t *testing.T) {
+	server := startServer(t, map[string]string{"HEALTH_LLM_PROBE_CACHE": "0s"})
+
+	check := func(path string, wantStatus int) health.Report {
+		t.Helper()
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s error = %v", path, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != wantStatus {
+			t.Errorf("GET %s status = %d, want %d", path, resp.StatusCode, wantStatus)
+		}
+		var report health.Report
+		if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+			t.Fatalf("failed to decode %s: %v", path, err)
+		}
+		return report
+	}
+
+	report := check("/readyz", http.StatusOK)
+	if report.Status != health.StatusUp {
+		t.Errorf("ready status = %q, want up: %+v", report.Status, report)
+	}
+	for _, name := range []string{"store", "llm", "redis"} {
+		if report.Components[name].Status != health.StatusUp {
+			t.Errorf("component %s = %+v, want up", name, report.Components[name])
+		}
 	}
 
-	reqBody := map[string]interface{}{
-		"messages": []map[string]string{
-			{"role": "user", "content": "Say 'Hello, World!' and nothing else."},
-		},
-		"stream": false,
+	// The cache is optional: losing Redis degrades the service without taking it out of rotation
+	server.redis.SetError("LOADING Redis is loading the dataset in memory")
+	report = check("/readyz", http.StatusOK)
+	if report.Status != health.StatusDegraded || report.Components["redis"].Error == "" {
+		t.Errorf("without Redis got %+v, want degraded with a redis error", report)
 	}
+	server.redis.SetError("")
 
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		t.Fatalf("Failed to marshal request: %v", err)
+	// Rejected credentials make the service unready
+	server.llm.SetModelsStatus(http.StatusUnauthorized)
+	report = check("/readyz", http.StatusServiceUnavailable)
+	if report.Status != health.StatusDown || report.Components["llm"].Error != "LLM API rejected the credentials" {
+		t.Errorf("with a bad key got %+v, want down with the llm error", report)
+	}
+	server.llm.SetModelsStatus(http.StatusOK)
+
+	// Shutting down flips readiness while liveness stays up
+	server.health.SetShuttingDown()
+	if report = check("/readyz", http.StatusServiceUnavailable); !report.ShuttingDown {
+		t.Errorf("report = %+v, want shutting_down", report)
 	}
+	if report = check("/livez", http.StatusOK); report.Status != health.StatusUp {
+		t.Errorf("livez status = %q, want up", report.Status)
+	}
+}
 
-	resp, err := http.Post(baseURL+"/chat", "application/json", bytes.NewBuffer(jsonData))
+func TestChatEndpoint_JSON(t *testing.T) {
+	server := startServer(t, nil, fakellm.Response{
+		Content:   "Hello, World!",
+		Usage:     &fakellm.Usage{PromptTokens: 12, CompletionTokens: 4, TotalTokens: 16},
+		RequestID: "req_upstream",
+	})
+
+	resp, err := http.Post(server.URL+"/chat", "application/json", chatBody("Say hello"))
 	if err != nil {
 		t.Fatalf("Failed to call chat endpoint: %v", err)
 	}
@@ -62,91 +212,485 @@ func TestChatEndpoint_NonStreaming(t *testing.T) {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		t.Errorf("Expected status 200, got %d. Body: %s", resp.StatusCode, string(body))
-		return
+		t.Fatalf("Expected status 200, got %d. Body: %s", resp.StatusCode, body)
 	}
 
-	var result map[string]string
+	var result struct {
+		Response          string `json:"response"`
+		FinishReason      string `json:"finish_reason"`
+		Model             string `json:"model"`
+		UpstreamRequestID string `json:"upstream_request_id"`
+		Usage             struct {
+			TotalTokens int `json:"total_tokens"`
+		} `json:"usage"`
+	}
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		t.Fatalf("Failed to decode response: %v", err)
 	}
+	if result.Response != "Hello, World!" || result.FinishReason != "stop" || result.Model != "llama-test" ||
+		result.UpstreamRequestID != "req_upstream" || result.Usage.TotalTokens != 16 {
+		t.Errorf("Unexpected response: %+v", result)
+	}
 
-	if result["response"] == "" {
-		t.Error("Expected non-empty response")
+	// The request ID assigned by the middleware is propagated upstream
+	requests := server.llm.Requests()
+	if len(requests) != 1 {
+		t.Fatalf("Expected 1 upstream request, got %d", len(requests))
+	}
+	if requestID := resp.Header.Get("X-Request-ID"); requestID == "" || requests[0].Header.Get("X-Request-ID") != requestID {
+		t.Errorf("Upstream X-Request-ID = %q, response X-Request-ID = %q",
+			requests[0].Header.Get("X-Request-ID"), requestID)
+	}
+	if requests[0].Stream {
+		t.Error("Expected a non-streaming upstream request")
 	}
 }
 
-func TestChatEndpoint_Validation(t *testing.T) {
-	// Test empty messages
-	reqBody := map[string]interface{}{
-		"messages": []map[string]string{},
-		"stream":   false,
+func TestChatEndpoint_SSE(t *testing.T) {
+	server := startServer(t, nil, fakellm.Response{
+		Content: "One two three.",
+		Usage:   &fakellm.Usage{PromptTokens: 8, CompletionTokens: 3, TotalTokens: 11},
+	})
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/chat", chatBody("Count to three"))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
 	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
 
-	jsonData, _ := json.Marshal(reqBody)
-	resp, err := http.Post(baseURL+"/chat", "application/json", bytes.NewBuffer(jsonData))
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
 	if err != nil {
 		t.Fatalf("Failed to call chat endpoint: %v", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusBadRequest {
-		t.Errorf("Expected status 400 for empty messages, got %d", resp.StatusCode)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	events := readSSE(t, resp.Body)
+	if len(events) < 3 {
+		t.Fatalf("Expected tokens, usage and [DONE], got %+v", events)
+	}
+
+	var answer strings.Builder
+	for _, event := range events[:len(events)-2] {
+		if event.Event != "" {
+			t.Errorf("Unexpected %q event before usage", event.Event)
+		}
+		answer.WriteString(event.Data)
+	}
+	if answer.String() != "One two three." {
+		t.Errorf("Streamed answer = %q", answer.String())
+	}
+	if usage := events[len(events)-2]; usage.Event != "usage" || !strings.Contains(usage.Data, `"total_tokens":11`) {
+		t.Errorf("Unexpected usage event: %+v", usage)
+	}
+	if done := events[len(events)-1]; done.Data != "[DONE]" {
+		t.Errorf("Expected [DONE] last, got %+v", done)
 	}
 }
 
-func TestChatEndpoint_SSE(t *testing.T) {
-	if os.Getenv("GROQ_API_KEY") == "" {
-		t.Skip("Skipping integration test: GROQ_API_KEY not set")
+func TestChatEndpoint_WebSocket(t *testing.T) {
+	server := startServer(t, nil, fakellm.Response{Content: "Streamed over a socket."})
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/chat", nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
 	}
+	defer conn.Close()
 
-	reqBody := map[string]interface{}{
-		"messages": []map[string]string{
-			{"role": "user", "content": "Count to 3, one number per line."},
-		},
-		"stream": true,
+	body, _ := io.ReadAll(chatBody("Stream something"))
+	if err := conn.WriteMessage(websocket.TextMessage, body); err != nil {
+		t.Fatalf("WriteMessage() error = %v", err)
+	}
+
+	var answer strings.Builder
+	var sawUsage bool
+	for {
+		var frame map[string]any
+		if err := conn.ReadJSON(&frame); err != nil {
+			t.Fatalf("Connection closed before done frame: %v", err)
+		}
+		if token, ok := frame["token"].(string); ok {
+			answer.WriteString(token)
+			continue
+		}
+		if frame["type"] == "usage" {
+			sawUsage = true
+			continue
+		}
+		if frame["done"] == "true" {
+			break
+		}
+		t.Fatalf("Unexpected frame: %v", frame)
+	}
+
+	if answer.String() != "Streamed over a socket." {
+		t.Errorf("Streamed answer = %q", answer.String())
+	}
+	if !sawUsage {
+		t.Error("Expected a usage frame before done")
+	}
+}
+
+func TestChatEndpoint_Validation(t *testing.T) {
+	server := startServer(t, nil)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"empty messages", `{"messages": []}`},
+		{"invalid JSON", `{"messages": [`},
+		{"last message not from user", `{"messages": [{"role": "assistant", "content": "Hi"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Post(server.URL+"/chat", "application/json", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("Failed to call chat endpoint: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("Expected status 400, got %d", resp.StatusCode)
+			}
+		})
+	}
+
+	if n := len(server.llm.Requests()); n != 0 {
+		t.Errorf("Invalid requests reached the LLM %d times", n)
+	}
+}
+
+func TestChatEndpoint_ErrorMapping(t *testing.T) {
+	tests := []struct {
+		name       string
+		upstream   fakellm.Response
+		wantStatus int
+		wantType   string
+	}{
+		{"unauthorized", fakellm.Response{Status: http.StatusUnauthorized}, http.StatusUnauthorized, "unauthorized_error"},
+		{"rate limited", fakellm.Response{Status: http.StatusTooManyRequests}, http.StatusTooManyRequests, "rate_limit_error"},
+		{"upstream error", fakellm.Response{Status: http.StatusInternalServerError}, http.StatusBadGateway, "llm_error"},
+		{"upstream timeout", fakellm.Response{Status: http.StatusGatewayTimeout}, http.StatusGatewayTimeout, "timeout_error"},
+		{"malformed body", fakellm.Response{Body: `{"choices": [`}, http.StatusBadGateway, "llm_error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := startServer(t, nil, tt.upstream)
+
+			resp, err := http.Post(server.URL+"/chat", "application/json", chatBody("Hi"))
+			if err != nil {
+				t.Fatalf("Failed to call chat endpoint: %v", err)
+			}
+			defer resp.Body.Close()
+
+			var body struct {
+				Error struct {
+					Type      string `json:"type"`
+					RequestID string `json:"request_id"`
+				} `json:"error"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			if resp.StatusCode != tt.wantStatus || body.Error.Type != tt.wantType {
+				t.Errorf("Got %d %q, want %d %q", resp.StatusCode, body.Error.Type, tt.wantStatus, tt.wantType)
+			}
+			if body.Error.RequestID == "" || body.Error.RequestID != resp.Header.Get("X-Request-ID") {
+				t.Errorf("Error request_id = %q, header = %q", body.Error.RequestID, resp.Header.Get("X-Request-ID"))
+			}
+		})
+	}
+}
+
+func TestChatEndpoint_HistoryTrimming(t *testing.T) {
+	server := startServer(t, map[string]string{"MAX_EXCHANGES": "2"},
+		fakellm.Response{Content: "Answer 1"},
+		fakellm.Response{Content: "Answer 2"},
+		fakellm.Response{Content: "Answer 3"},
+		fakellm.Response{Content: "Answer 4"},
+	)
+
+	for _, question := range []string{"Question 1", "Question 2", "Question 3", "Question 4"} {
+		resp, err := http.Post(server.URL+"/chat", "application/json", chatBody(question))
+		if err != nil {
+			t.Fatalf("Failed to call chat endpoint: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+		}
 	}
 
-	jsonData, err := json.Marshal(reqBody)
+	requests := server.llm.Requests()
+	if len(requests) != 4 {
+		t.Fatalf("Expected 4 upstream requests, got %d", len(requests))
+	}
+
+	// Only the last two exchanges are sent along with the new question
+	var contents []string
+	for _, raw := range requests[3].Messages {
+		var message struct {
+			Content string `json:"content"`
+		}
+		if err := json.Unmarshal(raw, &message); err != nil {
+			t.Fatalf("Failed to decode upstream message: %v", err)
+		}
+		contents = append(contents, message.Content)
+	}
+	want := []string{"Question 2", "Answer 2", "Question 3", "Answer 3", "Question 4"}
+	if strings.Join(contents, "|") != strings.Join(want, "|") {
+		t.Errorf("Upstream messages = %q, want %q", contents, want)
+	}
+}
+
+func TestSelectCandidateEndpoint(t *testing.T) {
+	server := startServer(t, nil,
+		fakellm.Response{Content: "Fresh bread daily"},
+		fakellm.Response{Content: "Baked with love"},
+		fakellm.Response{Content: "Glad you liked it"},
+	)
+
+	resp, err := http.Post(server.URL+"/chat", "application/json",
+		strings.NewReader(`{"messages":[{"role":"user","content":"Write a tagline"}],"n":2}`))
 	if err != nil {
-		t.Fatalf("Failed to marshal request: %v", err)
+		t.Fatalf("Failed to call chat endpoint: %v", err)
+	}
+	var chat struct {
+		SelectionID string `json:"selection_id"`
+		Candidates  []struct {
+			Response string `json:"response"`
+		} `json:"candidates"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&chat)
+	resp.Body.Close()
+	if err != nil || chat.SelectionID == "" || len(chat.Candidates) != 2 {
+		t.Fatalf("Expected 2 candidates and a selection ID, got %+v (%v)", chat, err)
+	}
+
+	selectURL := server.URL + "/chat/candidates/" + chat.SelectionID + "/select"
+	tests := []struct {
+		name       string
+		url        string
+		body       string
+		wantStatus int
+	}{
+		{name: "missing index", url: selectURL, body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "index out of range", url: selectURL, body: `{"index":2}`, wantStatus: http.StatusBadRequest},
+		{name: "unknown selection", url: server.URL + "/chat/candidates/unknown/select", body: `{"index":1}`, wantStatus: http.StatusNotFound},
+		{name: "selected", url: selectURL, body: `{"index":1}`, wantStatus: http.StatusOK},
+		{name: "already selected", url: selectURL, body: `{"index":0}`, wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		resp, err := http.Post(tt.url, "application/json", strings.NewReader(tt.body))
+		if err != nil {
+			t.Fatalf("%s: failed to call select endpoint: %v", tt.name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, resp.StatusCode, tt.wantStatus)
+		}
 	}
 
-	req, err := http.NewRequest("POST", baseURL+"/chat", bytes.NewBuffer(jsonData))
+	// The next turn sees the selected candidate as the previous answer
+	resp, err = http.Post(server.URL+"/chat", "application/json", chatBody("Thanks"))
 	if err != nil {
-		t.Fatalf("Failed to create request: %v", err)
+		t.Fatalf("Failed to call chat endpoint: %v", err)
+	}
+	resp.Body.Close()
+	requests := server.llm.Requests()
+	if messages := requests[len(requests)-1].Messages; len(messages) != 3 || !strings.Contains(string(messages[1]), chat.Candidates[1].Response) {
+		t.Errorf("Expected the selected answer in the next turn's history, got %s", messages)
 	}
+}
+
+func TestTracePropagation(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+	}()
 
+	server := startServer(t, nil, fakellm.Response{Content: "Hello!"})
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	const clientSpanID = "00f067aa0ba902b7"
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/chat", chatBody("Say hello"))
+	if err != nil {
+		t.Fatalf("NewRequest() error = %v", err)
+	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("traceparent", "00-"+traceID+"-"+clientSpanID+"-01")
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		t.Fatalf("Failed to call chat endpoint: %v", err)
 	}
-	defer resp.Body.Close()
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	serverSpan, requestSpan := spans["POST /chat"], spans["GroqClient.DoRequest"]
+	if serverSpan == nil || requestSpan == nil {
+		t.Fatalf("Expected server and upstream request spans, got %v", spans)
+	}
 
+	// The server span continues the client's trace
+	if serverSpan.SpanContext().TraceID().String() != traceID || serverSpan.Parent().SpanID().String() != clientSpanID {
+		t.Errorf("Server span trace %s parent %s, want trace %s parent %s",
+			serverSpan.SpanContext().TraceID(), serverSpan.Parent().SpanID(), traceID, clientSpanID)
+	}
+
+	// The provider receives the same trace, parented to the upstream request span
+	want := "00-" + traceID + "-" + requestSpan.SpanContext().SpanID().String() + "-01"
+	if got := server.llm.Requests()[0].Header.Get("traceparent"); got != want {
+		t.Errorf("Upstream traceparent = %q, want %q", got, want)
+	}
+}
+
+func TestTokenCountCache(t *testing.T) {
+	server := startServer(t, nil)
+
+	resp, err := http.Post(server.URL+"/chat", "application/json", chatBody("Count my tokens"))
+	if err != nil {
+		t.Fatalf("Failed to call chat endpoint: %v", err)
+	}
+	resp.Body.Close()
+
+	keys := server.redis.Keys()
+	if len(keys) != 1 || !strings.HasPrefix(keys[0], "token_count:") {
+		t.Fatalf("Redis keys = %q, want one token count", keys)
+	}
+	if ttl := server.redis.TTL(keys[0]); ttl <= 0 {
+		t.Errorf("Token count TTL = %v, want it to expire", ttl)
+	}
+}
+
+func TestTokenCountCache_SurvivesRedisOutage(t *testing.T) {
+	server := startServer(t, nil)
+
+	// Chats keep working while Redis is down; the cache is skipped
+	server.redis.Close()
+	resp, err := http.Post(server.URL+"/chat", "application/json", chatBody("Count my tokens"))
+	if err != nil {
+		t.Fatalf("Failed to call chat endpoint: %v", err)
+	}
+	resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		t.Errorf("Expected status 200, got %d. Body: %s", resp.StatusCode, string(body))
-		return
+		t.Fatalf("Status = %d with Redis down, want 200", resp.StatusCode)
 	}
 
-	// Read at least some data
-	buffer := make([]byte, 1024)
-	n, err := resp.Body.Read(buffer)
-	if err != nil && err != io.EOF {
-		t.Fatalf("Failed to read SSE stream: %v", err)
+	// The cache reconnects in the background once Redis is back
+	if err := server.redis.Restart(); err != nil {
+		t.Fatalf("Failed to restart Redis: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for server.health.Check(context.Background()).Components["redis"].Status != health.StatusUp {
+		if time.Now().After(deadline) {
+			t.Fatal("Redis cache did not reconnect")
+		}
+		time.Sleep(50 * time.Millisecond)
 	}
 
-	if n == 0 {
-		t.Error("Expected to receive some data from SSE stream")
+	resp, err = http.Post(server.URL+"/chat", "application/json", chatBody("Count my tokens again"))
+	if err != nil {
+		t.Fatalf("Failed to call chat endpoint: %v", err)
+	}
+	resp.Body.Close()
+	if keys := server.redis.Keys(); len(keys) != 1 {
+		t.Errorf("Redis keys = %q, want the token count cached after reconnecting", keys)
+	}
+}
+
+func TestTokenizeEndpoint(t *testing.T) {
+	server := startServer(t, nil)
+
+	tests := []struct {
+		name         string
+		body         string
+		wantStatus   int
+		wantModel    string
+		wantEncoding string
+	}{
+		{
+			name:         "server model",
+			body:         `{"messages":[{"role":"user","content":"Hello world"}]}`,
+			wantStatus:   http.StatusOK,
+			wantModel:    "llama-test",
+			wantEncoding: "llama",
+		},
+		{
+			name:         "named model",
+			body:         `{"model":"gpt-4o","messages":[{"role":"user","content":"Hello world"}]}`,
+			wantStatus:   http.StatusOK,
+			wantModel:    "gpt-4o",
+			wantEncoding: "o200k_base",
+		},
+		{
+			name:       "no messages",
+			body:       `{"messages":[]}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Post(server.URL+"/tokenize", "application/json", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatalf("Failed to call tokenize endpoint: %v", err)
+			}
+			defer resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("Status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var count struct {
+				Model        string `json:"model"`
+				Encoding     string `json:"encoding"`
+				PromptTokens int    `json:"prompt_tokens"`
+				Messages     []int  `json:"messages"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&count); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if count.Model != tt.wantModel || count.Encoding != tt.wantEncoding {
+				t.Errorf("model %q encoding %q, want %q and %q", count.Model, count.Encoding, tt.wantModel, tt.wantEncoding)
+			}
+			if len(count.Messages) != 1 || count.PromptTokens <= count.Messages[0] {
+				t.Errorf("count = %+v, want one message and the template overhead on top", count)
+			}
+		})
+	}
+
+	// Counting needs no LLM call
+	if got := server.llm.Requests(); len(got) != 0 {
+		t.Errorf("upstream received %d requests, want none", len(got))
 	}
 }
 
 func TestMetricsEndpoint(t *testing.T) {
-	resp, err := http.Get(baseURL + "/metrics")
+	server := startServer(t, nil)
+
+	resp, err := http.Get(server.URL + "/metrics")
 	if err != nil {
 		t.Fatalf("Failed to call metrics endpoint: %v", err)
 	}
@@ -160,29 +704,7 @@ func TestMetricsEndpoint(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to read response: %v", err)
 	}
-
-	// Check for Prometheus metrics format
-	bodyStr := string(body)
-	if bodyStr == "" {
-		t.Error("Expected non-empty metrics response")
-	}
-}
-
-// Helper function to wait for server to be ready
-func TestMain(m *testing.M) {
-	// Wait a bit for server to start if needed
-	maxRetries := 10
-	for i := 0; i < maxRetries; i++ {
-		resp, err := http.Get(baseURL + "/health")
-		if err == nil && resp.StatusCode == http.StatusOK {
-			resp.Body.Close()
-			break
-		}
-		if i == maxRetries-1 {
-			fmt.Println("Warning: Server may not be running. Some tests may fail.")
-		}
-		time.Sleep(1 * time.Second)
+	if !strings.Contains(string(body), "chat_requests_total") {
+		t.Error("Expected chat metrics in the response")
 	}
-
-	os.Exit(m.Run())
 }